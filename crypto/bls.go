@@ -0,0 +1,259 @@
+package crypto
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+
+	bls "github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/kilic/bls12-381"
+	uuid "github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/wayn3h0/go-uuid"
+)
+
+//-----------------------------------------------------------------------------
+// bls12-381 keys
+//
+// Follows the proof of possession scheme of the IETF BLS signature draft,
+// with minimal pubkey size: pubkeys are 48 byte compressed G1 points and
+// signatures 96 byte compressed G2 points. Private keys are the 32 byte scalar.
+//
+// Signatures over the same message can only be safely aggregated if each
+// pubkey has proven possession of its private key (see ProofOfPossession)
+
+const (
+	blsSigDST = "BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_"
+	blsPopDST = "BLS_POP_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_"
+
+	blsKeyGenSalt = "BLS-SIG-KEYGEN-SALT-"
+)
+
+// order of G1 and G2
+var blsOrder, _ = new(big.Int).SetString("73EDA753299D7D483339D80809A1D80553BDA402FFFE5BFEFFFFFFFF00000001", 16)
+
+func newKeyBLS12381(addrType AddrType) (*Key, error) {
+	ikm, err := randentropy.GetEntropyCSPRNG(32)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := blsKeyGen(ikm)
+	if err != nil {
+		return nil, err
+	}
+	return keyFromPrivBLS12381(addrType, priv)
+}
+
+// blsKeyGen derives a private key from at least 32 bytes of key material (KeyGen in the draft)
+func blsKeyGen(ikm []byte) ([]byte, error) {
+	if len(ikm) < 32 {
+		return nil, fmt.Errorf("bls key generation needs at least 32 bytes of key material")
+	}
+	salt := []byte(blsKeyGenSalt)
+	sk := new(big.Int)
+	for sk.Sign() == 0 {
+		salt = Sha256(salt)
+		okm, err := HKDF(append(append([]byte{}, ikm...), 0), salt, []byte{0, 48}, 48)
+		if err != nil {
+			return nil, err
+		}
+		sk.SetBytes(okm).Mod(sk, blsOrder)
+	}
+	priv := make([]byte, 32)
+	return sk.FillBytes(priv), nil
+}
+
+func privKeyBLS12381(priv []byte) (*big.Int, error) {
+	sk := new(big.Int).SetBytes(priv)
+	if len(priv) != 32 || sk.Sign() == 0 || sk.Cmp(blsOrder) >= 0 {
+		return nil, fmt.Errorf("invalid bls12381 private key")
+	}
+	return sk, nil
+}
+
+func keyFromPrivBLS12381(addrType AddrType, priv []byte) (*Key, error) {
+	if addrType != AddrTypeRipemd160Sha256 && addrType != AddrTypeSha256 {
+		return nil, fmt.Errorf("bls12381 keys need a ripemd160sha256 or sha256 address, not %s", addrType)
+	}
+	k := &Key{
+		Type:       KeyType{CurveTypeBLS12381, addrType},
+		PrivateKey: priv,
+	}
+	pub, err := pubKeyBLS12381(k)
+	if err != nil {
+		return nil, err
+	}
+	k.Id, _ = uuid.NewRandom()
+	k.Address = AddressFromPub(addrType, pub)
+	return k, nil
+}
+
+func pubKeyBLS12381(k *Key) ([]byte, error) {
+	sk, err := privKeyBLS12381(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	g1 := bls.NewG1()
+	pub := g1.MulScalarBig(g1.New(), g1.One(), sk)
+	return g1.ToCompressed(pub), nil
+}
+
+func signBLS12381(k *Key, msg []byte) ([]byte, error) {
+	return blsSign(k, msg, blsSigDST)
+}
+
+func blsSign(k *Key, msg []byte, dst string) ([]byte, error) {
+	sk, err := privKeyBLS12381(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	g2 := bls.NewG2()
+	point, err := g2.HashToCurve(msg, []byte(dst))
+	if err != nil {
+		return nil, err
+	}
+	sig := g2.MulScalarBig(g2.New(), point, sk)
+	return g2.ToCompressed(sig), nil
+}
+
+func verifySigBLS12381(msg, sig, pub []byte) (bool, error) {
+	return blsVerify([][]byte{msg}, sig, [][]byte{pub}, blsSigDST)
+}
+
+// ProofOfPossession signs the key's own pubkey, proving it holds the private key.
+// Only bls12381 keys need one
+func (k *Key) ProofOfPossession() ([]byte, error) {
+	if k.Type.CurveType != CurveTypeBLS12381 {
+		return nil, fmt.Errorf("proofs of possession are only for bls12381 keys")
+	}
+	pub, err := k.Pubkey()
+	if err != nil {
+		return nil, err
+	}
+	return blsSign(k, pub, blsPopDST)
+}
+
+// VerifyProofOfPossession checks a bls12381 pubkey's proof of possession
+func VerifyProofOfPossession(pub, proof []byte) (bool, error) {
+	return blsVerify([][]byte{pub}, proof, [][]byte{pub}, blsPopDST)
+}
+
+// AggregateSignatures combines bls12381 signatures into one 96 byte signature
+func AggregateSignatures(sigs [][]byte) ([]byte, error) {
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("no signatures to aggregate")
+	}
+	g2 := bls.NewG2()
+	agg := g2.Zero()
+	for i, sigB := range sigs {
+		sig, err := g2.FromCompressed(sigB)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bls12381 signature %d: %v", i, err)
+		}
+		g2.Add(agg, agg, sig)
+	}
+	return g2.ToCompressed(agg), nil
+}
+
+// VerifyAggregate verifies an aggregate bls12381 signature. With a single message,
+// all pubkeys signed it. Otherwise each pubkey signed the message at the same index.
+// Unless every pubkey signed a different message, each one needs a valid proof
+// of possession in pops, or one signer could forge the others' signatures
+func VerifyAggregate(msgs [][]byte, sig []byte, pubs, pops [][]byte) (bool, error) {
+	if len(pubs) == 0 {
+		return false, fmt.Errorf("no pubkeys to verify against")
+	}
+	if len(msgs) != 1 && len(msgs) != len(pubs) {
+		return false, fmt.Errorf("got %d messages for %d pubkeys", len(msgs), len(pubs))
+	}
+	if len(pops) > 0 || !distinct(msgs, len(pubs)) {
+		if len(pops) != len(pubs) {
+			return false, fmt.Errorf("need a proof of possession for each of the %d pubkeys, got %d", len(pubs), len(pops))
+		}
+		for i := range pops {
+			ok, err := VerifyProofOfPossession(pubs[i], pops[i])
+			if err != nil {
+				return false, fmt.Errorf("proof of possession %d: %v", i, err)
+			}
+			if !ok {
+				return false, fmt.Errorf("invalid proof of possession for pubkey %X", pubs[i])
+			}
+		}
+	}
+	if len(msgs) == 1 {
+		agg, err := aggregatePubkeys(pubs)
+		if err != nil {
+			return false, err
+		}
+		return blsVerify(msgs, sig, [][]byte{agg}, blsSigDST)
+	}
+	return blsVerify(msgs, sig, pubs, blsSigDST)
+}
+
+// distinct reports whether each of n pubkeys signed a different message
+func distinct(msgs [][]byte, n int) bool {
+	if len(msgs) == 1 {
+		return n == 1
+	}
+	seen := make(map[string]bool, len(msgs))
+	for _, msg := range msgs {
+		if seen[string(msg)] {
+			return false
+		}
+		seen[string(msg)] = true
+	}
+	return true
+}
+
+func aggregatePubkeys(pubs [][]byte) ([]byte, error) {
+	g1 := bls.NewG1()
+	agg := g1.Zero()
+	for i, pubB := range pubs {
+		pub, err := blsPubkey(g1, pubB)
+		if err != nil {
+			return nil, fmt.Errorf("pubkey %d: %v", i, err)
+		}
+		g1.Add(agg, agg, pub)
+	}
+	return g1.ToCompressed(agg), nil
+}
+
+func canonicalPubBLS12381(pubB []byte) ([]byte, error) {
+	g1 := bls.NewG1()
+	pub, err := blsPubkey(g1, pubB)
+	if err != nil {
+		return nil, err
+	}
+	return g1.ToCompressed(pub), nil
+}
+
+func blsPubkey(g1 *bls.G1, pubB []byte) (*bls.PointG1, error) {
+	pub, err := g1.FromCompressed(pubB)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bls12381 pubkey %X: %v", pubB, err)
+	}
+	if g1.IsZero(pub) {
+		return nil, fmt.Errorf("invalid bls12381 pubkey %X: identity", pubB)
+	}
+	return pub, nil
+}
+
+// blsVerify checks e(g1, sig) == prod e(pub_i, H(msg_i))
+func blsVerify(msgs [][]byte, sigB []byte, pubs [][]byte, dst string) (bool, error) {
+	engine := bls.NewEngine()
+	sig, err := engine.G2.FromCompressed(sigB)
+	if err != nil {
+		return false, fmt.Errorf("invalid bls12381 signature: %v", err)
+	}
+	engine.AddPairInv(engine.G1.One(), sig)
+	for i, msg := range msgs {
+		pub, err := blsPubkey(engine.G1, pubs[i])
+		if err != nil {
+			return false, err
+		}
+		point, err := engine.G2.HashToCurve(msg, []byte(dst))
+		if err != nil {
+			return false, err
+		}
+		engine.AddPair(pub, point)
+	}
+	return engine.Check(), nil
+}
@@ -0,0 +1,290 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	bls "github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/kilic/bls12-381"
+)
+
+func newBLSKeys(t *testing.T, n int) ([]*Key, [][]byte) {
+	keys := make([]*Key, n)
+	pubs := make([][]byte, n)
+	for i := range keys {
+		key, err := NewKey(KeyType{CurveTypeBLS12381, AddrTypeSha256})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+		if pubs[i], err = key.Pubkey(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys, pubs
+}
+
+func TestBLS12381(t *testing.T) {
+	keys, pubs := newBLSKeys(t, 1)
+	key, pub := keys[0], pubs[0]
+	if len(pub) != 48 {
+		t.Fatalf("Expected a 48 byte pubkey, got %d", len(pub))
+	}
+	if addr := Sha256(pub); !bytes.Equal(addr, key.Address) {
+		t.Fatalf("Wrong address. Got %X, expected %X", key.Address, addr)
+	}
+
+	msg := []byte("a message")
+	sig, err := key.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != 96 {
+		t.Fatalf("Expected a 96 byte signature, got %d", len(sig))
+	}
+	if res, err := Verify(CurveTypeBLS12381, msg, sig, pub); err != nil || !res {
+		t.Fatalf("Signature failed to verify: %v", err)
+	}
+	if res, _ := Verify(CurveTypeBLS12381, []byte("another message"), sig, pub); res {
+		t.Fatal("Signature verified for the wrong message")
+	}
+
+	// deterministic, and reproducible from the private key
+	key2, err := NewKeyFromPriv(key.Type, key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig2, _ := key2.Sign(msg)
+	if !bytes.Equal(key.Address, key2.Address) || !bytes.Equal(sig, sig2) {
+		t.Fatal("Key from the same private key gave a different address or signature")
+	}
+
+	if _, err := NewKey(KeyType{CurveTypeBLS12381, AddrTypeRipemd160}); err == nil {
+		t.Fatal("Expected an error for a ripemd160 bls key")
+	}
+}
+
+func TestBLSProofOfPossession(t *testing.T) {
+	keys, pubs := newBLSKeys(t, 2)
+	proof, err := keys[0].ProofOfPossession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := VerifyProofOfPossession(pubs[0], proof); err != nil || !res {
+		t.Fatalf("Proof of possession failed to verify: %v", err)
+	}
+	if res, _ := VerifyProofOfPossession(pubs[1], proof); res {
+		t.Fatal("Proof of possession verified for another pubkey")
+	}
+
+	// a proof is not a signature of the pubkey
+	sig, _ := keys[0].Sign(pubs[0])
+	if res, _ := VerifyProofOfPossession(pubs[0], sig); res {
+		t.Fatal("Signature of the pubkey accepted as a proof of possession")
+	}
+
+	ed, _ := NewKey(KeyType{CurveTypeEd25519, AddrTypeRipemd160})
+	if _, err := ed.ProofOfPossession(); err == nil {
+		t.Fatal("Expected an error for an ed25519 proof of possession")
+	}
+}
+
+func TestBLSAggregate(t *testing.T) {
+	keys, pubs := newBLSKeys(t, 3)
+
+	// everyone signs the same message
+	msg := []byte("a message")
+	sigs := make([][]byte, len(keys))
+	for i, key := range keys {
+		sigs[i], _ = key.Sign(msg)
+	}
+	agg, err := AggregateSignatures(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pops := make([][]byte, len(keys))
+	for i, key := range keys {
+		pops[i], _ = key.ProofOfPossession()
+	}
+	if res, err := VerifyAggregate([][]byte{msg}, agg, pubs, pops); err != nil || !res {
+		t.Fatalf("Aggregate signature failed to verify: %v", err)
+	}
+	if res, _ := VerifyAggregate([][]byte{msg}, agg, pubs[:2], pops[:2]); res {
+		t.Fatal("Aggregate signature verified without one of the signers")
+	}
+	if _, err := VerifyAggregate([][]byte{msg}, agg, pubs, nil); err == nil {
+		t.Fatal("Expected an error for a shared message without proofs of possession")
+	}
+	if _, err := VerifyAggregate([][]byte{msg}, agg, pubs, [][]byte{pops[0], pops[2], pops[1]}); err == nil {
+		t.Fatal("Expected an error for the wrong proofs of possession")
+	}
+
+	// everyone signs their own message
+	msgs := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	for i, key := range keys {
+		sigs[i], _ = key.Sign(msgs[i])
+	}
+	agg, err = AggregateSignatures(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := VerifyAggregate(msgs, agg, pubs, nil); err != nil || !res {
+		t.Fatalf("Aggregate signature failed to verify: %v", err)
+	}
+	msgs[0], msgs[1] = msgs[1], msgs[0]
+	if res, _ := VerifyAggregate(msgs, agg, pubs, nil); res {
+		t.Fatal("Aggregate signature verified with swapped messages")
+	}
+	if _, err := VerifyAggregate(msgs[:2], agg, pubs, nil); err == nil {
+		t.Fatal("Expected an error for mismatched messages and pubkeys")
+	}
+
+	// two of them sign the same message
+	msgs[2] = msgs[0]
+	for i, key := range keys {
+		sigs[i], _ = key.Sign(msgs[i])
+	}
+	agg, _ = AggregateSignatures(sigs)
+	if _, err := VerifyAggregate(msgs, agg, pubs, nil); err == nil {
+		t.Fatal("Expected an error for a repeated message without proofs of possession")
+	}
+	if res, err := VerifyAggregate(msgs, agg, pubs, pops); err != nil || !res {
+		t.Fatalf("Aggregate signature failed to verify: %v", err)
+	}
+
+	if _, err := AggregateSignatures([][]byte{sigs[0], sigs[1][:95]}); err == nil {
+		t.Fatal("Expected an error for a truncated signature")
+	}
+}
+
+// known answers from the eth2 consensus spec bls tests, which use the same ciphersuite
+var blsTestPrivs = []string{
+	"263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3",
+	"47b8192d77bf871b62e87859d653922725724a5c031afeabc60bcef5ff665138",
+	"328388aff0d4a5b7dc9205abd374e7e98f3cd9f3418edb4eafda5fb16473d216",
+}
+
+var blsTestPubs = []string{
+	"a491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a",
+	"b301803f8b5ac4a1133581fc676dfedc60d891dd5fa99028805e5ea5b08d3491af75d0707adab3b70c6a6a580217bf81",
+	"b53d21a4cfd562c469cc81514d4ce5a6b577d8403d32a394dc265dd190b47fa9f829fdd7963afdf972e5e77854051f6f",
+}
+
+func blsHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// the rogue key attack: the attacker's pubkey x*G - victim makes the
+// aggregate pubkey x*G, so the attacker alone can sign for both
+func TestBLSRogueKey(t *testing.T) {
+	keys, pubs := newBLSKeys(t, 2)
+	victim, attacker := pubs[0], pubs[1]
+
+	g1 := bls.NewG1()
+	v, _ := g1.FromCompressed(victim)
+	a, _ := g1.FromCompressed(attacker)
+	rogue := g1.ToCompressed(g1.Sub(g1.New(), a, v))
+
+	msg := []byte("the victim agrees")
+	sig, _ := keys[1].Sign(msg)
+	if _, err := VerifyAggregate([][]byte{msg}, sig, [][]byte{victim, rogue}, nil); err == nil {
+		t.Fatal("Expected an error for a shared message without proofs of possession")
+	}
+	if _, err := VerifyAggregate([][]byte{msg, msg}, sig, [][]byte{victim, rogue}, nil); err == nil {
+		t.Fatal("Expected an error for a repeated message without proofs of possession")
+	}
+
+	// the attacker can't prove possession of the rogue key
+	victimPop, _ := keys[0].ProofOfPossession()
+	attackerPop, _ := keys[1].ProofOfPossession()
+	if _, err := VerifyAggregate([][]byte{msg}, sig, [][]byte{victim, rogue}, [][]byte{victimPop, attackerPop}); err == nil {
+		t.Fatal("Expected an error for a rogue key's proof of possession")
+	}
+}
+
+func TestBLSKnownAnswers(t *testing.T) {
+	keys := make([]*Key, len(blsTestPrivs))
+	pubs := make([][]byte, len(blsTestPrivs))
+	pops := make([][]byte, len(blsTestPrivs))
+	for i, priv := range blsTestPrivs {
+		key, err := NewKeyFromPriv(KeyType{CurveTypeBLS12381, AddrTypeSha256}, blsHex(t, priv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+		pops[i], _ = key.ProofOfPossession()
+		if pubs[i], _ = key.Pubkey(); !bytes.Equal(pubs[i], blsHex(t, blsTestPubs[i])) {
+			t.Fatalf("Wrong pubkey for private key %d. Got %X, expected %s", i, pubs[i], blsTestPubs[i])
+		}
+	}
+
+	// sign
+	msg := make([]byte, 32)
+	sig, err := keys[0].Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := blsHex(t, "b6ed936746e01f8ecf281f020953fbf1f01debd5657c4a383940b020b26507f6076334f91e2366c96e9ab279fb5158090352ea1c5b0c9274504f4f0e7053af24802e51e4568d164fe986834f41e55c8e850ce1f98458c0cfc9ab380b55285a55")
+	if !bytes.Equal(sig, expected) {
+		t.Fatalf("Wrong signature. Got %X, expected %X", sig, expected)
+	}
+	if res, err := Verify(CurveTypeBLS12381, msg, expected, pubs[0]); err != nil || !res {
+		t.Fatalf("Known signature failed to verify: %v", err)
+	}
+
+	// aggregate, and fast aggregate verify
+	for _, v := range []struct {
+		msg, agg string
+	}{
+		{strings.Repeat("00", 32), "9683b3e6701f9a4b706709577963110043af78a5b41991b998475a3d3fd62abf35ce03b33908418efc95a058494a8ae504354b9f626231f6b3f3c849dfdeaf5017c4780e2aee1850ceaf4b4d9ce70971a3d2cfcd97b7e5ecf6759f8da5f76d31"},
+		{strings.Repeat("ab", 32), "9712c3edd73a209c742b8250759db12549b3eaf43b5ca61376d9f30e2747dbcf842d8b2ac0901d2a093713e20284a7670fcf6954e9ab93de991bb9b313e664785a075fc285806fa5224c82bde146561b446ccfc706a64b8579513cfc4ff1d930"},
+	} {
+		msg := blsHex(t, v.msg)
+		sigs := make([][]byte, len(keys))
+		for i, key := range keys {
+			sigs[i], _ = key.Sign(msg)
+		}
+		agg, err := AggregateSignatures(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := blsHex(t, v.agg); !bytes.Equal(agg, expected) {
+			t.Fatalf("Wrong aggregate signature of %s. Got %X, expected %X", v.msg, agg, expected)
+		}
+		if res, err := VerifyAggregate([][]byte{msg}, agg, pubs, pops); err != nil || !res {
+			t.Fatalf("Known aggregate signature failed to verify: %v", err)
+		}
+	}
+
+	// the point at infinity is neither a pubkey nor a signature
+	infPub := append([]byte{0xc0}, make([]byte, 47)...)
+	infSig := append([]byte{0xc0}, make([]byte, 95)...)
+	if res, _ := Verify(CurveTypeBLS12381, msg, infSig, infPub); res {
+		t.Fatal("Infinite signature verified for the infinite pubkey")
+	}
+	if res, _ := VerifyAggregate([][]byte{msg}, infSig, [][]byte{infPub}, nil); res {
+		t.Fatal("Infinite aggregate signature verified for the infinite pubkey")
+	}
+}
+
+// the G2 hash to curve suite the ciphersuite is built on, from RFC 9380 appendix J.10.1
+func TestBLSHashToCurve(t *testing.T) {
+	g2 := bls.NewG2()
+	p, err := g2.HashToCurve([]byte(""), []byte("QUUX-V01-CS02-with-BLS12381G2_XMD:SHA-256_SSWU_RO_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// x and y, each as c1 || c0
+	expected := blsHex(t, "05cb8437535e20ecffaef7752baddf98034139c38452458baeefab379ba13dff5bf5dd71b72418717047f5b0f37da03d"+
+		"0141ebfbdca40eb85b87142e130ab689c673cf60f1a3e98d69335266f30d9b8d4ac44c1038e9dcdd5393faf5c41fb78a"+
+		"12424ac32561493f3fe3c260708a12b7c620e7be00099a974e259ddc7d1f6395c3c811cdd19f1e8dbf3e9ecfdcbab8d6"+
+		"0503921d7f6a12805e72940b963c0cf3471c7b2a524950ca195d11062ee75ec076daf2d4bc358c4b190c0c98064fdd92")
+	if b := g2.ToBytes(p); !bytes.Equal(b, expected) {
+		t.Fatalf("Wrong hash to curve. Got %X, expected %X", b, expected)
+	}
+}
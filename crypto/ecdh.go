@@ -0,0 +1,76 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/eris-ltd/eris-keys/crypto/ed25519/extra25519"
+	"github.com/eris-ltd/eris-keys/crypto/secp256k1"
+
+	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/golang.org/x/crypto/curve25519"
+)
+
+//-----------------------------------------------------------------------------
+// key agreement
+
+// SharedSecret computes the raw Diffie-Hellman secret between the key and a peer's pubkey.
+// Ed25519 keys are converted to curve25519 (X25519), secp256k1 keys
+// use the x coordinate of the shared point. Run the result through HKDF before use
+func (k *Key) SharedSecret(peerPub []byte) ([]byte, error) {
+	switch k.Type.CurveType {
+	case CurveTypeSecp256k1:
+		return secp256k1.ECDH(k.PrivateKey, peerPub)
+	case CurveTypeEd25519:
+		return sharedSecretEd25519(k, peerPub)
+	}
+	return nil, InvalidCurveErr(k.Type.CurveType)
+}
+
+func sharedSecretEd25519(k *Key, peerPub []byte) ([]byte, error) {
+	if len(peerPub) != 32 {
+		return nil, fmt.Errorf("ed25519 pubkey must be 32 bytes, got %d", len(peerPub))
+	}
+	var edPriv [64]byte
+	copy(edPriv[:], k.PrivateKey)
+	var edPub, curvePriv, curvePub, secret [32]byte
+	copy(edPub[:], peerPub)
+
+	extra25519.PrivateKeyToCurve25519(&curvePriv, &edPriv)
+	if !extra25519.PublicKeyToCurve25519(&curvePub, &edPub) {
+		return nil, fmt.Errorf("invalid ed25519 pubkey %X", peerPub)
+	}
+	curve25519.ScalarMult(&secret, &curvePriv, &curvePub)
+
+	// a low order peer key gives an all zero secret
+	var zero [32]byte
+	if hmac.Equal(secret[:], zero[:]) {
+		return nil, fmt.Errorf("invalid ed25519 pubkey %X", peerPub)
+	}
+	return secret[:], nil
+}
+
+// HKDF derives length bytes from the secret as in RFC 5869, using sha256
+func HKDF(secret, salt, info []byte, length int) ([]byte, error) {
+	if length <= 0 || length > 255*sha256.Size {
+		return nil, fmt.Errorf("invalid hkdf output length %d", length)
+	}
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	var out, t []byte
+	expander := hmac.New(sha256.New, prk)
+	for i := byte(1); len(out) < length; i++ {
+		expander.Reset()
+		expander.Write(t)
+		expander.Write(info)
+		expander.Write([]byte{i})
+		t = expander.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length], nil
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 5869, test case 1
+func TestHKDF(t *testing.T) {
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	expected := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+	okm, err := HKDF(ikm, salt, info, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(okm) != expected {
+		t.Fatalf("Wrong hkdf output. Got %x, expected %s", okm, expected)
+	}
+}
+
+func TestSharedSecret(t *testing.T) {
+	for _, curve := range []CurveType{CurveTypeEd25519, CurveTypeSecp256k1} {
+		k1, err := NewKey(KeyType{curve, AddrTypeRipemd160Sha256})
+		if err != nil {
+			t.Fatal(err)
+		}
+		k2, err := NewKey(KeyType{curve, AddrTypeRipemd160Sha256})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub1, _ := k1.Pubkey()
+		pub2, _ := k2.Pubkey()
+
+		s1, err := k1.SharedSecret(pub2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s2, err := k2.SharedSecret(pub1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s1) != 32 || !bytes.Equal(s1, s2) {
+			t.Fatalf("Shared secrets (%v) don't match: %X, %X", curve, s1, s2)
+		}
+	}
+}
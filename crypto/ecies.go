@@ -0,0 +1,114 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+)
+
+//-----------------------------------------------------------------------------
+// public key encryption
+//
+// A fresh ephemeral key of the recipient's curve agrees a secret with the
+// recipient's pubkey (see SharedSecret), which HKDF turns into an AES-256-GCM key.
+// The ciphertext is wrapped in a json envelope naming the curve,
+// so the recipient knows how to decrypt it
+
+const EnvelopeVersion = 1
+
+type Envelope struct {
+	Version      int    `json:"version"`
+	Curve        string `json:"curve"`
+	EphemeralPub []byte `json:"ephemeral_pub"`
+	Nonce        []byte `json:"nonce"`
+	CipherText   []byte `json:"ciphertext"`
+}
+
+// the envelope header is bound to the ciphertext as additional data
+func (env *Envelope) header() []byte {
+	return []byte(fmt.Sprintf("eris-keys envelope v%d %s %X", env.Version, env.Curve, env.EphemeralPub))
+}
+
+// Encrypt encrypts the plaintext to the owner of the pubkey and returns the json envelope.
+// The curve must be given: pubkeys of different curves can have the same length
+func Encrypt(curveType CurveType, pub, plainText []byte) ([]byte, error) {
+	switch curveType {
+	case CurveTypeSecp256k1, CurveTypeEd25519:
+	default:
+		return nil, fmt.Errorf("can't encrypt to %v keys", curveType)
+	}
+	if _, err := CanonicalPubkey(curveType, pub); err != nil {
+		return nil, err
+	}
+	ephemeral, err := NewKey(KeyType{curveType, AddrTypeRipemd160Sha256})
+	if err != nil {
+		return nil, err
+	}
+	ephemeralPub, err := ephemeral.Pubkey()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randentropy.GetEntropyCSPRNG(12)
+	if err != nil {
+		return nil, err
+	}
+	env := &Envelope{
+		Version:      EnvelopeVersion,
+		Curve:        curveType.String(),
+		EphemeralPub: ephemeralPub,
+		Nonce:        nonce,
+	}
+
+	gcm, err := envelopeCipher(ephemeral, pub, env)
+	if err != nil {
+		return nil, err
+	}
+	env.CipherText = gcm.Seal(nil, env.Nonce, plainText, env.header())
+	return json.Marshal(env)
+}
+
+// Decrypt opens a json envelope encrypted to the key
+func (k *Key) Decrypt(envelope []byte) ([]byte, error) {
+	env := new(Envelope)
+	if err := json.Unmarshal(envelope, env); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %v", err)
+	}
+	if env.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", env.Version)
+	}
+	if env.Curve != k.Type.CurveType.String() {
+		return nil, fmt.Errorf("envelope is for a %s key, not %s", env.Curve, k.Type.CurveType)
+	}
+
+	gcm, err := envelopeCipher(k, env.EphemeralPub, env)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid envelope nonce")
+	}
+	plainText, err := gcm.Open(nil, env.Nonce, env.CipherText, env.header())
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %v", err)
+	}
+	return plainText, nil
+}
+
+func envelopeCipher(k *Key, peerPub []byte, env *Envelope) (cipher.AEAD, error) {
+	shared, err := k.SharedSecret(peerPub)
+	if err != nil {
+		return nil, err
+	}
+	aesKey, err := HKDF(shared, nil, env.header(), 32)
+	if err != nil {
+		return nil, err
+	}
+	aesBlock, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(aesBlock)
+}
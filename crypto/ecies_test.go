@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	msg := []byte("a secret for the validator")
+	for _, curve := range []CurveType{CurveTypeEd25519, CurveTypeSecp256k1} {
+		key, err := NewKey(KeyType{curve, AddrTypeRipemd160Sha256})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, _ := key.Pubkey()
+
+		envelope, err := Encrypt(curve, pub, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := key.Decrypt(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, msg) {
+			t.Fatalf("Decrypted %q, expected %q", plain, msg)
+		}
+
+		other, _ := NewKey(KeyType{curve, AddrTypeRipemd160Sha256})
+		if _, err := other.Decrypt(envelope); err == nil {
+			t.Fatalf("Expected decryption with the wrong key to fail")
+		}
+
+		env := new(Envelope)
+		json.Unmarshal(envelope, env)
+		env.CipherText[0] ^= 1
+		tampered, _ := json.Marshal(env)
+		if _, err := key.Decrypt(tampered); err == nil {
+			t.Fatalf("Expected tampered envelope to fail")
+		}
+	}
+}
+
+func TestEncryptWrongCurve(t *testing.T) {
+	// a P-256 pubkey is the same length as a secp256k1 one
+	r1, err := NewKey(KeyType{CurveTypeSecp256r1, AddrTypeSha256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := r1.Pubkey()
+	if _, err := Encrypt(CurveTypeSecp256k1, pub, []byte("a secret")); err == nil {
+		t.Fatal("Expected an error encrypting to a secp256r1 pubkey as secp256k1")
+	}
+	if _, err := Encrypt(CurveTypeSecp256r1, pub, []byte("a secret")); err == nil {
+		t.Fatal("Expected an error encrypting to an unsupported curve")
+	}
+	if _, err := Encrypt(CurveTypeEd25519, pub, []byte("a secret")); err == nil {
+		t.Fatal("Expected an error encrypting to a 65 byte pubkey as ed25519")
+	}
+}
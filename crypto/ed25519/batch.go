@@ -0,0 +1,136 @@
+package ed25519
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+
+	"github.com/eris-ltd/eris-keys/crypto/ed25519/edwards25519"
+)
+
+// VerifyBatch verifies many signatures at once, which is significantly faster
+// than verifying them one by one. It checks the random linear combination
+//
+//	sum(z_i*s_i)*B = sum(z_i*R_i) + sum(z_i*h_i*A_i)
+//
+// with 128 bit random odd z_i, so a single signature with a small order
+// component always fails the batch. Signatures with a small order A or R,
+// or that can't be decoded, are left out of the batch and checked with Verify.
+// If the batch fails, each signature is verified with Verify to find the bad ones.
+// Only a batch holding several signatures whose small order components were
+// crafted to cancel out can pass where Verify would reject one of them.
+// It returns true iff all signatures are valid, and the result for each one
+func VerifyBatch(publicKeys []*[PublicKeySize]byte, messages [][]byte, sigs []*[SignatureSize]byte) (bool, []bool) {
+	n := len(sigs)
+	if len(publicKeys) != n || len(messages) != n {
+		panic("ed25519: mismatched batch lengths")
+	}
+	valid := make([]bool, n)
+	if n == 0 {
+		return true, valid
+	}
+
+	batched, ok := verifyBatch(publicKeys, messages, sigs)
+	allValid := true
+	for i := range sigs {
+		if batched[i] && ok {
+			valid[i] = true
+		} else {
+			valid[i] = Verify(publicKeys[i], messages[i], sigs[i])
+		}
+		allValid = allValid && valid[i]
+	}
+	return allValid, valid
+}
+
+// verifyBatch checks the batch equation over the signatures it could batch
+// and reports which ones those were
+func verifyBatch(publicKeys []*[PublicKeySize]byte, messages [][]byte, sigs []*[SignatureSize]byte) ([]bool, bool) {
+	n := len(sigs)
+	batched := make([]bool, n)
+	scalars := make([]*[32]byte, 0, 2*n)
+	points := make([]*edwards25519.ExtendedGroupElement, 0, 2*n)
+	var sSum [32]byte
+
+	zBytes := make([]byte, 16*n)
+	if _, err := rand.Read(zBytes); err != nil {
+		return batched, false
+	}
+
+	for i, sig := range sigs {
+		if sig[63]&224 != 0 {
+			continue
+		}
+
+		// FromBytes returns the negated points -A and -R
+		A, R := new(edwards25519.ExtendedGroupElement), new(edwards25519.ExtendedGroupElement)
+		if !A.FromBytes(publicKeys[i]) || isSmallOrder(A) {
+			continue
+		}
+		var rBytes [32]byte
+		copy(rBytes[:], sig[:32])
+		if !canonical(&rBytes) || !R.FromBytes(&rBytes) || isSmallOrder(R) {
+			continue
+		}
+
+		h := sha512.New()
+		h.Write(sig[:32])
+		h.Write(publicKeys[i][:])
+		h.Write(messages[i])
+		var digest [64]byte
+		h.Sum(digest[:0])
+		var hReduced [32]byte
+		edwards25519.ScReduce(&hReduced, &digest)
+
+		z := new([32]byte)
+		copy(z[:], zBytes[16*i:16*(i+1)])
+		z[0] |= 1
+
+		// z*h and sum(z*s)
+		var zero, s [32]byte
+		zh := new([32]byte)
+		edwards25519.ScMulAdd(zh, z, &hReduced, &zero)
+		copy(s[:], sig[32:])
+		edwards25519.ScMulAdd(&sSum, z, &s, &sSum)
+
+		scalars = append(scalars, z, zh)
+		points = append(points, R, A)
+		batched[i] = true
+	}
+	if len(points) == 0 {
+		return batched, true
+	}
+
+	// sum(z*s)*B - sum(z*R) - sum(z*h*A) must be the identity
+	var check edwards25519.ProjectiveGroupElement
+	edwards25519.GeMultiScalarMultVartime(&check, scalars, points, &sSum)
+	var checkBytes [32]byte
+	check.ToBytes(&checkBytes)
+	return batched, checkBytes == identityBytes
+}
+
+// canonical reports whether the encoded y coordinate is less than 2^255 - 19
+func canonical(p *[32]byte) bool {
+	if p[31]&0x7f != 0x7f {
+		return true
+	}
+	for i := 30; i > 0; i-- {
+		if p[i] != 0xff {
+			return true
+		}
+	}
+	return p[0] < 0xed
+}
+
+// isSmallOrder reports whether 8*p is the identity
+func isSmallOrder(p *edwards25519.ExtendedGroupElement) bool {
+	var t edwards25519.CompletedGroupElement
+	var q edwards25519.ProjectiveGroupElement
+	p.ToProjective(&q)
+	for i := 0; i < 3; i++ {
+		q.Double(&t)
+		t.ToProjective(&q)
+	}
+	var b [32]byte
+	q.ToBytes(&b)
+	return b == identityBytes
+}
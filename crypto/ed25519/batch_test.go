@@ -0,0 +1,178 @@
+package ed25519
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"testing"
+
+	"github.com/eris-ltd/eris-keys/crypto/ed25519/edwards25519"
+)
+
+func batch(n int) ([]*[PublicKeySize]byte, [][]byte, []*[SignatureSize]byte) {
+	pubs := make([]*[PublicKeySize]byte, n)
+	msgs := make([][]byte, n)
+	sigs := make([]*[SignatureSize]byte, n)
+	for i := 0; i < n; i++ {
+		pub, priv, _ := GenerateKey(rand.Reader)
+		pubs[i] = pub
+		msgs[i] = []byte(fmt.Sprintf("message %d", i))
+		sigs[i] = Sign(priv, msgs[i])
+	}
+	return pubs, msgs, sigs
+}
+
+func TestVerifyBatch(t *testing.T) {
+	pubs, msgs, sigs := batch(64)
+
+	// the batch equation itself must hold, not just the fallback
+	if _, ok := verifyBatch(pubs, msgs, sigs); !ok {
+		t.Fatal("valid batch failed the batch equation")
+	}
+	ok, valid := VerifyBatch(pubs, msgs, sigs)
+	if !ok {
+		t.Fatal("valid batch rejected")
+	}
+	for i, v := range valid {
+		if !v {
+			t.Fatalf("valid signature %d rejected", i)
+		}
+	}
+
+	msgs[17] = []byte("wrong message")
+	ok, valid = VerifyBatch(pubs, msgs, sigs)
+	if ok {
+		t.Fatal("batch with a bad signature accepted")
+	}
+	for i, v := range valid {
+		if v == (i == 17) {
+			t.Fatalf("wrong result %v for signature %d", v, i)
+		}
+	}
+}
+
+// a point of order 8
+var torsionPoint = [32]byte{
+	0x26, 0xe8, 0x95, 0x8f, 0xc2, 0xb2, 0x27, 0xb0, 0x45, 0xc3, 0xf4, 0x89, 0xf2, 0xef, 0x98, 0xf0,
+	0xd5, 0xdf, 0xac, 0x05, 0xd3, 0xc6, 0x33, 0x39, 0xb1, 0x38, 0x02, 0x88, 0x6d, 0x53, 0xfc, 0x05,
+}
+
+// addTorsion returns the encoding of P + T for T of order 8
+func addTorsion(t *testing.T, p [32]byte) [32]byte {
+	// FromBytes returns the negated points
+	P, T := new(edwards25519.ExtendedGroupElement), new(edwards25519.ExtendedGroupElement)
+	torsion := torsionPoint
+	if !P.FromBytes(&p) || !T.FromBytes(&torsion) {
+		t.Fatal("invalid point")
+	}
+	var one, zero [32]byte
+	one[0] = 1
+	var r edwards25519.ProjectiveGroupElement
+	edwards25519.GeMultiScalarMultVartime(&r, []*[32]byte{&one, &one}, []*edwards25519.ExtendedGroupElement{P, T}, &zero)
+	var out [32]byte
+	r.ToBytes(&out)
+	out[31] ^= 0x80
+	return out
+}
+
+// signTorsion signs like Sign, but with R + T as the R of the signature
+func signTorsion(t *testing.T, privateKey *[PrivateKeySize]byte, message []byte) *[SignatureSize]byte {
+	digest := sha512.Sum512(privateKey[:32])
+	var a [32]byte
+	copy(a[:], digest[:])
+	a[0] &= 248
+	a[31] &= 63
+	a[31] |= 64
+
+	var rDigest [64]byte
+	rand.Read(rDigest[:])
+	var r [32]byte
+	edwards25519.ScReduce(&r, &rDigest)
+	var R edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&R, &r)
+	var rBytes [32]byte
+	R.ToBytes(&rBytes)
+	rBytes = addTorsion(t, rBytes)
+
+	h := sha512.New()
+	h.Write(rBytes[:])
+	h.Write(privateKey[32:])
+	h.Write(message)
+	var hDigest [64]byte
+	h.Sum(hDigest[:0])
+	var hReduced [32]byte
+	edwards25519.ScReduce(&hReduced, &hDigest)
+
+	sig := new([SignatureSize]byte)
+	copy(sig[:], rBytes[:])
+	var s [32]byte
+	edwards25519.ScMulAdd(&s, &hReduced, &a, &r)
+	copy(sig[32:], s[:])
+	return sig
+}
+
+func TestVerifyBatchTorsion(t *testing.T) {
+	pubs, msgs, sigs := batch(4)
+
+	// s*B = R + h*A only up to a small order component of R, which Verify
+	// rejects, so the batch must reject it every time too
+	pub, priv, _ := GenerateKey(rand.Reader)
+	pubs[2], sigs[2] = pub, signTorsion(t, priv, msgs[2])
+	if Verify(pubs[2], msgs[2], sigs[2]) {
+		t.Fatal("Verify accepted a torsion tweaked R")
+	}
+	for i := 0; i < 64; i++ {
+		if batched, ok := verifyBatch(pubs, msgs, sigs); ok || !batched[2] {
+			t.Fatal("batch equation accepted a torsion tweaked R")
+		}
+		ok, valid := VerifyBatch(pubs, msgs, sigs)
+		if ok || valid[2] || !valid[0] || !valid[1] || !valid[3] {
+			t.Fatalf("wrong batch result %v %v", ok, valid)
+		}
+	}
+
+	// the identity as A and R with s = 0 satisfies Verify, and a
+	// non-canonical encoding of the identity as R doesn't. Both are
+	// left out of the batch, which must agree with Verify on them
+	pubs, msgs, sigs = batch(3)
+	var identity [32]byte
+	identity[0] = 1
+	*pubs[1], *pubs[2] = identity, identity
+	sigs[1], sigs[2] = new([SignatureSize]byte), new([SignatureSize]byte)
+	sigs[1][0] = 1
+	sigs[2][0] = 0xee
+	for i := 1; i < 31; i++ {
+		sigs[2][i] = 0xff
+	}
+	sigs[2][31] = 0x7f
+	if !Verify(pubs[1], msgs[1], sigs[1]) {
+		t.Fatal("Verify rejected the identity")
+	}
+	if Verify(pubs[2], msgs[2], sigs[2]) {
+		t.Fatal("Verify accepted a non-canonical R")
+	}
+	if batched, ok := verifyBatch(pubs, msgs, sigs); !ok || !batched[0] || batched[1] || batched[2] {
+		t.Fatalf("wrong batch equation result %v %v", ok, batched)
+	}
+	if ok, valid := VerifyBatch(pubs, msgs, sigs); ok || !valid[0] || !valid[1] || valid[2] {
+		t.Fatalf("wrong batch result %v %v", ok, valid)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	pubs, msgs, sigs := batch(64)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range sigs {
+			Verify(pubs[i], msgs[i], sigs[i])
+		}
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	pubs, msgs, sigs := batch(64)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		VerifyBatch(pubs, msgs, sigs)
+	}
+}
@@ -0,0 +1,74 @@
+package edwards25519
+
+// GeMultiScalarMultVartime sets r = sum(a[i]*A[i]) + b*B
+// where B is the Ed25519 base point. It interleaves the doublings
+// of all the multiplications (Straus' method), so is much faster than
+// computing each product with GeDoubleScalarMultVartime.
+func GeMultiScalarMultVartime(r *ProjectiveGroupElement, a []*[32]byte, A []*ExtendedGroupElement, b *[32]byte) {
+	if len(a) != len(A) {
+		panic("edwards25519: mismatched scalars and points")
+	}
+
+	aSlide := make([][256]int8, len(a))
+	Ai := make([][8]CachedGroupElement, len(A)) // A,3A,5A,7A,9A,11A,13A,15A
+	var bSlide [256]int8
+	var t CompletedGroupElement
+	var u, A2 ExtendedGroupElement
+
+	for j := range a {
+		slide(&aSlide[j], a[j])
+
+		A[j].ToCached(&Ai[j][0])
+		A[j].Double(&t)
+		t.ToExtended(&A2)
+		for i := 0; i < 7; i++ {
+			geAdd(&t, &A2, &Ai[j][i])
+			t.ToExtended(&u)
+			u.ToCached(&Ai[j][i+1])
+		}
+	}
+	slide(&bSlide, b)
+
+	r.Zero()
+
+	i := 255
+	for ; i >= 0; i-- {
+		if bSlide[i] != 0 {
+			break
+		}
+		nonZero := false
+		for j := range aSlide {
+			if aSlide[j][i] != 0 {
+				nonZero = true
+				break
+			}
+		}
+		if nonZero {
+			break
+		}
+	}
+
+	for ; i >= 0; i-- {
+		r.Double(&t)
+
+		for j := range aSlide {
+			if aSlide[j][i] > 0 {
+				t.ToExtended(&u)
+				geAdd(&t, &u, &Ai[j][aSlide[j][i]/2])
+			} else if aSlide[j][i] < 0 {
+				t.ToExtended(&u)
+				geSub(&t, &u, &Ai[j][(-aSlide[j][i])/2])
+			}
+		}
+
+		if bSlide[i] > 0 {
+			t.ToExtended(&u)
+			geMixedAdd(&t, &u, &bi[bSlide[i]/2])
+		} else if bSlide[i] < 0 {
+			t.ToExtended(&u)
+			geMixedSub(&t, &u, &bi[(-bSlide[i])/2])
+		}
+
+		t.ToProjective(r)
+	}
+}
@@ -0,0 +1,609 @@
+package ed25519
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/eris-ltd/eris-keys/crypto/ed25519/edwards25519"
+)
+
+// FROST(Ed25519, SHA-512) threshold signatures, as in RFC 9591.
+//
+// A group of n participants runs a distributed key generation (Pedersen's,
+// with a proof of knowledge of each participant's secret) after which each
+// holds a share of a group private key that never exists in one place.
+// Any threshold of them can then make an ordinary ed25519 signature
+// for the group pubkey in two rounds: everyone commits to a pair of nonces,
+// then everyone signs given all the commitments. The signature shares
+// add up to the signature.
+//
+// Participants are identified by non-zero ids, which are the points
+// their shares of the secret polynomial are evaluated at.
+
+const frostContext = "FROST-ED25519-SHA512-v1"
+
+// the group order L, little endian
+var (
+	groupOrder, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+	scZero, scOne scalar
+	scMinusOne    scalar
+
+	identityBytes = [32]byte{1}
+)
+
+func init() {
+	scOne[0] = 1
+	scMinusOne = scalarFromBig(new(big.Int).Sub(groupOrder, big.NewInt(1)))
+}
+
+//-----------------------------------------------------------------------------
+// scalars and points
+
+type scalar [32]byte
+
+func scalarFromBig(n *big.Int) (s scalar) {
+	b := n.Bytes()
+	for i := range b {
+		s[i] = b[len(b)-1-i]
+	}
+	return s
+}
+
+func (s *scalar) big() *big.Int {
+	var b [32]byte
+	for i := range s {
+		b[i] = s[31-i]
+	}
+	return new(big.Int).SetBytes(b[:])
+}
+
+func scalarFromID(id uint16) (s scalar) {
+	binary.LittleEndian.PutUint16(s[:], id)
+	return s
+}
+
+// decodeScalar returns an error if b isn't a canonical 32 byte scalar
+func decodeScalar(b []byte) (s scalar, err error) {
+	if len(b) != 32 {
+		return s, errors.New("scalar must be 32 bytes")
+	}
+	copy(s[:], b)
+	if s.big().Cmp(groupOrder) >= 0 {
+		return s, errors.New("scalar is not reduced")
+	}
+	return s, nil
+}
+
+func scAdd(a, b *scalar) (s scalar) {
+	edwards25519.ScMulAdd((*[32]byte)(&s), (*[32]byte)(&scOne), (*[32]byte)(a), (*[32]byte)(b))
+	return s
+}
+
+func scMul(a, b *scalar) (s scalar) {
+	edwards25519.ScMulAdd((*[32]byte)(&s), (*[32]byte)(a), (*[32]byte)(b), (*[32]byte)(&scZero))
+	return s
+}
+
+func scNeg(a *scalar) scalar {
+	return scMul(&scMinusOne, a)
+}
+
+func hashToScalar(data ...[]byte) (s scalar) {
+	h := sha512.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	var digest [64]byte
+	h.Sum(digest[:0])
+	edwards25519.ScReduce((*[32]byte)(&s), &digest)
+	return s
+}
+
+func randomScalar(rand io.Reader) (s scalar, err error) {
+	var b [64]byte
+	if _, err := io.ReadFull(rand, b[:]); err != nil {
+		return s, err
+	}
+	edwards25519.ScReduce((*[32]byte)(&s), &b)
+	return s, nil
+}
+
+func scalarBaseMult(s *scalar) []byte {
+	var p edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&p, (*[32]byte)(s))
+	var b [32]byte
+	p.ToBytes(&b)
+	return b[:]
+}
+
+// decodePoint decodes a canonically encoded point
+func decodePoint(b []byte) (*edwards25519.ExtendedGroupElement, error) {
+	if len(b) != 32 {
+		return nil, errors.New("point must be 32 bytes")
+	}
+	var enc [32]byte
+	copy(enc[:], b)
+	p := new(edwards25519.ExtendedGroupElement)
+	if !p.FromBytes(&enc) {
+		return nil, errors.New("invalid point")
+	}
+	// FromBytes decodes the negated point
+	edwards25519.FeNeg(&p.X, &p.X)
+	edwards25519.FeNeg(&p.T, &p.T)
+	var check [32]byte
+	p.ToBytes(&check)
+	if check != enc {
+		return nil, errors.New("point is not canonically encoded")
+	}
+	return p, nil
+}
+
+// linearCombination returns sum(scalars[i]*points[i]) + b*B, encoded
+func linearCombination(scalars []scalar, points [][]byte, b *scalar) ([]byte, error) {
+	a := make([]*[32]byte, len(scalars))
+	A := make([]*edwards25519.ExtendedGroupElement, len(points))
+	for i := range points {
+		p, err := decodePoint(points[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i], A[i] = (*[32]byte)(&scalars[i]), p
+	}
+	var r edwards25519.ProjectiveGroupElement
+	edwards25519.GeMultiScalarMultVartime(&r, a, A, (*[32]byte)(b))
+	var enc [32]byte
+	r.ToBytes(&enc)
+	return enc[:], nil
+}
+
+// evalCommitments returns sum(C_k * x^k), the public image of a polynomial at x
+func evalCommitments(commitments [][]byte, id uint16) ([]byte, error) {
+	x := scalarFromID(id)
+	powers := make([]scalar, len(commitments))
+	powers[0] = scOne
+	for k := 1; k < len(powers); k++ {
+		powers[k] = scMul(&powers[k-1], &x)
+	}
+	return linearCombination(powers, commitments, &scZero)
+}
+
+// lagrange returns the coefficient of id's share when interpolating the ids at zero
+func lagrange(id uint16, ids []uint16) scalar {
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, j := range ids {
+		if j == id {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		den.Mul(den, big.NewInt(int64(j)-int64(id)))
+	}
+	num.Mod(num, groupOrder)
+	den.Mod(den, groupOrder)
+	num.Mul(num, den.ModInverse(den, groupOrder))
+	return scalarFromBig(num.Mod(num, groupOrder))
+}
+
+func idBytes(id uint16) []byte {
+	s := scalarFromID(id)
+	return s[:]
+}
+
+//-----------------------------------------------------------------------------
+// distributed key generation
+
+// DKGCommitment is a participant's public round 1 message:
+// commitments to the coefficients of its secret polynomial
+// and a proof of knowledge of the constant term
+type DKGCommitment struct {
+	ID          uint16   `json:"id"`
+	Commitments [][]byte `json:"commitments"`
+	ProofR      []byte   `json:"proof_r"`
+	ProofZ      []byte   `json:"proof_z"`
+}
+
+// DKGSecret is a participant's secret state between the rounds of the DKG
+type DKGSecret struct {
+	ID           uint16
+	Threshold    int
+	coefficients []scalar
+	commitment   *DKGCommitment
+}
+
+func dkgChallenge(id uint16, c0, r []byte) scalar {
+	return hashToScalar([]byte(frostContext), []byte("dkg"), idBytes(id), c0, r)
+}
+
+// DKGRound1 picks the participant's secret polynomial of degree threshold-1
+// and returns the commitment to broadcast to the others
+func DKGRound1(id uint16, threshold int, rand io.Reader) (*DKGSecret, *DKGCommitment, error) {
+	if id == 0 {
+		return nil, nil, errors.New("participant id must be non-zero")
+	}
+	if threshold < 1 {
+		return nil, nil, errors.New("threshold must be at least 1")
+	}
+	s := &DKGSecret{ID: id, Threshold: threshold}
+	c := &DKGCommitment{ID: id}
+	for k := 0; k < threshold; k++ {
+		a, err := randomScalar(rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.coefficients = append(s.coefficients, a)
+		c.Commitments = append(c.Commitments, scalarBaseMult(&a))
+	}
+
+	// schnorr proof of knowledge of the constant term
+	k, err := randomScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.ProofR = scalarBaseMult(&k)
+	e := dkgChallenge(id, c.Commitments[0], c.ProofR)
+	z := scMul(&s.coefficients[0], &e)
+	z = scAdd(&z, &k)
+	c.ProofZ = z[:]
+
+	s.commitment = c
+	return s, c, nil
+}
+
+// VerifyDKGCommitment checks a round 1 commitment has threshold coefficients
+// and a valid proof of knowledge
+func VerifyDKGCommitment(c *DKGCommitment, threshold int) error {
+	if c.ID == 0 {
+		return errors.New("participant id must be non-zero")
+	}
+	if len(c.Commitments) != threshold {
+		return fmt.Errorf("participant %d committed to %d coefficients, expected %d", c.ID, len(c.Commitments), threshold)
+	}
+	z, err := decodeScalar(c.ProofZ)
+	if err != nil {
+		return fmt.Errorf("participant %d: %v", c.ID, err)
+	}
+	// z*B - e*C_0 must be R
+	e := dkgChallenge(c.ID, c.Commitments[0], c.ProofR)
+	r, err := linearCombination([]scalar{scNeg(&e)}, c.Commitments[:1], &z)
+	if err != nil {
+		return fmt.Errorf("participant %d: %v", c.ID, err)
+	}
+	if !bytes.Equal(r, c.ProofR) {
+		return fmt.Errorf("participant %d: invalid proof of knowledge", c.ID)
+	}
+	return nil
+}
+
+// Share returns the secret share for participant id: the polynomial evaluated at id.
+// It must only be sent to that participant
+func (s *DKGSecret) Share(id uint16) []byte {
+	x := scalarFromID(id)
+	var acc scalar
+	for k := len(s.coefficients) - 1; k >= 0; k-- {
+		acc = scMul(&acc, &x)
+		acc = scAdd(&acc, &s.coefficients[k])
+	}
+	return acc[:]
+}
+
+// KeyShare is a participant's share of the group key
+type KeyShare struct {
+	ID        uint16 `json:"id"`
+	Threshold int    `json:"threshold"`
+	Secret    []byte `json:"secret"`
+	GroupPub  []byte `json:"group_pub"`
+
+	// every participant's public share, for checking their signature shares
+	PubShares map[uint16][]byte `json:"pub_shares"`
+}
+
+// DKGFinish checks the others' commitments and the shares they sent,
+// and combines the shares into the participant's share of the group key.
+// commitments are everyone's round 1 messages, including our own,
+// and shares are the shares sent to us by id
+func DKGFinish(s *DKGSecret, commitments []*DKGCommitment, shares map[uint16][]byte) (*KeyShare, error) {
+	ids := make(map[uint16]bool)
+	var constants [][]byte
+	for _, c := range commitments {
+		if ids[c.ID] {
+			return nil, fmt.Errorf("participant %d is given twice", c.ID)
+		}
+		ids[c.ID] = true
+		if err := VerifyDKGCommitment(c, s.Threshold); err != nil {
+			return nil, err
+		}
+		constants = append(constants, c.Commitments[0])
+	}
+	if !ids[s.ID] {
+		return nil, errors.New("our own commitment is missing")
+	}
+	if len(ids) < s.Threshold {
+		return nil, fmt.Errorf("%d participants is fewer than the threshold of %d", len(ids), s.Threshold)
+	}
+
+	secret, _ := decodeScalar(s.Share(s.ID))
+	for _, c := range commitments {
+		if c.ID == s.ID {
+			if !bytes.Equal(c.Commitments[0], s.commitment.Commitments[0]) {
+				return nil, errors.New("our own commitment was altered")
+			}
+			continue
+		}
+		share, ok := shares[c.ID]
+		if !ok {
+			return nil, fmt.Errorf("missing share from participant %d", c.ID)
+		}
+		sh, err := decodeScalar(share)
+		if err != nil {
+			return nil, fmt.Errorf("share from participant %d: %v", c.ID, err)
+		}
+		expected, err := evalCommitments(c.Commitments, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(scalarBaseMult(&sh), expected) {
+			return nil, fmt.Errorf("share from participant %d doesn't match its commitment", c.ID)
+		}
+		secret = scAdd(&secret, &sh)
+	}
+
+	ones := make([]scalar, len(constants))
+	for i := range ones {
+		ones[i] = scOne
+	}
+	groupPub, err := linearCombination(ones, constants, &scZero)
+	if err != nil {
+		return nil, err
+	}
+
+	// participant l's public share is the sum of every polynomial's image at l
+	pubShares := make(map[uint16][]byte)
+	for l := range ids {
+		var images [][]byte
+		for _, c := range commitments {
+			image, err := evalCommitments(c.Commitments, l)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, image)
+		}
+		if pubShares[l], err = linearCombination(ones, images, &scZero); err != nil {
+			return nil, err
+		}
+	}
+	if !bytes.Equal(pubShares[s.ID], scalarBaseMult(&secret)) {
+		return nil, errors.New("our share doesn't match the group's commitments")
+	}
+
+	return &KeyShare{
+		ID:        s.ID,
+		Threshold: s.Threshold,
+		Secret:    secret[:],
+		GroupPub:  groupPub,
+		PubShares: pubShares,
+	}, nil
+}
+
+//-----------------------------------------------------------------------------
+// signing
+
+// SigningCommitment is a participant's public round 1 message for a signature
+type SigningCommitment struct {
+	ID      uint16 `json:"id"`
+	Hiding  []byte `json:"hiding"`
+	Binding []byte `json:"binding"`
+}
+
+// SigningNonces are the secret nonces behind a commitment.
+// They must be used for at most one signature
+type SigningNonces struct {
+	hiding, binding scalar
+	Commitment      *SigningCommitment
+}
+
+// SignatureShare is a participant's round 2 message
+type SignatureShare struct {
+	ID uint16 `json:"id"`
+	Z  []byte `json:"z"`
+}
+
+func (k *KeyShare) nonce(rand io.Reader) (scalar, error) {
+	var b [32]byte
+	if _, err := io.ReadFull(rand, b[:]); err != nil {
+		return scZero, err
+	}
+	return hashToScalar([]byte(frostContext), []byte("nonce"), b[:], k.Secret), nil
+}
+
+// Commit picks the nonces for one signature and returns them with their commitment
+func (k *KeyShare) Commit(rand io.Reader) (*SigningNonces, error) {
+	hiding, err := k.nonce(rand)
+	if err != nil {
+		return nil, err
+	}
+	binding, err := k.nonce(rand)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningNonces{
+		hiding:  hiding,
+		binding: binding,
+		Commitment: &SigningCommitment{
+			ID:      k.ID,
+			Hiding:  scalarBaseMult(&hiding),
+			Binding: scalarBaseMult(&binding),
+		},
+	}, nil
+}
+
+// signingSession is what every signer derives from the commitments
+type signingSession struct {
+	ids         []uint16
+	commitments map[uint16]*SigningCommitment
+	rhos        map[uint16]scalar
+	r           []byte // the group commitment
+	challenge   scalar
+}
+
+func newSigningSession(groupPub, msg []byte, commitments []*SigningCommitment) (*signingSession, error) {
+	ss := &signingSession{
+		commitments: make(map[uint16]*SigningCommitment),
+		rhos:        make(map[uint16]scalar),
+	}
+	for _, c := range commitments {
+		if c.ID == 0 {
+			return nil, errors.New("participant id must be non-zero")
+		}
+		if _, ok := ss.commitments[c.ID]; ok {
+			return nil, fmt.Errorf("participant %d is given twice", c.ID)
+		}
+		ss.commitments[c.ID] = c
+		ss.ids = append(ss.ids, c.ID)
+	}
+	sort.Slice(ss.ids, func(i, j int) bool { return ss.ids[i] < ss.ids[j] })
+
+	var encoded []byte
+	for _, id := range ss.ids {
+		c := ss.commitments[id]
+		encoded = append(encoded, idBytes(id)...)
+		encoded = append(encoded, c.Hiding...)
+		encoded = append(encoded, c.Binding...)
+	}
+	msgHash := sha512.Sum512(append([]byte(frostContext+"msg"), msg...))
+	comHash := sha512.Sum512(append([]byte(frostContext+"com"), encoded...))
+
+	var scalars []scalar
+	var points [][]byte
+	for _, id := range ss.ids {
+		rho := hashToScalar([]byte(frostContext+"rho"), groupPub, msgHash[:], comHash[:], idBytes(id))
+		ss.rhos[id] = rho
+		scalars = append(scalars, scOne, rho)
+		points = append(points, ss.commitments[id].Hiding, ss.commitments[id].Binding)
+	}
+	r, err := linearCombination(scalars, points, &scZero)
+	if err != nil {
+		return nil, err
+	}
+	ss.r = r
+	ss.challenge = hashToScalar(r, groupPub, msg)
+	return ss, nil
+}
+
+// Sign makes the participant's signature share of msg, given the commitments
+// of every signer, including its own. The nonces must not be used again
+func (k *KeyShare) Sign(nonces *SigningNonces, msg []byte, commitments []*SigningCommitment) (*SignatureShare, error) {
+	if len(commitments) < k.Threshold {
+		return nil, fmt.Errorf("%d signers is fewer than the threshold of %d", len(commitments), k.Threshold)
+	}
+	ss, err := newSigningSession(k.GroupPub, msg, commitments)
+	if err != nil {
+		return nil, err
+	}
+	own, ok := ss.commitments[k.ID]
+	if !ok {
+		return nil, errors.New("our commitment is missing")
+	}
+	if !bytes.Equal(own.Hiding, nonces.Commitment.Hiding) || !bytes.Equal(own.Binding, nonces.Commitment.Binding) {
+		return nil, errors.New("our commitment doesn't match our nonces")
+	}
+	secret, err := decodeScalar(k.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	// z = d + e*rho + lambda*s*c
+	lambda := lagrange(k.ID, ss.ids)
+	rho := ss.rhos[k.ID]
+	z := scMul(&lambda, &secret)
+	z = scMul(&z, &ss.challenge)
+	eRho := scMul(&nonces.binding, &rho)
+	z = scAdd(&z, &eRho)
+	z = scAdd(&z, &nonces.hiding)
+	return &SignatureShare{ID: k.ID, Z: z[:]}, nil
+}
+
+// VerifySignatureShare checks a participant's signature share against its public share
+func VerifySignatureShare(groupPub, msg []byte, commitments []*SigningCommitment, share *SignatureShare, pubShare []byte) error {
+	ss, err := newSigningSession(groupPub, msg, commitments)
+	if err != nil {
+		return err
+	}
+	return ss.verifyShare(share, pubShare)
+}
+
+func (ss *signingSession) verifyShare(share *SignatureShare, pubShare []byte) error {
+	c, ok := ss.commitments[share.ID]
+	if !ok {
+		return fmt.Errorf("participant %d has no commitment", share.ID)
+	}
+	z, err := decodeScalar(share.Z)
+	if err != nil {
+		return fmt.Errorf("participant %d: %v", share.ID, err)
+	}
+	// z*B - D - rho*E - c*lambda*Y must be the identity
+	lambda := lagrange(share.ID, ss.ids)
+	cl := scMul(&ss.challenge, &lambda)
+	rho := ss.rhos[share.ID]
+	check, err := linearCombination(
+		[]scalar{scMinusOne, scNeg(&rho), scNeg(&cl)},
+		[][]byte{c.Hiding, c.Binding, pubShare},
+		&z,
+	)
+	if err != nil {
+		return fmt.Errorf("participant %d: %v", share.ID, err)
+	}
+	if !bytes.Equal(check, identityBytes[:]) {
+		return fmt.Errorf("participant %d made an invalid signature share", share.ID)
+	}
+	return nil
+}
+
+// Aggregate adds up the signature shares into an ed25519 signature for the group pubkey.
+// If the signature is invalid, the shares are checked against pubShares to find the culprits
+func Aggregate(groupPub, msg []byte, commitments []*SigningCommitment, shares []*SignatureShare, pubShares map[uint16][]byte) ([]byte, error) {
+	ss, err := newSigningSession(groupPub, msg, commitments)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) != len(ss.ids) {
+		return nil, fmt.Errorf("got %d signature shares for %d commitments", len(shares), len(ss.ids))
+	}
+	var z scalar
+	seen := make(map[uint16]bool)
+	for _, share := range shares {
+		if _, ok := ss.commitments[share.ID]; !ok || seen[share.ID] {
+			return nil, fmt.Errorf("unexpected signature share from participant %d", share.ID)
+		}
+		seen[share.ID] = true
+		zi, err := decodeScalar(share.Z)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d: %v", share.ID, err)
+		}
+		z = scAdd(&z, &zi)
+	}
+	sig := append(append([]byte{}, ss.r...), z[:]...)
+
+	var pub [PublicKeySize]byte
+	var sigA [SignatureSize]byte
+	copy(pub[:], groupPub)
+	copy(sigA[:], sig)
+	if len(groupPub) == PublicKeySize && Verify(&pub, msg, &sigA) {
+		return sig, nil
+	}
+	for _, share := range shares {
+		pubShare, ok := pubShares[share.ID]
+		if !ok {
+			return nil, fmt.Errorf("no public share for participant %d", share.ID)
+		}
+		if err := ss.verifyShare(share, pubShare); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("invalid signature")
+}
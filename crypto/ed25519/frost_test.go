@@ -0,0 +1,240 @@
+package ed25519
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// runDKG runs the key generation for participants 1..n and returns their key shares
+func runDKG(t *testing.T, threshold, n int) []*KeyShare {
+	secrets := make([]*DKGSecret, n)
+	commitments := make([]*DKGCommitment, n)
+	for i := range secrets {
+		var err error
+		if secrets[i], commitments[i], err = DKGRound1(uint16(i+1), threshold, rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys := make([]*KeyShare, n)
+	for i, s := range secrets {
+		shares := make(map[uint16][]byte)
+		for _, from := range secrets {
+			if from.ID != s.ID {
+				shares[from.ID] = from.Share(s.ID)
+			}
+		}
+		var err error
+		if keys[i], err = DKGFinish(s, commitments, shares); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(keys[i].GroupPub, keys[0].GroupPub) {
+			t.Fatalf("participant %d got a different group pubkey", s.ID)
+		}
+	}
+	return keys
+}
+
+// frostSign runs both signing rounds with the signers and returns the shares
+func frostSign(t *testing.T, signers []*KeyShare, msg []byte) ([]*SigningCommitment, []*SignatureShare) {
+	nonces := make([]*SigningNonces, len(signers))
+	commitments := make([]*SigningCommitment, len(signers))
+	for i, k := range signers {
+		var err error
+		if nonces[i], err = k.Commit(rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+		commitments[i] = nonces[i].Commitment
+	}
+	shares := make([]*SignatureShare, len(signers))
+	for i, k := range signers {
+		var err error
+		if shares[i], err = k.Sign(nonces[i], msg, commitments); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return commitments, shares
+}
+
+func TestFROST(t *testing.T) {
+	keys := runDKG(t, 2, 3)
+	groupPub, pubShares := keys[0].GroupPub, keys[0].PubShares
+	var pub [PublicKeySize]byte
+	copy(pub[:], groupPub)
+
+	msg := []byte("the treasury pays the bills")
+	for _, signers := range [][]*KeyShare{keys[:2], keys[1:], {keys[2], keys[0]}, keys} {
+		commitments, shares := frostSign(t, signers, msg)
+		for _, share := range shares {
+			if err := VerifySignatureShare(groupPub, msg, commitments, share, pubShares[share.ID]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sig, err := Aggregate(groupPub, msg, commitments, shares, pubShares)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sigA [SignatureSize]byte
+		copy(sigA[:], sig)
+		if !Verify(&pub, msg, &sigA) {
+			t.Fatal("Aggregate signature failed to verify as ed25519")
+		}
+	}
+
+	// too few signers
+	nonces, _ := keys[0].Commit(rand.Reader)
+	if _, err := keys[0].Sign(nonces, msg, []*SigningCommitment{nonces.Commitment}); err == nil {
+		t.Fatal("Expected an error signing with fewer than the threshold")
+	}
+
+	// a bad share is blamed on its signer
+	commitments, shares := frostSign(t, keys[:2], msg)
+	shares[1].Z[0] ^= 1
+	if _, err := Aggregate(groupPub, msg, commitments, shares, pubShares); err == nil {
+		t.Fatal("Expected an error for a bad signature share")
+	} else if err.Error() != "participant 2 made an invalid signature share" {
+		t.Fatalf("Expected participant 2 to be blamed, got %v", err)
+	}
+}
+
+func TestDKGBadShare(t *testing.T) {
+	s1, c1, _ := DKGRound1(1, 2, rand.Reader)
+	s2, c2, _ := DKGRound1(2, 2, rand.Reader)
+	commitments := []*DKGCommitment{c1, c2}
+
+	bad := s2.Share(3)
+	if _, err := DKGFinish(s1, commitments, map[uint16][]byte{2: bad}); err == nil {
+		t.Fatal("Expected an error for a share that doesn't match its commitment")
+	}
+
+	c2.ProofZ = c1.ProofZ
+	if _, err := DKGFinish(s1, commitments, map[uint16][]byte{2: s2.Share(1)}); err == nil {
+		t.Fatal("Expected an error for an invalid proof of knowledge")
+	}
+}
+
+func TestLagrange(t *testing.T) {
+	// a degree 1 polynomial through any two of its points
+	s, _, _ := DKGRound1(1, 2, rand.Reader)
+	ids := []uint16{2, 5}
+	var sum scalar
+	for _, id := range ids {
+		share, _ := decodeScalar(s.Share(id))
+		l := lagrange(id, ids)
+		term := scMul(&l, &share)
+		sum = scAdd(&sum, &term)
+	}
+	if sum != s.coefficients[0] {
+		t.Fatal("Interpolated the wrong secret")
+	}
+}
+
+func frostHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// RFC 9591 appendix E.1, FROST(Ed25519, SHA-512) with participants 1 and 3 of 3 signing
+func TestFROSTRFC9591(t *testing.T) {
+	var groupSecret, coefficient scalar
+	copy(groupSecret[:], frostHex("7b1c33d3f5291d85de664833beb1ad469f7fb6025a0ec78b3a790c6e13a98304"))
+	copy(coefficient[:], frostHex("178199860edd8c62f5212ee91eff1295d0d670ab4ed4506866bae57e7030b204"))
+	groupPub := frostHex("15d21ccd7ee42959562fc8aa63224c8851fb3ec85a3faf66040d380fb9738673")
+	msg := frostHex("74657374")
+	if !bytes.Equal(scalarBaseMult(&groupSecret), groupPub) {
+		t.Fatal("Wrong group public key")
+	}
+
+	// the trusted dealer's polynomial, f(x) = secret + coefficient*x
+	dealer := &DKGSecret{coefficients: []scalar{groupSecret, coefficient}}
+	for id, share := range map[uint16]string{
+		1: "929dcc590407aae7d388761cddb0c0db6f5627aea8e217f4a033f2ec83d93509",
+		2: "a91e66e012e4364ac9aaa405fcafd370402d9859f7b6685c07eed76bf409e80d",
+		3: "d3cb090a075eb154e82fdb4b3cb507f110040905468bb9c46da8bdea643a9a02",
+	} {
+		if got := dealer.Share(id); !bytes.Equal(got, frostHex(share)) {
+			t.Fatalf("participant %d: got share %x, expected %s", id, got, share)
+		}
+	}
+
+	signers := []struct {
+		id                                       uint16
+		hidingRand, bindingRand, hiding, binding string
+		hidingCommit, bindingCommit, rho, z      string
+	}{
+		{
+			1,
+			"0fd2e39e111cdc266f6c0f4d0fd45c947761f1f5d3cb583dfcb9bbaf8d4c9fec",
+			"69cd85f631d5f7f2721ed5e40519b1366f340a87c2f6856363dbdcda348a7501",
+			"812d6104142944d5a55924de6d49940956206909f2acaeedecda2b726e630407",
+			"b1110165fc2334149750b28dd813a39244f315cff14d4e89e6142f262ed83301",
+			"b5aa8ab305882a6fc69cbee9327e5a45e54c08af61ae77cb8207be3d2ce13de3",
+			"67e98ab55aa310c3120418e5050c9cf76cf387cb20ac9e4b6fdb6f82a469f932",
+			"f2cb9d7dd9beff688da6fcc83fa89046b3479417f47f55600b106760eb3b5603",
+			"001719ab5a53ee1a12095cd088fd149702c0720ce5fd2f29dbecf24b7281b603",
+		},
+		{
+			3,
+			"86d64a260059e495d0fb4fcc17ea3da7452391baa494d4b00321098ed2a0062f",
+			"13e6b25afb2eba51716a9a7d44130c0dbae0004a9ef8d7b5550c8a0e07c61775",
+			"c256de65476204095ebdc01bd11dc10e57b36bc96284595b8215222374f99c0e",
+			"243d71944d929063bc51205714ae3c2218bd3451d0214dfb5aeec2a90c35180d",
+			"cfbdb165bd8aad6eb79deb8d287bcc0ab6658ae57fdcc98ed12c0669e90aec91",
+			"7487bc41a6e712eea2f2af24681b58b1cf1da278ea11fe4e8b78398965f13552",
+			"b087686bf35a13f3dc78e780a34b0fe8a77fef1b9938c563f5573d71d8d7890f",
+			"bd86125de990acc5e1f13781d8e32c03a9bbd4c53539bbc106058bfd14326007",
+		},
+	}
+
+	keys := make([]*KeyShare, len(signers))
+	nonces := make([]*SigningNonces, len(signers))
+	commitments := make([]*SigningCommitment, len(signers))
+	for i, v := range signers {
+		keys[i] = &KeyShare{ID: v.id, Threshold: 2, Secret: dealer.Share(v.id), GroupPub: groupPub}
+		var err error
+		if nonces[i], err = keys[i].Commit(bytes.NewReader(frostHex(v.hidingRand + v.bindingRand))); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(nonces[i].hiding[:], frostHex(v.hiding)) || !bytes.Equal(nonces[i].binding[:], frostHex(v.binding)) {
+			t.Fatalf("participant %d: got nonces %x %x", v.id, nonces[i].hiding, nonces[i].binding)
+		}
+		c := nonces[i].Commitment
+		if !bytes.Equal(c.Hiding, frostHex(v.hidingCommit)) || !bytes.Equal(c.Binding, frostHex(v.bindingCommit)) {
+			t.Fatalf("participant %d: got commitments %x %x", v.id, c.Hiding, c.Binding)
+		}
+		commitments[i] = c
+	}
+
+	ss, err := newSigningSession(groupPub, msg, commitments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ss.r, frostHex("36282629c383bb820a88b71cae937d41f2f2adfcc3d02e55507e2fb9e2dd3cbe")) {
+		t.Fatalf("Got group commitment %x", ss.r)
+	}
+	shares := make([]*SignatureShare, len(signers))
+	for i, v := range signers {
+		if rho := ss.rhos[v.id]; !bytes.Equal(rho[:], frostHex(v.rho)) {
+			t.Fatalf("participant %d: got binding factor %x", v.id, rho)
+		}
+		if shares[i], err = keys[i].Sign(nonces[i], msg, commitments); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(shares[i].Z, frostHex(v.z)) {
+			t.Fatalf("participant %d: got signature share %x", v.id, shares[i].Z)
+		}
+	}
+
+	sig, err := Aggregate(groupPub, msg, commitments, shares, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "36282629c383bb820a88b71cae937d41f2f2adfcc3d02e55507e2fb9e2dd3cbebd9d2b0844e49ae0f3fa935161e1419aab7b47d21a37ebeae1f17d4987b3160b"
+	if !bytes.Equal(sig, frostHex(expected)) {
+		t.Fatalf("Got signature %x", sig)
+	}
+}
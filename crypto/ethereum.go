@@ -0,0 +1,353 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// ethereum message hashing
+//
+// personal_sign: https://github.com/ethereum/go-ethereum/wiki/Management-APIs#personal_sign
+// typed data:    https://eips.ethereum.org/EIPS/eip-712
+
+// EthPersonalHash returns the digest signed by personal_sign (eth_sign), ie.
+// keccak256("\x19Ethereum Signed Message:\n" + len(msg) + msg)
+func EthPersonalHash(msg []byte) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(msg))
+	return Sha3([]byte(prefix), msg)
+}
+
+// TypedData is the json format of EIP-712 structured data (as used by eth_signTypedData)
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// EIP712Hash returns the digest to sign for the json encoded typed data, ie.
+// keccak256("\x19\x01" + domainSeparator + hashStruct(message))
+func EIP712Hash(typedDataJSON []byte) ([]byte, error) {
+	td := new(TypedData)
+	dec := json.NewDecoder(bytes.NewReader(typedDataJSON))
+	dec.UseNumber()
+	if err := dec.Decode(td); err != nil {
+		return nil, fmt.Errorf("invalid typed data: %v", err)
+	}
+	if _, ok := td.Types["EIP712Domain"]; !ok {
+		return nil, fmt.Errorf("typed data is missing the EIP712Domain type")
+	}
+	if td.PrimaryType == "" {
+		return nil, fmt.Errorf("typed data is missing the primaryType")
+	}
+
+	domainSeparator, err := td.HashStruct("EIP712Domain", td.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing domain: %v", err)
+	}
+	messageHash, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing message: %v", err)
+	}
+	return Sha3([]byte{0x19, 0x01}, domainSeparator, messageHash), nil
+}
+
+// HashStruct returns keccak256(typeHash + encodeData(data))
+func (td *TypedData) HashStruct(typ string, data map[string]interface{}) ([]byte, error) {
+	enc, err := td.encodeData(typ, data)
+	if err != nil {
+		return nil, err
+	}
+	return Sha3(enc), nil
+}
+
+// TypeHash returns keccak256(encodeType(typ))
+func (td *TypedData) TypeHash(typ string) ([]byte, error) {
+	enc, err := td.EncodeType(typ)
+	if err != nil {
+		return nil, err
+	}
+	return Sha3([]byte(enc)), nil
+}
+
+// EncodeType returns eg. "Mail(Person from,Person to,string contents)Person(string name,address wallet)"
+// with the referenced struct types sorted by name
+func (td *TypedData) EncodeType(typ string) (string, error) {
+	deps := make(map[string]bool)
+	if err := td.dependencies(typ, deps); err != nil {
+		return "", err
+	}
+	delete(deps, typ)
+	sorted := []string{}
+	for d := range deps {
+		sorted = append(sorted, d)
+	}
+	sort.Strings(sorted)
+	sorted = append([]string{typ}, sorted...)
+
+	var buf bytes.Buffer
+	for _, t := range sorted {
+		buf.WriteString(t + "(")
+		for i, f := range td.Types[t] {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(f.Type + " " + f.Name)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String(), nil
+}
+
+func (td *TypedData) dependencies(typ string, found map[string]bool) error {
+	typ = baseType(typ)
+	if found[typ] {
+		return nil
+	}
+	fields, ok := td.Types[typ]
+	if !ok {
+		return nil
+	}
+	found[typ] = true
+	for _, f := range fields {
+		if err := td.dependencies(f.Type, found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// strip array suffixes, eg. Person[][2] -> Person
+func baseType(typ string) string {
+	if i := strings.Index(typ, "["); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+func (td *TypedData) encodeData(typ string, data map[string]interface{}) ([]byte, error) {
+	fields, ok := td.Types[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	typeHash, err := td.TypeHash(typ)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(typeHash)
+	for _, f := range fields {
+		val, ok := data[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing field %s of %s", f.Name, typ)
+		}
+		enc, err := td.encodeValue(f.Type, val)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %v", f.Name, typ, err)
+		}
+		buf.Write(enc)
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	intTypeRe   = regexp.MustCompile(`^(u?)int(\d*)$`)
+	bytesTypeRe = regexp.MustCompile(`^bytes(\d+)$`)
+)
+
+// encodeValue returns the 32 byte encoding of a value
+func (td *TypedData) encodeValue(typ string, val interface{}) ([]byte, error) {
+	// arrays are the hash of their concatenated encoded elements
+	if strings.HasSuffix(typ, "]") {
+		elemType := typ[:strings.LastIndex(typ, "[")]
+		elems, ok := val.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an array for %s", typ)
+		}
+		var buf bytes.Buffer
+		for _, e := range elems {
+			enc, err := td.encodeValue(elemType, e)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(enc)
+		}
+		return Sha3(buf.Bytes()), nil
+	}
+
+	if _, ok := td.Types[typ]; ok {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object for %s", typ)
+		}
+		return td.HashStruct(typ, m)
+	}
+
+	switch {
+	case typ == "string":
+		s, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string")
+		}
+		return Sha3([]byte(s)), nil
+	case typ == "bytes":
+		b, err := hexValue(val)
+		if err != nil {
+			return nil, err
+		}
+		return Sha3(b), nil
+	case typ == "bool":
+		b, ok := val.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a bool")
+		}
+		if b {
+			return leftPad32([]byte{1}), nil
+		}
+		return make([]byte, 32), nil
+	case typ == "address":
+		b, err := hexValue(val)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 20 {
+			return nil, fmt.Errorf("address must be 20 bytes, got %d", len(b))
+		}
+		return leftPad32(b), nil
+	case bytesTypeRe.MatchString(typ):
+		n, _ := strconv.Atoi(bytesTypeRe.FindStringSubmatch(typ)[1])
+		b, err := hexValue(val)
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 || n > 32 || len(b) > n {
+			return nil, fmt.Errorf("invalid %s value of length %d", typ, len(b))
+		}
+		out := make([]byte, 32)
+		copy(out, b)
+		return out, nil
+	case intTypeRe.MatchString(typ):
+		return encodeInt(typ, val)
+	}
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func encodeInt(typ string, val interface{}) ([]byte, error) {
+	m := intTypeRe.FindStringSubmatch(typ)
+	signed := m[1] == ""
+	bits := 256
+	if m[2] != "" {
+		bits, _ = strconv.Atoi(m[2])
+	}
+	if bits < 8 || bits > 256 || bits%8 != 0 {
+		return nil, fmt.Errorf("invalid integer type %s", typ)
+	}
+
+	var s string
+	switch v := val.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return nil, fmt.Errorf("expected a number for %s", typ)
+	}
+	n, ok := parseInt(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %s", s)
+	}
+
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if signed {
+		max.Rsh(max, 1)
+		min := new(big.Int).Neg(max)
+		if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+			return nil, fmt.Errorf("%s out of range for %s", s, typ)
+		}
+	} else if n.Sign() < 0 || n.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("%s out of range for %s", s, typ)
+	}
+
+	// two's complement
+	if n.Sign() < 0 {
+		n.Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return leftPad32(n.Bytes()), nil
+}
+
+// parseInt parses a decimal integer, or a hex one with a 0x prefix.
+// Unlike big.Int's base 0, it doesn't take octal, binary or underscores
+func parseInt(s string) (*big.Int, bool) {
+	digits := strings.TrimPrefix(s, "-")
+	base := 10
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits, base = digits[2:], 16
+	}
+	if digits == "" || strings.ContainsAny(digits, "+-_") {
+		return nil, false
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, false
+	}
+	if strings.HasPrefix(s, "-") {
+		n.Neg(n)
+	}
+	return n, true
+}
+
+func hexValue(val interface{}) ([]byte, error) {
+	s, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a hex string")
+	}
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	return hex.DecodeString(s)
+}
+
+func leftPad32(b []byte) []byte {
+	out := make([]byte, 32)
+	copy(out[32-len(b):], b)
+	return out
+}
+
+//-----------------------------------------------------------------------------
+// ethereum signatures
+
+// ToEthSignature converts a 65 byte r||s||recid signature into r||s||v with v = 27 + recid
+func ToEthSignature(sig []byte) ([]byte, error) {
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("ethereum signatures must be 65 bytes, got %d", len(sig))
+	}
+	ethSig := make([]byte, 65)
+	copy(ethSig, sig)
+	if ethSig[64] < 27 {
+		ethSig[64] += 27
+	}
+	return ethSig, nil
+}
+
+// FromEthSignature converts a r||s||v signature (v of 27/28, or 0/1) back to r||s||recid
+func FromEthSignature(sig []byte) ([]byte, error) {
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("ethereum signatures must be 65 bytes, got %d", len(sig))
+	}
+	s := make([]byte, 65)
+	copy(s, sig)
+	if s[64] >= 27 {
+		s[64] -= 27
+	}
+	return s, nil
+}
@@ -0,0 +1,187 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/eris-ltd/eris-keys/crypto/secp256k1"
+)
+
+// example from https://eips.ethereum.org/EIPS/eip-712
+var mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestEIP712Hash(t *testing.T) {
+	exp, _ := hex.DecodeString("be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2")
+	checkhash(t, "EIP712", func(in []byte) []byte {
+		h, err := EIP712Hash(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}, []byte(mailTypedData), exp)
+}
+
+func TestEIP712EncodeType(t *testing.T) {
+	td := &TypedData{Types: map[string][]TypedDataField{
+		"Mail":   {{"from", "Person"}, {"to", "Person"}, {"contents", "string"}},
+		"Person": {{"name", "string"}, {"wallet", "address"}},
+	}}
+	enc, err := td.EncodeType("Mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "Mail(Person from,Person to,string contents)Person(string name,address wallet)"; enc != exp {
+		t.Fatalf("Got %s, expected %s", enc, exp)
+	}
+}
+
+func TestEthPersonalHash(t *testing.T) {
+	exp, _ := hex.DecodeString("a1de988600a42c4b4ab089b619297c17d53cffae5d5120d82d8a92d0bb3b78f2")
+	checkhash(t, "EthPersonal", EthPersonalHash, []byte("Hello World"), exp)
+}
+
+func TestEthSignature(t *testing.T) {
+	priv := Sha3([]byte("cow"))
+	key, err := NewKeyFromPriv(KeyType{CurveTypeSecp256k1, AddrTypeSha3}, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := hex.EncodeToString(key.Address); addr != "cd2a3d9f938e13cd947ec05abc7fe734df8dd826" {
+		t.Fatalf("Wrong address for key, got %s", addr)
+	}
+
+	hash, err := EIP712Hash([]byte(mailTypedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := key.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ethSig, err := ToEthSignature(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ethSig[64] != 27 && ethSig[64] != 28 {
+		t.Fatalf("Invalid v %d", ethSig[64])
+	}
+	sig2, err := FromEthSignature(ethSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := key.Pubkey()
+	if ok, err := Verify(CurveTypeSecp256k1, hash, sig2, pub); !ok {
+		t.Fatalf("Signature failed to verify: %v", err)
+	}
+}
+
+// example from https://github.com/ethereum/EIPs/blob/master/EIPS/eip-155.md
+var eip155Tx = `{
+	"nonce": 9,
+	"gasPrice": "20000000000",
+	"gas": "0x5208",
+	"to": "0x3535353535353535353535353535353535353535",
+	"value": "1000000000000000000",
+	"chainId": 1
+}`
+
+func TestEthTxEIP155(t *testing.T) {
+	tx, err := EthTxFromJSON([]byte(eip155Tx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expData := "ec098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a764000080018080"
+	if d := hex.EncodeToString(tx.SigningData()); d != expData {
+		t.Fatalf("Wrong signing data. Got %s, expected %s", d, expData)
+	}
+	expHash := "daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53"
+	if h := hex.EncodeToString(tx.SigHash()); h != expHash {
+		t.Fatalf("Wrong signing hash. Got %s, expected %s", h, expHash)
+	}
+
+	sig, _ := hex.DecodeString("28ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276" +
+		"67cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83" + "00")
+	raw, err := tx.EncodeSigned(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expRaw := "f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a0" +
+		"28ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a0" +
+		"67cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+	if r := hex.EncodeToString(raw); r != expRaw {
+		t.Fatalf("Wrong raw transaction. Got %s, expected %s", r, expRaw)
+	}
+
+	// the signature is from the example's private key
+	pub, err := secp256k1.RecoverPubkey(tx.SigHash(), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, _ := hex.DecodeString("4646464646464646464646464646464646464646464646464646464646464646")
+	pub2, _ := secp256k1.GeneratePubKey(priv)
+	if !bytes.Equal(pub, pub2) {
+		t.Fatalf("Recovered wrong pubkey")
+	}
+}
+
+func TestEthTxLegacy(t *testing.T) {
+	tx, err := EthTxFromJSON([]byte(`{"nonce": 0, "gasPrice": 1, "gas": 21000, "to": "", "value": 0, "data": "0x6060"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 65)
+	sig[31], sig[63], sig[64] = 1, 2, 1
+	raw, err := tx.EncodeSigned(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// [0, 1, 21000, "", 0, 0x6060, 28, 1, 2]
+	if r := hex.EncodeToString(raw); r != "cd800182520880808260601c0102" {
+		t.Fatalf("Wrong raw legacy transaction. Got %s", r)
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	for s, exp := range map[string]int64{"10": 10, "010": 10, "0x10": 16, "0X1f": 31, "-0x10": -16, "-7": -7} {
+		if n, ok := parseInt(s); !ok || n.Int64() != exp {
+			t.Fatalf("Wrong value for %s. Got %v, expected %d", s, n, exp)
+		}
+	}
+	for _, s := range []string{"", "0x", "0b10", "0o10", "1_000", "+1", "--1", "0x-1", "1e3"} {
+		if _, ok := parseInt(s); ok {
+			t.Fatalf("Expected an error parsing %q", s)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package crypto
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/eris-ltd/eris-keys/crypto/rlp"
+)
+
+//-----------------------------------------------------------------------------
+// ethereum transactions
+//
+// Transactions with a chain id are signed with EIP-155 replay protection,
+// otherwise as legacy (homestead) transactions.
+// See https://github.com/ethereum/EIPs/blob/master/EIPS/eip-155.md
+
+type EthTx struct {
+	Nonce    *big.Int
+	GasPrice *big.Int
+	Gas      *big.Int
+	To       []byte // nil for contract creation
+	Value    *big.Int
+	Data     []byte
+	ChainID  *big.Int // nil or zero for a legacy transaction
+}
+
+// json format of a transaction. Numbers may be json numbers,
+// or decimal or 0x prefixed hex strings
+type ethTxJSON struct {
+	Nonce    json.RawMessage `json:"nonce"`
+	GasPrice json.RawMessage `json:"gasPrice"`
+	Gas      json.RawMessage `json:"gas"`
+	To       string          `json:"to"`
+	Value    json.RawMessage `json:"value"`
+	Data     string          `json:"data"`
+	ChainID  json.RawMessage `json:"chainId"`
+}
+
+func EthTxFromJSON(j []byte) (*EthTx, error) {
+	txJ := new(ethTxJSON)
+	if err := json.Unmarshal(j, txJ); err != nil {
+		return nil, fmt.Errorf("invalid transaction json: %v", err)
+	}
+
+	tx := new(EthTx)
+	var err error
+	for _, f := range []struct {
+		name string
+		val  json.RawMessage
+		dst  **big.Int
+	}{
+		{"nonce", txJ.Nonce, &tx.Nonce},
+		{"gasPrice", txJ.GasPrice, &tx.GasPrice},
+		{"gas", txJ.Gas, &tx.Gas},
+		{"value", txJ.Value, &tx.Value},
+		{"chainId", txJ.ChainID, &tx.ChainID},
+	} {
+		if *f.dst, err = parseTxInt(f.val); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", f.name, err)
+		}
+	}
+
+	if txJ.To != "" {
+		if tx.To, err = hexValue(txJ.To); err != nil {
+			return nil, fmt.Errorf("invalid to: %v", err)
+		}
+		if len(tx.To) != 20 {
+			return nil, fmt.Errorf("invalid to: address must be 20 bytes, got %d", len(tx.To))
+		}
+	}
+	if txJ.Data != "" {
+		if tx.Data, err = hexValue(txJ.Data); err != nil {
+			return nil, fmt.Errorf("invalid data: %v", err)
+		}
+	}
+	return tx, nil
+}
+
+func parseTxInt(raw json.RawMessage) (*big.Int, error) {
+	n := strings.Trim(string(raw), `"`)
+	if n == "" || n == "null" {
+		return new(big.Int), nil
+	}
+	i, ok := parseInt(n)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an integer", n)
+	}
+	if i.Sign() < 0 {
+		return nil, fmt.Errorf("%s is negative", n)
+	}
+	return i, nil
+}
+
+func (tx *EthTx) isEIP155() bool {
+	return tx.ChainID != nil && tx.ChainID.Sign() > 0
+}
+
+func (tx *EthTx) fields() [][]byte {
+	return [][]byte{
+		rlp.EncodeBigInt(tx.Nonce),
+		rlp.EncodeBigInt(tx.GasPrice),
+		rlp.EncodeBigInt(tx.Gas),
+		rlp.EncodeBytes(tx.To),
+		rlp.EncodeBigInt(tx.Value),
+		rlp.EncodeBytes(tx.Data),
+	}
+}
+
+// SigningData returns the rlp encoding that is hashed for signing
+func (tx *EthTx) SigningData() []byte {
+	fields := tx.fields()
+	if tx.isEIP155() {
+		fields = append(fields, rlp.EncodeBigInt(tx.ChainID), rlp.EncodeUint(0), rlp.EncodeUint(0))
+	}
+	return rlp.EncodeList(fields...)
+}
+
+// SigHash returns the hash to sign
+func (tx *EthTx) SigHash() []byte {
+	return Sha3(tx.SigningData())
+}
+
+// EncodeSigned returns the raw signed transaction, given a
+// 65 byte r||s||recid signature of the SigHash
+func (tx *EthTx) EncodeSigned(sig []byte) ([]byte, error) {
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("transaction signatures must be 65 bytes, got %d", len(sig))
+	}
+	recid := sig[64]
+	if recid >= 27 {
+		recid -= 27
+	}
+	if recid > 1 {
+		return nil, fmt.Errorf("invalid recovery id %d", sig[64])
+	}
+
+	v := big.NewInt(int64(recid) + 27)
+	if tx.isEIP155() {
+		v = new(big.Int).Mul(tx.ChainID, big.NewInt(2))
+		v.Add(v, big.NewInt(int64(recid)+35))
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+
+	fields := append(tx.fields(), rlp.EncodeBigInt(v), rlp.EncodeBigInt(r), rlp.EncodeBigInt(s))
+	return rlp.EncodeList(fields...), nil
+}
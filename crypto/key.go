@@ -25,10 +25,10 @@
 package crypto
 
 import (
-	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/eris-ltd/eris-keys/crypto/ed25519"
@@ -85,6 +85,10 @@ func (k CurveType) String() string {
 		return "secp256k1"
 	case CurveTypeEd25519:
 		return "ed25519"
+	case CurveTypeSecp256r1:
+		return "secp256r1"
+	case CurveTypeBLS12381:
+		return "bls12381"
 	default:
 		return "unknown"
 	}
@@ -96,6 +100,10 @@ func CurveTypeFromString(s string) (CurveType, error) {
 		return CurveTypeSecp256k1, nil
 	case "ed25519":
 		return CurveTypeEd25519, nil
+	case "secp256r1":
+		return CurveTypeSecp256r1, nil
+	case "bls12381":
+		return CurveTypeBLS12381, nil
 	default:
 		var k CurveType
 		return k, InvalidCurveErr(s)
@@ -105,6 +113,8 @@ func CurveTypeFromString(s string) (CurveType, error) {
 const (
 	CurveTypeSecp256k1 CurveType = iota
 	CurveTypeEd25519
+	CurveTypeSecp256r1 // NIST P-256
+	CurveTypeBLS12381
 )
 
 //-----------------------------------------------------------------------------
@@ -120,6 +130,8 @@ func (a AddrType) String() string {
 		return "ripemd160sha256"
 	case AddrTypeSha3:
 		return "sha3"
+	case AddrTypeSha256:
+		return "sha256"
 	default:
 		return "unknown"
 	}
@@ -133,6 +145,8 @@ func AddrTypeFromString(s string) (AddrType, error) {
 		return AddrTypeRipemd160Sha256, nil
 	case "sha3":
 		return AddrTypeSha3, nil
+	case "sha256":
+		return AddrTypeSha256, nil
 	default:
 		var a AddrType
 		return a, fmt.Errorf("unknown addr type %s", s)
@@ -143,8 +157,12 @@ const (
 	AddrTypeRipemd160 AddrType = iota
 	AddrTypeRipemd160Sha256
 	AddrTypeSha3
+	AddrTypeSha256 // of the compressed point
 )
 
+// all address types, for when we need to try each of them
+var AddrTypes = []AddrType{AddrTypeRipemd160, AddrTypeRipemd160Sha256, AddrTypeSha3, AddrTypeSha256}
+
 func AddressFromPub(addrType AddrType, pub []byte) (addr []byte) {
 	switch addrType {
 	case AddrTypeRipemd160:
@@ -154,10 +172,21 @@ func AddressFromPub(addrType AddrType, pub []byte) (addr []byte) {
 		addr = Ripemd160(Sha256(pub))
 	case AddrTypeSha3:
 		addr = Sha3(pub[1:])[12:]
+	case AddrTypeSha256:
+		addr = Sha256(compressPoint(pub))
 	}
 	return
 }
 
+// compressPoint returns the 33 byte compressed form of a 65 byte uncompressed curve point.
+// Anything else is returned as is
+func compressPoint(pub []byte) []byte {
+	if len(pub) != 65 || pub[0] != 0x04 {
+		return pub
+	}
+	return append([]byte{0x02 + pub[64]&1}, pub[1:33]...)
+}
+
 //-----------------------------------------------------------------------------
 // main key struct and functions (sign, pubkey, verify)
 
@@ -171,9 +200,13 @@ type Key struct {
 func NewKey(typ KeyType) (*Key, error) {
 	switch typ.CurveType {
 	case CurveTypeSecp256k1:
-		return newKeySecp256k1(typ.AddrType), nil
+		return newKeySecp256k1(typ.AddrType)
 	case CurveTypeEd25519:
-		return newKeyEd25519(typ.AddrType), nil
+		return newKeyEd25519(typ.AddrType)
+	case CurveTypeSecp256r1:
+		return newKeySecp256r1(typ.AddrType)
+	case CurveTypeBLS12381:
+		return newKeyBLS12381(typ.AddrType)
 	default:
 		return nil, fmt.Errorf("Unknown curve type: %v", typ.CurveType)
 	}
@@ -185,9 +218,40 @@ func NewKeyFromPriv(typ KeyType, priv []byte) (*Key, error) {
 		return keyFromPrivSecp256k1(typ.AddrType, priv)
 	case CurveTypeEd25519:
 		return keyFromPrivEd25519(typ.AddrType, priv)
+	case CurveTypeSecp256r1:
+		return keyFromPrivSecp256r1(typ.AddrType, priv)
+	case CurveTypeBLS12381:
+		return keyFromPrivBLS12381(typ.AddrType, priv)
+	default:
+		return nil, fmt.Errorf("Unknown curve type: %v", typ.CurveType)
+	}
+}
+
+// NewKeyFromReader makes a key from 32 bytes read from r, eg. a randentropy.Source.
+// Bytes that aren't a valid private key are discarded and more are read
+func NewKeyFromReader(typ KeyType, r io.Reader) (*Key, error) {
+	switch typ.CurveType {
+	case CurveTypeSecp256k1, CurveTypeEd25519, CurveTypeSecp256r1, CurveTypeBLS12381:
 	default:
 		return nil, fmt.Errorf("Unknown curve type: %v", typ.CurveType)
 	}
+	for i := 0; i < 16; i++ {
+		randBytes := make([]byte, 32)
+		if _, err := io.ReadFull(r, randBytes); err != nil {
+			return nil, err
+		}
+		if typ.CurveType == CurveTypeBLS12381 {
+			priv, err := blsKeyGen(randBytes)
+			if err != nil {
+				return nil, err
+			}
+			return keyFromPrivBLS12381(typ.AddrType, priv)
+		}
+		if key, err := NewKeyFromPriv(typ, randBytes); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("could not make a valid %v key from the entropy source", typ.CurveType)
 }
 
 func (k *Key) Sign(hash []byte) ([]byte, error) {
@@ -196,6 +260,10 @@ func (k *Key) Sign(hash []byte) ([]byte, error) {
 		return signSecp256k1(k, hash)
 	case CurveTypeEd25519:
 		return signEd25519(k, hash)
+	case CurveTypeSecp256r1:
+		return signSecp256r1(k, hash)
+	case CurveTypeBLS12381:
+		return signBLS12381(k, hash)
 	}
 	return nil, InvalidCurveErr(k.Type.CurveType)
 }
@@ -206,6 +274,10 @@ func (k *Key) Pubkey() ([]byte, error) {
 		return pubKeySecp256k1(k)
 	case CurveTypeEd25519:
 		return pubKeyEd25519(k)
+	case CurveTypeSecp256r1:
+		return pubKeySecp256r1(k)
+	case CurveTypeBLS12381:
+		return pubKeyBLS12381(k)
 	}
 	return nil, InvalidCurveErr(k.Type.CurveType)
 }
@@ -216,10 +288,80 @@ func Verify(curveType CurveType, hash, sig, pub []byte) (bool, error) {
 		return verifySigSecp256k1(hash, sig, pub)
 	case CurveTypeEd25519:
 		return verifySigEd25519(hash, sig, pub)
+	case CurveTypeSecp256r1:
+		return verifySigSecp256r1(hash, sig, pub)
+	case CurveTypeBLS12381:
+		return verifySigBLS12381(hash, sig, pub)
 	}
 	return false, InvalidCurveErr(curveType)
 }
 
+// CanonicalPubkey checks the pubkey is valid for the curve and returns its one canonical encoding,
+// so that the same key given in different encodings compares equal.
+// secp256k1 and secp256r1 pubkeys may be given compressed or uncompressed, and are returned compressed
+func CanonicalPubkey(curveType CurveType, pub []byte) ([]byte, error) {
+	switch curveType {
+	case CurveTypeSecp256k1:
+		if len(pub) == 65 && pub[0] != 0x04 {
+			return nil, fmt.Errorf("invalid secp256k1 pubkey %X", pub)
+		}
+		if err := secp256k1.VerifyPubkeyValidity(pub); err != nil {
+			return nil, fmt.Errorf("invalid secp256k1 pubkey %X: %v", pub, err)
+		}
+		return compressPoint(pub), nil
+	case CurveTypeEd25519:
+		if len(pub) != 32 {
+			return nil, fmt.Errorf("ed25519 pubkeys are 32 bytes, got %d", len(pub))
+		}
+		return pub, nil
+	case CurveTypeSecp256r1:
+		return canonicalPubSecp256r1(pub)
+	case CurveTypeBLS12381:
+		return canonicalPubBLS12381(pub)
+	}
+	return nil, InvalidCurveErr(curveType)
+}
+
+// VerifyBatch verifies each sig of a hash against its pubkey.
+// Ed25519 signatures are batch verified
+func VerifyBatch(curveType CurveType, hashes, sigs, pubs [][]byte) ([]bool, error) {
+	if len(sigs) != len(hashes) || len(pubs) != len(hashes) {
+		return nil, fmt.Errorf("need the same number of hashes, signatures and pubkeys")
+	}
+	switch curveType {
+	case CurveTypeSecp256k1, CurveTypeSecp256r1, CurveTypeBLS12381:
+		results := make([]bool, len(hashes))
+		for i := range hashes {
+			// a malformed signature is just invalid
+			results[i], _ = Verify(curveType, hashes[i], sigs[i], pubs[i])
+		}
+		return results, nil
+	case CurveTypeEd25519:
+		// a malformed signature is just invalid, and left out of the batch
+		results := make([]bool, len(hashes))
+		var idx []int
+		var pubKeys []*[32]byte
+		var sigBytes []*[64]byte
+		var msgs [][]byte
+		for i := range pubs {
+			if len(pubs[i]) != 32 || len(sigs[i]) != 64 {
+				continue
+			}
+			pubKey, sig := new([32]byte), new([64]byte)
+			copy(pubKey[:], pubs[i])
+			copy(sig[:], sigs[i])
+			idx = append(idx, i)
+			pubKeys, sigBytes, msgs = append(pubKeys, pubKey), append(sigBytes, sig), append(msgs, hashes[i])
+		}
+		_, valid := ed25519.VerifyBatch(pubKeys, msgs, sigBytes)
+		for j, i := range idx {
+			results[i] = valid[j]
+		}
+		return results, nil
+	}
+	return nil, InvalidCurveErr(curveType)
+}
+
 //-----------------------------------------------------------------------------
 // json encodings
 
@@ -304,21 +446,26 @@ func IsValidKeyJson(j []byte) []byte {
 // main utility functions for each key type (new, pub, sign, verify)
 // TODO: run all sorts of length and validity checks
 
-func newKeySecp256k1(addrType AddrType) *Key {
-	pub, priv := secp256k1.GenerateKeyPair()
+func newKeySecp256k1(addrType AddrType) (*Key, error) {
+	pub, priv, err := secp256k1.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
 	id, _ := uuid.NewRandom()
 	return &Key{
 		Id:         id,
 		Type:       KeyType{CurveTypeSecp256k1, addrType},
 		Address:    AddressFromPub(addrType, pub),
 		PrivateKey: priv,
-	}
+	}, nil
 }
 
-func newKeyEd25519(addrType AddrType) *Key {
-	randBytes := randentropy.GetEntropyMixed(32)
-	key, _ := keyFromPrivEd25519(addrType, randBytes)
-	return key
+func newKeyEd25519(addrType AddrType) (*Key, error) {
+	randBytes, err := randentropy.GetEntropyMixed(32)
+	if err != nil {
+		return nil, err
+	}
+	return keyFromPrivEd25519(addrType, randBytes)
 }
 
 func keyFromPrivSecp256k1(addrType AddrType, priv []byte) (*Key, error) {
@@ -361,7 +508,18 @@ func pubKeyEd25519(k *Key) ([]byte, error) {
 	return pubKeyBytes[:], nil
 }
 
+// Secp256k1NonceEntropy mixes 32 bytes from randentropy into the RFC 6979 nonce
+// of each secp256k1 signature. Signatures are deterministic without it
+var Secp256k1NonceEntropy bool
+
 func signSecp256k1(k *Key, hash []byte) ([]byte, error) {
+	if Secp256k1NonceEntropy {
+		entropy, err := randentropy.GetEntropyMixed(32)
+		if err != nil {
+			return nil, err
+		}
+		return secp256k1.SignWithEntropy(hash, k.PrivateKey, entropy)
+	}
 	return secp256k1.Sign(hash, k.PrivateKey)
 }
 
@@ -374,19 +532,21 @@ func signEd25519(k *Key, hash []byte) ([]byte, error) {
 	return sigB[:], nil
 }
 
-func verifySigSecp256k1(hash, sig, pubOG []byte) (bool, error) {
-	pub, err := secp256k1.RecoverPubkey(hash, sig)
-	if err != nil {
+func verifySigSecp256k1(hash, sig, pub []byte) (bool, error) {
+	if err := secp256k1.VerifySignature(hash, sig, pub); err != nil {
 		return false, err
 	}
+	return true, nil
+}
 
-	if bytes.Compare(pub, pubOG) != 0 {
-		return false, fmt.Errorf("Recovered pub key does not match. Got %X, expected %X", pub, pubOG)
+// RecoverPubkey returns the uncompressed secp256k1 pubkey that made the
+// 65 byte r||s||recid signature of the hash. The recovery id may also be
+// given as an ethereum v of 27 or 28
+func RecoverPubkey(hash, sig []byte) ([]byte, error) {
+	if len(sig) == 65 && sig[64] >= 27 {
+		sig = append(append([]byte{}, sig[:64]...), sig[64]-27)
 	}
-
-	// TODO: validate recovered pub!
-
-	return true, nil
+	return secp256k1.RecoverPubkey(hash, sig)
 }
 
 func verifySigEd25519(hash, sig, pub []byte) (bool, error) {
@@ -83,6 +83,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	uuid "github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/wayn3h0/go-uuid"
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/golang.org/x/crypto/scrypt" // 2^18 / 8 / 1 uses 256MB memory and approx 1s CPU time on a modern CPU.
@@ -96,6 +97,18 @@ const (
 	scryptdkLen = 32
 )
 
+// OnScrypt, if set, is called with the duration of every scrypt key derivation
+var OnScrypt func(time.Duration)
+
+func scryptKey(auth, salt []byte) ([]byte, error) {
+	start := time.Now()
+	derivedKey, err := scrypt.Key(auth, salt, scryptN, scryptr, scryptp, scryptdkLen)
+	if OnScrypt != nil {
+		OnScrypt(time.Since(start))
+	}
+	return derivedKey, err
+}
+
 type keyStorePassphrase struct {
 	keysDirPath string
 }
@@ -122,8 +135,11 @@ func (ks keyStorePassphrase) GetAllAddresses() (addresses [][]byte, err error) {
 
 func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
 	authArray := []byte(auth)
-	salt := randentropy.GetEntropyMixed(32)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
+	salt, err := randentropy.GetEntropyMixed(32)
+	if err != nil {
+		return err
+	}
+	derivedKey, err := scryptKey(authArray, salt)
 	if err != nil {
 		return err
 	}
@@ -142,7 +158,10 @@ func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
 	}
 
 	// XXX: a GCM nonce may only be used once per key ever!
-	nonce := randentropy.GetEntropyMixed(gcm.NonceSize())
+	nonce, err := randentropy.GetEntropyMixed(gcm.NonceSize())
+	if err != nil {
+		return err
+	}
 
 	// (dst, nonce, plaintext, extradata)
 	cipherText := gcm.Seal(nil, nonce, toEncrypt, nil)
@@ -221,7 +240,7 @@ func DecryptKey(ks keyStorePassphrase, keyAddr []byte, auth string) (*Key, error
 	cipherText := keyProtected.Crypto.CipherText
 
 	authArray := []byte(auth)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
+	derivedKey, err := scryptKey(authArray, salt)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,90 @@
+package randentropy
+
+import (
+	"fmt"
+	"math"
+)
+
+// Continuous health tests on the raw samples of an entropy source,
+// the repetition count and adaptive proportion tests of NIST SP 800-90B section 4.4.
+// They catch a source that's stuck or badly biased, not one that's merely predictable.
+// The cutoffs are set for a false positive rate of 2^-40 per sample
+
+const (
+	healthAlphaBits = 40
+	aptWindow       = 512
+)
+
+type healthTest struct {
+	rctCutoff int
+	aptCutoff int
+
+	// repetition count test
+	last    byte
+	lastRun int
+
+	// adaptive proportion test
+	aptRef   byte
+	aptCount int
+	aptSeen  int
+}
+
+// newHealthTest makes the tests for a source assessed to have the given bits of entropy per sample
+func newHealthTest(bitsPerSample float64) *healthTest {
+	return &healthTest{
+		rctCutoff: 1 + int(math.Ceil(healthAlphaBits/bitsPerSample)),
+		aptCutoff: aptCutoff(aptWindow, math.Pow(2, -bitsPerSample)),
+	}
+}
+
+// aptCutoff is the smallest count of the first sample in a window of w
+// that has a probability of at most 2^-40 with a sample probability of p
+func aptCutoff(w int, p float64) int {
+	n := w - 1
+	logAlpha := -healthAlphaBits * math.Ln2
+	// P(X >= k) for X ~ Binomial(n, p), summed down from k = n
+	tail := math.Inf(-1)
+	for k := n; k >= 0; k-- {
+		lg1, _ := math.Lgamma(float64(n + 1))
+		lg2, _ := math.Lgamma(float64(k + 1))
+		lg3, _ := math.Lgamma(float64(n - k + 1))
+		logP := lg1 - lg2 - lg3 + float64(k)*math.Log(p) + float64(n-k)*math.Log1p(-p)
+		// log(exp(tail) + exp(logP))
+		hi, lo := math.Max(tail, logP), math.Min(tail, logP)
+		tail = hi + math.Log1p(math.Exp(lo-hi))
+		if tail > logAlpha {
+			// the count includes the first sample of the window
+			return k + 2
+		}
+	}
+	return 1
+}
+
+// check runs the tests over the next samples from the source
+func (h *healthTest) check(samples []byte) error {
+	for _, s := range samples {
+		if h.lastRun > 0 && s == h.last {
+			h.lastRun++
+		} else {
+			h.last, h.lastRun = s, 1
+		}
+		if h.lastRun >= h.rctCutoff {
+			return fmt.Errorf("repetition count test failed: %d identical samples in a row", h.lastRun)
+		}
+
+		if h.aptSeen == 0 {
+			h.aptRef, h.aptCount = s, 0
+		}
+		if s == h.aptRef {
+			h.aptCount++
+		}
+		h.aptSeen++
+		if h.aptCount >= h.aptCutoff {
+			return fmt.Errorf("adaptive proportion test failed: %d of %d samples were the same", h.aptCount, h.aptSeen)
+		}
+		if h.aptSeen == aptWindow {
+			h.aptSeen = 0
+		}
+	}
+	return nil
+}
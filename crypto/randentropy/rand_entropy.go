@@ -1,13 +1,10 @@
 package randentropy
 
 import (
-	crand "crypto/rand"
-	"encoding/binary"
-	"github.com/eris-ltd/eris-keys/crypto/sha3"
 	"io"
-	"os"
-	"strings"
 	"time"
+
+	"github.com/eris-ltd/eris-keys/crypto/sha3"
 )
 
 var Reader io.Reader = &randEntropy{}
@@ -16,9 +13,7 @@ type randEntropy struct {
 }
 
 func (*randEntropy) Read(bytes []byte) (n int, err error) {
-	readBytes := GetEntropyMixed(len(bytes))
-	copy(bytes, readBytes)
-	return len(bytes), nil
+	return Mixed.Read(bytes)
 }
 
 // TODO: copied from crypto.go , move to sha3 package?
@@ -29,53 +24,33 @@ func Sha3(data []byte) []byte {
 	return d.Sum(nil)
 }
 
-// TODO: verify. this needs to be audited
-// we start with crypt/rand, then XOR in additional entropy from OS
-func GetEntropyMixed(n int) []byte {
-	startTime := time.Now().UnixNano()
-	// for each source, we take SHA3 of the source and use it as seed to math/rand
-	// then read bytes from it and XOR them onto the bytes read from crypto/rand
-	mainBuff := GetEntropyCSPRNG(n)
-	// 1. OS entropy sources
-	startTimeBytes := make([]byte, 32)
-	binary.PutVarint(startTimeBytes, startTime)
-	startTimeHash := Sha3(startTimeBytes)
-	mixBytes(mainBuff, startTimeHash)
-
-	pid := os.Getpid()
-	pidBytes := make([]byte, 32)
-	binary.PutUvarint(pidBytes, uint64(pid))
-	pidHash := Sha3(pidBytes)
-	mixBytes(mainBuff, pidHash)
-
-	osEnv := os.Environ()
-	osEnvBytes := []byte(strings.Join(osEnv, ""))
-	osEnvHash := Sha3(osEnvBytes)
-	mixBytes(mainBuff, osEnvHash)
-
-	// not all OS have hostname in env variables
-	osHostName, err := os.Hostname()
-	if err != nil {
-		osHostNameBytes := []byte(osHostName)
-		osHostNameHash := Sha3(osHostNameBytes)
-		mixBytes(mainBuff, osHostNameHash)
+// GetEntropyMixed reads n bytes from crypto/rand and XORs in a keystream
+// seeded from the time, pid, environment and hostname
+func GetEntropyMixed(n int) ([]byte, error) {
+	mainBuff := make([]byte, n)
+	if _, err := Mixed.Read(mainBuff); err != nil {
+		return nil, err
 	}
-	return mainBuff
+	return mainBuff, nil
 }
 
-func GetEntropyCSPRNG(n int) []byte {
+// GetEntropyCSPRNG reads n bytes from crypto/rand
+func GetEntropyCSPRNG(n int) ([]byte, error) {
 	mainBuff := make([]byte, n)
-	_, err := io.ReadFull(crand.Reader, mainBuff)
-	if err != nil {
-		panic("reading from crypto/rand failed: " + err.Error())
+	if _, err := CSPRNG.Read(mainBuff); err != nil {
+		return nil, err
 	}
-	return mainBuff
+	return mainBuff, nil
+}
+
+func timeNow() int64 {
+	return time.Now().UnixNano()
 }
 
 func mixBytes(buff []byte, mixBuff []byte) []byte {
 	bytesToMix := len(buff)
-	if bytesToMix > 32 {
-		bytesToMix = 32
+	if bytesToMix > len(mixBuff) {
+		bytesToMix = len(mixBuff)
 	}
 	for i := 0; i < bytesToMix; i++ {
 		buff[i] ^= mixBuff[i]
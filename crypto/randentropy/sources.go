@@ -0,0 +1,244 @@
+package randentropy
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Source is a source of entropy for key generation.
+// User supplied sources (a file or dice rolls) are mixed into the output of
+// the operating system's generator, so they can add entropy but never take it away.
+// Their raw samples are health tested as they're read, and Read fails if a test does.
+// The operating system's generator isn't: its output is already conditioned,
+// so the tests would only ever fail by chance
+type Source interface {
+	io.Reader
+	Name() string
+}
+
+// DiceRolls is the fewest dice rolls a dice source needs, for 256 bits of entropy
+var DiceRolls = int(math.Ceil(256 / math.Log2(6)))
+
+// the startup test of a file source reads this many samples (SP 800-90B section 4.3)
+const fileStartupSamples = 1024
+
+// FileSourceBytes is how much of a file is enough for a bytes source:
+// the startup test's samples, then 32 bytes for each of up to 16 tries at a valid private key
+const FileSourceBytes = fileStartupSamples + 16*32
+
+// ParseSource returns the source named by spec:
+// "os" for crypto/rand, "mixed" for crypto/rand with system information mixed in (the default),
+// "bytes:<hex>" to mix in bytes read from a file by the client, eg. from a hardware generator's device,
+// or "dice:<rolls>" to mix in at least DiceRolls rolls of a six sided die, written as digits 1 to 6.
+// Specs come from clients, so there's none for the daemon to open a file itself
+func ParseSource(spec string) (Source, error) {
+	switch {
+	case spec == "os":
+		return CSPRNG, nil
+	case spec == "mixed" || spec == "":
+		return Mixed, nil
+	case strings.HasPrefix(spec, "bytes:"):
+		b, err := hex.DecodeString(strings.TrimPrefix(spec, "bytes:"))
+		if err != nil {
+			return nil, fmt.Errorf("entropy bytes must be hex: %v", err)
+		}
+		return NewBytesSource(b)
+	case strings.HasPrefix(spec, "dice:"):
+		return NewDiceSource(strings.TrimPrefix(spec, "dice:"))
+	}
+	return nil, fmt.Errorf("unknown entropy source %q. Use os, mixed, bytes:<hex> or dice:<rolls>", spec)
+}
+
+//------------------------------------------------------------------------
+// os
+
+// CSPRNG is the operating system's generator, through crypto/rand
+var CSPRNG Source = osSource{}
+
+type osSource struct{}
+
+func (osSource) Name() string { return "os" }
+
+func (osSource) Read(p []byte) (int, error) {
+	if _, err := io.ReadFull(crand.Reader, p); err != nil {
+		return 0, fmt.Errorf("reading from crypto/rand failed: %v", err)
+	}
+	return len(p), nil
+}
+
+//------------------------------------------------------------------------
+// mixed
+
+// Mixed is CSPRNG with the time, pid, environment and hostname mixed in
+var Mixed Source = mixedSource{}
+
+type mixedSource struct{}
+
+func (mixedSource) Name() string { return "mixed" }
+
+func (mixedSource) Read(p []byte) (int, error) {
+	if _, err := CSPRNG.Read(p); err != nil {
+		return 0, err
+	}
+	mixStream(p, systemSeed())
+	return len(p), nil
+}
+
+// systemSeed hashes each piece of system information into one seed
+func systemSeed() []byte {
+	var seed []byte
+	add := func(b []byte) {
+		seed = Sha3(append(seed, Sha3(b)...))
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	add(buf[:binary.PutVarint(buf, timeNow())])
+	add(buf[:binary.PutUvarint(buf, uint64(os.Getpid()))])
+	add([]byte(strings.Join(os.Environ(), "")))
+	// not all OS have hostname in env variables
+	if hostname, err := os.Hostname(); err == nil {
+		add([]byte(hostname))
+	}
+	return seed
+}
+
+// mixStream XORs a keystream expanded from the seed over all of p
+func mixStream(p, seed []byte) {
+	var counter [8]byte
+	for i := 0; i < len(p); i += 32 {
+		binary.BigEndian.PutUint64(counter[:], uint64(i/32))
+		mixBytes(p[i:], Sha3(append(append([]byte{}, seed...), counter[:]...)))
+	}
+}
+
+//------------------------------------------------------------------------
+// file
+
+type fileSource struct {
+	mtx    sync.Mutex
+	name   string
+	r      io.Reader
+	health *healthTest
+	seed   []byte // hash of everything read from the file
+}
+
+// NewFileSource mixes bytes read from the file at path into CSPRNG output.
+// Each read hashes that many new bytes from the file into a running seed,
+// after a startup test of the file's first 1024 bytes, which are also hashed in.
+// The bytes are tested as if they were uniformly random
+func NewFileSource(path string) (Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newFileSource("file:"+path, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewBytesSource is NewFileSource for bytes already read from a file.
+// It needs at least 1024 of them, and 32 more for each read
+func NewBytesSource(b []byte) (Source, error) {
+	return newFileSource("bytes", bytes.NewReader(b))
+}
+
+func newFileSource(name string, r io.Reader) (*fileSource, error) {
+	s := &fileSource{name: name, r: r, health: newHealthTest(8)}
+	if err := s.absorb(fileStartupSamples); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSource) Name() string { return s.name }
+
+func (s *fileSource) absorb(n int) error {
+	raw := make([]byte, n)
+	if _, err := io.ReadFull(s.r, raw); err != nil {
+		return fmt.Errorf("entropy %s: %v", s.name, err)
+	}
+	if err := s.health.check(raw); err != nil {
+		return fmt.Errorf("entropy %s: %v", s.name, err)
+	}
+	s.seed = Sha3(append(s.seed, raw...))
+	return nil
+}
+
+func (s *fileSource) Read(p []byte) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err := s.absorb(len(p)); err != nil {
+		return 0, err
+	}
+	if _, err := CSPRNG.Read(p); err != nil {
+		return 0, err
+	}
+	mixStream(p, s.seed)
+	return len(p), nil
+}
+
+// Close closes the file
+func (s *fileSource) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------
+// dice
+
+type diceSource struct {
+	mtx     sync.Mutex
+	seed    []byte
+	counter uint64
+}
+
+// NewDiceSource mixes dice rolls into CSPRNG output. Rolls are digits 1 to 6,
+// and anything else but whitespace is an error
+func NewDiceSource(rolls string) (Source, error) {
+	var samples []byte
+	for _, c := range rolls {
+		switch {
+		case c >= '1' && c <= '6':
+			samples = append(samples, byte(c))
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			return nil, fmt.Errorf("invalid dice roll %q", c)
+		}
+	}
+	if len(samples) < DiceRolls {
+		return nil, fmt.Errorf("got %d dice rolls, need at least %d", len(samples), DiceRolls)
+	}
+	if err := newHealthTest(math.Log2(6)).check(samples); err != nil {
+		return nil, fmt.Errorf("dice rolls: %v", err)
+	}
+	return &diceSource{seed: Sha3(append([]byte("dice"), samples...))}, nil
+}
+
+func (s *diceSource) Name() string { return "dice" }
+
+func (s *diceSource) Read(p []byte) (int, error) {
+	if _, err := CSPRNG.Read(p); err != nil {
+		return 0, err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	// a fresh keystream for each read
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], s.counter)
+	s.counter++
+	mixStream(p, Sha3(append(append([]byte{}, s.seed...), counter[:]...)))
+	return len(p), nil
+}
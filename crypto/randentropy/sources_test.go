@@ -0,0 +1,127 @@
+package randentropy
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHealthTests(t *testing.T) {
+	random, err := GetEntropyCSPRNG(1 << 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newHealthTest(8).check(random); err != nil {
+		t.Fatal(err)
+	}
+	stuck := bytes.Repeat([]byte{0x42}, 64)
+	if err := newHealthTest(8).check(stuck); err == nil || !strings.Contains(err.Error(), "repetition count") {
+		t.Fatalf("Expected the repetition count test to fail, got %v", err)
+	}
+	// biased but never repeating
+	biased := make([]byte, aptWindow)
+	for i := range biased {
+		if i%2 == 1 {
+			biased[i] = byte(i)
+		}
+	}
+	if err := newHealthTest(8).check(biased); err == nil || !strings.Contains(err.Error(), "adaptive proportion") {
+		t.Fatalf("Expected the adaptive proportion test to fail, got %v", err)
+	}
+}
+
+func TestMixedFillsBuffer(t *testing.T) {
+	// every byte of a long read is mixed
+	b := make([]byte, 100)
+	if _, err := Mixed.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	b1, _ := GetEntropyMixed(64)
+	b2, _ := GetEntropyMixed(64)
+	if bytes.Equal(b[32:64], make([]byte, 32)) || bytes.Equal(b1, b2) {
+		t.Fatal("Mixed output is not random")
+	}
+}
+
+func TestDiceSource(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var rolls []string
+	for i := 0; i < DiceRolls; i++ {
+		rolls = append(rolls, string('1'+byte(r.Intn(6))))
+	}
+	if _, err := ParseSource("dice:" + strings.Join(rolls[:DiceRolls-1], "")); err == nil {
+		t.Fatal("Expected an error for too few rolls")
+	}
+	src, err := ParseSource("dice:" + strings.Join(rolls, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1, b2 := make([]byte, 32), make([]byte, 32)
+	src.Read(b1)
+	src.Read(b2)
+	if bytes.Equal(b1, b2) {
+		t.Fatal("Dice source repeated its output")
+	}
+	if _, err := NewDiceSource(strings.Repeat("1", DiceRolls)); err == nil {
+		t.Fatal("Expected the health tests to fail for a stuck die")
+	}
+	if _, err := NewDiceSource(strings.Repeat("7", DiceRolls)); err == nil {
+		t.Fatal("Expected an error for an invalid roll")
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "randentropy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	good := make([]byte, fileStartupSamples+64)
+	crand.Read(good)
+	goodPath := filepath.Join(dir, "good")
+	ioutil.WriteFile(goodPath, good, 0600)
+	fileSrc, err := NewFileSource(goodPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileSrc.(io.Closer).Close()
+	bytesSrc, err := ParseSource("bytes:" + hex.EncodeToString(good))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []Source{fileSrc, bytesSrc} {
+		b := make([]byte, 64)
+		if _, err := src.Read(b); err != nil {
+			t.Fatal(err)
+		}
+		// the file is used up
+		if _, err := src.Read(b); err == nil {
+			t.Fatalf("Expected an error reading past the end of %s", src.Name())
+		}
+	}
+
+	zeroPath := filepath.Join(dir, "zero")
+	ioutil.WriteFile(zeroPath, make([]byte, fileStartupSamples), 0600)
+	if _, err := NewFileSource(zeroPath); err == nil {
+		t.Fatal("Expected the startup health test to fail for a file of zeros")
+	}
+
+	if _, err := NewBytesSource(good[:fileStartupSamples-1]); err == nil {
+		t.Fatal("Expected an error for too few bytes")
+	}
+
+	// the daemon parses specs from clients, so it mustn't open files
+	for _, spec := range []string{"bogus", "file:" + goodPath, "bytes:zz"} {
+		if _, err := ParseSource(spec); err == nil {
+			t.Fatalf("Expected an error for %s", spec)
+		}
+	}
+}
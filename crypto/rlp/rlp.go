@@ -0,0 +1,49 @@
+// Package rlp implements the encoding side of ethereum's
+// Recursive Length Prefix serialization, enough to build transactions.
+// See https://github.com/ethereum/wiki/wiki/RLP
+package rlp
+
+import (
+	"math/big"
+)
+
+// EncodeBytes encodes a byte string
+func EncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(encodeLength(len(b), 0x80), b...)
+}
+
+// EncodeBigInt encodes a non-negative integer as its minimal big endian bytes.
+// Zero is the empty string
+func EncodeBigInt(i *big.Int) []byte {
+	if i == nil {
+		return EncodeBytes(nil)
+	}
+	return EncodeBytes(i.Bytes())
+}
+
+// EncodeUint encodes an unsigned integer
+func EncodeUint(i uint64) []byte {
+	return EncodeBigInt(new(big.Int).SetUint64(i))
+}
+
+// EncodeList encodes a list of already encoded items
+func EncodeList(items ...[]byte) []byte {
+	var payload []byte
+	for _, item := range items {
+		payload = append(payload, item...)
+	}
+	return append(encodeLength(len(payload), 0xc0), payload...)
+}
+
+// encodeLength returns the prefix for a payload of length l,
+// where offset is 0x80 for strings and 0xc0 for lists
+func encodeLength(l int, offset byte) []byte {
+	if l < 56 {
+		return []byte{offset + byte(l)}
+	}
+	lBytes := big.NewInt(int64(l)).Bytes()
+	return append([]byte{offset + 55 + byte(len(lBytes))}, lBytes...)
+}
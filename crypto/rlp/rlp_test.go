@@ -0,0 +1,46 @@
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// vectors from https://github.com/ethereum/tests/blob/develop/RLPTests/rlptest.json
+func TestEncode(t *testing.T) {
+	bigInt, _ := new(big.Int).SetString("83729609699884896815286331701780722", 10)
+	cases := []struct {
+		name string
+		enc  []byte
+		exp  string
+	}{
+		{"emptystring", EncodeBytes(nil), "80"},
+		{"bytestring00", EncodeBytes([]byte{0}), "00"},
+		{"bytestring7F", EncodeBytes([]byte{0x7f}), "7f"},
+		{"shortstring", EncodeBytes([]byte("dog")), "83646f67"},
+		{"longstring", EncodeBytes([]byte("Lorem ipsum dolor sit amet, consectetur adipisicing elit")),
+			"b8384c6f72656d20697073756d20646f6c6f722073697420616d65742c20636f6e7365637465747572206164697069736963696e6720656c6974"},
+		{"zero", EncodeUint(0), "80"},
+		{"smallint", EncodeUint(1), "01"},
+		{"mediumint1", EncodeUint(100000), "830186a0"},
+		{"bigint", EncodeBigInt(bigInt), "8f102030405060708090a0b0c0d0e0f2"},
+		{"emptylist", EncodeList(), "c0"},
+		{"stringlist", EncodeList(EncodeBytes([]byte("dog")), EncodeBytes([]byte("god")), EncodeBytes([]byte("cat"))), "cc83646f6783676f6483636174"},
+		{"multilist", EncodeList(EncodeBytes([]byte("zw")), EncodeList(EncodeUint(4)), EncodeUint(1)), "c6827a77c10401"},
+		{"listsoflists", EncodeList(EncodeList(EncodeList(), EncodeList()), EncodeList()), "c4c2c0c0c0"},
+		{"longlist", EncodeList(
+			EncodeList(EncodeBytes([]byte("asdf")), EncodeBytes([]byte("qwer")), EncodeBytes([]byte("zxcv"))),
+			EncodeList(EncodeBytes([]byte("asdf")), EncodeBytes([]byte("qwer")), EncodeBytes([]byte("zxcv"))),
+			EncodeList(EncodeBytes([]byte("asdf")), EncodeBytes([]byte("qwer")), EncodeBytes([]byte("zxcv"))),
+			EncodeList(EncodeBytes([]byte("asdf")), EncodeBytes([]byte("qwer")), EncodeBytes([]byte("zxcv")))),
+			"f840" + strings.Repeat("cf84617364668471776572847a786376", 4)},
+	}
+	for _, c := range cases {
+		exp, _ := hex.DecodeString(c.exp)
+		if !bytes.Equal(c.enc, exp) {
+			t.Errorf("%s: got %x, expected %s", c.name, c.enc, c.exp)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+	"github.com/eris-ltd/eris-keys/crypto/secp256k1"
+)
+
+//-----------------------------------------------------------------------------
+// signature schemes
+//
+// secp256k1 keys sign with ECDSA by default, or with BIP-340 Schnorr.
+// Schnorr signatures are 64 bytes and verify against the 32 byte x-only pubkey
+
+const (
+	SigSchemeECDSA   = "ecdsa"
+	SigSchemeSchnorr = "schnorr"
+)
+
+// SignScheme signs with the given scheme. An empty scheme is the key's default
+func (k *Key) SignScheme(msg []byte, scheme string) ([]byte, error) {
+	switch scheme {
+	case "":
+		return k.Sign(msg)
+	case SigSchemeECDSA:
+		if k.Type.CurveType != CurveTypeSecp256k1 && k.Type.CurveType != CurveTypeSecp256r1 {
+			return nil, fmt.Errorf("%s keys can't make ecdsa signatures", k.Type.CurveType)
+		}
+		return k.Sign(msg)
+	case SigSchemeSchnorr:
+		if k.Type.CurveType != CurveTypeSecp256k1 {
+			return nil, fmt.Errorf("schnorr signatures are only supported for secp256k1 keys")
+		}
+		aux, err := randentropy.GetEntropyCSPRNG(32)
+		if err != nil {
+			return nil, err
+		}
+		return secp256k1.SchnorrSign(msg, k.PrivateKey, aux)
+	}
+	return nil, fmt.Errorf("unknown signature scheme %s", scheme)
+}
+
+// VerifyScheme verifies a signature made with the given scheme. An empty scheme is the curve's default.
+// Schnorr pubkeys may be given x-only, compressed or uncompressed
+func VerifyScheme(curveType CurveType, scheme string, msg, sig, pub []byte) (bool, error) {
+	switch scheme {
+	case "":
+		return Verify(curveType, msg, sig, pub)
+	case SigSchemeECDSA:
+		if curveType != CurveTypeSecp256k1 && curveType != CurveTypeSecp256r1 {
+			return false, fmt.Errorf("%s keys can't make ecdsa signatures", curveType)
+		}
+		return Verify(curveType, msg, sig, pub)
+	case SigSchemeSchnorr:
+		if curveType != CurveTypeSecp256k1 {
+			return false, fmt.Errorf("schnorr signatures are only supported for secp256k1 keys")
+		}
+		xOnly, err := XOnlyPubkey(pub)
+		if err != nil {
+			return false, err
+		}
+		return secp256k1.SchnorrVerify(msg, sig, xOnly) == nil, nil
+	}
+	return false, fmt.Errorf("unknown signature scheme %s", scheme)
+}
+
+// XOnlyPubkey returns the 32 byte x-only form of a secp256k1 pubkey, as used by schnorr signatures
+func XOnlyPubkey(pub []byte) ([]byte, error) {
+	if len(pub) == 32 {
+		return pub, nil
+	}
+	return secp256k1.XOnlyPubkey(pub)
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestSignSchnorr(t *testing.T) {
+	key, err := NewKey(KeyType{CurveTypeSecp256k1, AddrTypeSha3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := key.Pubkey()
+	xOnly, err := XOnlyPubkey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xOnly) != 32 {
+		t.Fatalf("Expected a 32 byte x-only pubkey, got %d", len(xOnly))
+	}
+
+	msg := []byte("a message of any length")
+	sig, err := key.SignScheme(msg, SigSchemeSchnorr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != 64 {
+		t.Fatalf("Expected a 64 byte signature, got %d", len(sig))
+	}
+	for _, p := range [][]byte{pub, xOnly} {
+		if res, err := VerifyScheme(CurveTypeSecp256k1, SigSchemeSchnorr, msg, sig, p); err != nil || !res {
+			t.Fatalf("Signature failed to verify against %X: %v", p, err)
+		}
+	}
+	if res, _ := VerifyScheme(CurveTypeSecp256k1, SigSchemeSchnorr, []byte("another message"), sig, xOnly); res {
+		t.Fatal("Signature verified for the wrong message")
+	}
+	// not an ecdsa signature
+	if res, _ := VerifyScheme(CurveTypeSecp256k1, SigSchemeECDSA, Sha256(msg), sig, pub); res {
+		t.Fatal("Schnorr signature verified as ecdsa")
+	}
+
+	ed, _ := NewKey(KeyType{CurveTypeEd25519, AddrTypeRipemd160})
+	if _, err := ed.SignScheme(msg, SigSchemeSchnorr); err == nil {
+		t.Fatal("Expected an error for an ed25519 schnorr signature")
+	}
+	if _, err := key.SignScheme(msg, "rsa"); err == nil {
+		t.Fatal("Expected an error for an unknown scheme")
+	}
+}
@@ -0,0 +1,474 @@
+package secp256k1
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+// Pure Go arithmetic on the curve y^2 = x^3 + 7, for what libsecp256k1 has no API for,
+// and for the pure Go backend. The field arithmetic has no data dependent branches.
+// Secret scalars must only be multiplied with scalarBaseMultCT and scalarMultCT, which
+// use complete addition formulas, fixed windows and constant time table lookups.
+// scalarBaseMult and scalarMult are faster but variable time, for verifying
+
+var (
+	fieldP, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	curveGx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+	curveGy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+
+	// p-2, for inverses, and (p+1)/4, for square roots since p = 3 mod 4
+	fieldInvExp  = new(big.Int).Sub(fieldP, big.NewInt(2))
+	fieldSqrtExp = new(big.Int).Rsh(new(big.Int).Add(fieldP, big.NewInt(1)), 2)
+)
+
+//------------------------------------------------------------------------
+// field elements
+
+// fieldElem is an element of the field mod p as little endian 64 bit limbs, always reduced
+type fieldElem [4]uint64
+
+// 2^256 - p, so 2^256 = fieldC mod p
+const fieldC = 0x1000003D1
+
+var fieldPElem = fieldFromBig(fieldP)
+
+func fieldFromBig(x *big.Int) (r fieldElem) {
+	b := x.FillBytes(make([]byte, 32))
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			r[i] |= uint64(b[31-8*i-j]) << (8 * uint(j))
+		}
+	}
+	return r
+}
+
+func (a fieldElem) big() *big.Int {
+	b := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[31-8*i-j] = byte(a[i] >> (8 * uint(j)))
+		}
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func (a fieldElem) isZero() bool {
+	return a[0]|a[1]|a[2]|a[3] == 0
+}
+
+// fieldSelect returns b if mask is all ones and a if it's zero, in constant time
+func fieldSelect(a, b fieldElem, mask uint64) fieldElem {
+	for i := range a {
+		a[i] ^= mask & (a[i] ^ b[i])
+	}
+	return a
+}
+
+// ctEqual returns all ones if a == b, else zero, in constant time
+func ctEqual(a, b uint64) uint64 {
+	x := a ^ b
+	return ((x | -x) >> 63) - 1
+}
+
+// addCMasked returns a + (fieldC & mask) mod 2^256
+func (a fieldElem) addCMasked(mask uint64) (r fieldElem) {
+	var carry uint64
+	r[0], carry = bits.Add64(a[0], fieldC&mask, 0)
+	r[1], carry = bits.Add64(a[1], 0, carry)
+	r[2], carry = bits.Add64(a[2], 0, carry)
+	r[3], _ = bits.Add64(a[3], 0, carry)
+	return r
+}
+
+// addC returns a + fieldC and the carry out of 2^256
+func (a fieldElem) addC() (r fieldElem, carry uint64) {
+	r[0], carry = bits.Add64(a[0], fieldC, 0)
+	r[1], carry = bits.Add64(a[1], 0, carry)
+	r[2], carry = bits.Add64(a[2], 0, carry)
+	r[3], carry = bits.Add64(a[3], 0, carry)
+	return r, carry
+}
+
+// reduceOnce subtracts p from a if a >= p
+func (a fieldElem) reduceOnce() fieldElem {
+	// a >= p exactly when a + 2^256 - p overflows, and then the sum is a - p
+	r, carry := a.addC()
+	return fieldSelect(a, r, -carry)
+}
+
+func fieldAdd(a, b fieldElem) (r fieldElem) {
+	var carry uint64
+	r[0], carry = bits.Add64(a[0], b[0], 0)
+	r[1], carry = bits.Add64(a[1], b[1], carry)
+	r[2], carry = bits.Add64(a[2], b[2], carry)
+	r[3], carry = bits.Add64(a[3], b[3], carry)
+	// on a carry, a + b - 2^256 < p, so adding 2^256 - p can't overflow again
+	return fieldSelect(r.reduceOnce(), r.addCMasked(^uint64(0)), -carry)
+}
+
+func fieldSub(a, b fieldElem) (r fieldElem) {
+	var borrow uint64
+	r[0], borrow = bits.Sub64(a[0], b[0], 0)
+	r[1], borrow = bits.Sub64(a[1], b[1], borrow)
+	r[2], borrow = bits.Sub64(a[2], b[2], borrow)
+	r[3], borrow = bits.Sub64(a[3], b[3], borrow)
+	// on a borrow, add p back, which is subtracting 2^256 - p mod 2^256
+	mask := -borrow
+	r[0], borrow = bits.Sub64(r[0], fieldC&mask, 0)
+	r[1], borrow = bits.Sub64(r[1], 0, borrow)
+	r[2], borrow = bits.Sub64(r[2], 0, borrow)
+	r[3], _ = bits.Sub64(r[3], 0, borrow)
+	return r
+}
+
+func fieldMul(a, b fieldElem) fieldElem {
+	var t [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var c uint64
+			lo, c = bits.Add64(lo, t[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[i+j], carry = lo, hi
+		}
+		t[i+4] = carry
+	}
+
+	// t = lo + hi*2^256 = lo + hi*fieldC mod p
+	var r fieldElem
+	var top uint64
+	for i := 0; i < 4; i++ {
+		hi, lo := bits.Mul64(t[i+4], fieldC)
+		var c uint64
+		lo, c = bits.Add64(lo, t[i], 0)
+		hi += c
+		lo, c = bits.Add64(lo, top, 0)
+		hi += c
+		r[i], top = lo, hi
+	}
+	// and once more for what's left above 2^256, which is under 2^34
+	hi, lo := bits.Mul64(top, fieldC)
+	var carry uint64
+	r[0], carry = bits.Add64(r[0], lo, 0)
+	r[1], carry = bits.Add64(r[1], hi, carry)
+	r[2], carry = bits.Add64(r[2], 0, carry)
+	r[3], carry = bits.Add64(r[3], 0, carry)
+	// on a carry, r is now tiny, so this can't overflow
+	return r.addCMasked(-carry).reduceOnce()
+}
+
+func fieldExp(a fieldElem, e *big.Int) fieldElem {
+	r := fieldElem{1}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = fieldMul(r, r)
+		if e.Bit(i) == 1 {
+			r = fieldMul(r, a)
+		}
+	}
+	return r
+}
+
+func fieldInv(a fieldElem) fieldElem {
+	return fieldExp(a, fieldInvExp)
+}
+
+//------------------------------------------------------------------------
+// points
+
+// jacobian coordinates (x/z^2, y/z^3). z = 0 is the point at infinity
+type jacobianPoint struct {
+	x, y, z fieldElem
+}
+
+func newAffinePoint(x, y *big.Int) *jacobianPoint {
+	return &jacobianPoint{fieldFromBig(x), fieldFromBig(y), fieldElem{1}}
+}
+
+func infinity() *jacobianPoint {
+	return &jacobianPoint{}
+}
+
+func (p *jacobianPoint) isInfinity() bool {
+	return p.z.isZero()
+}
+
+// affine returns the affine x and y. p must not be the point at infinity
+func (p *jacobianPoint) affine() (x, y *big.Int) {
+	zInv := fieldInv(p.z)
+	zInv2 := fieldMul(zInv, zInv)
+	return fieldMul(p.x, zInv2).big(), fieldMul(p.y, fieldMul(zInv2, zInv)).big()
+}
+
+func pointDouble(p *jacobianPoint) *jacobianPoint {
+	if p.isInfinity() || p.y.isZero() {
+		return infinity()
+	}
+	a := fieldMul(p.x, p.x)
+	b := fieldMul(p.y, p.y)
+	c := fieldMul(b, b)
+	xb := fieldAdd(p.x, b)
+	d := fieldSub(fieldSub(fieldMul(xb, xb), a), c)
+	d = fieldAdd(d, d)
+	e := fieldAdd(fieldAdd(a, a), a)
+	f := fieldMul(e, e)
+
+	x3 := fieldSub(f, fieldAdd(d, d))
+	c2 := fieldAdd(c, c)
+	c4 := fieldAdd(c2, c2)
+	c8 := fieldAdd(c4, c4)
+	y3 := fieldSub(fieldMul(e, fieldSub(d, x3)), c8)
+	z3 := fieldMul(fieldAdd(p.y, p.y), p.z)
+	return &jacobianPoint{x3, y3, z3}
+}
+
+func pointAdd(p, q *jacobianPoint) *jacobianPoint {
+	if p.isInfinity() {
+		return q
+	}
+	if q.isInfinity() {
+		return p
+	}
+	z1z1 := fieldMul(p.z, p.z)
+	z2z2 := fieldMul(q.z, q.z)
+	u1 := fieldMul(p.x, z2z2)
+	u2 := fieldMul(q.x, z1z1)
+	s1 := fieldMul(p.y, fieldMul(q.z, z2z2))
+	s2 := fieldMul(q.y, fieldMul(p.z, z1z1))
+	if u1 == u2 {
+		if s1 == s2 {
+			return pointDouble(p)
+		}
+		return infinity()
+	}
+
+	h := fieldSub(u2, u1)
+	h2 := fieldAdd(h, h)
+	i := fieldMul(h2, h2)
+	j := fieldMul(h, i)
+	r := fieldSub(s2, s1)
+	r = fieldAdd(r, r)
+	v := fieldMul(u1, i)
+
+	x3 := fieldSub(fieldSub(fieldMul(r, r), j), fieldAdd(v, v))
+	s1j := fieldMul(s1, j)
+	y3 := fieldSub(fieldMul(r, fieldSub(v, x3)), fieldAdd(s1j, s1j))
+	zz := fieldAdd(p.z, q.z)
+	z3 := fieldMul(fieldSub(fieldSub(fieldMul(zz, zz), z1z1), z2z2), h)
+	return &jacobianPoint{x3, y3, z3}
+}
+
+func pointNeg(p *jacobianPoint) *jacobianPoint {
+	return &jacobianPoint{p.x, fieldSub(fieldElem{}, p.y), p.z}
+}
+
+// nibble returns the i'th 4 bit window of k
+func nibble(k *big.Int, i int) uint {
+	return k.Bit(4*i) | k.Bit(4*i+1)<<1 | k.Bit(4*i+2)<<2 | k.Bit(4*i+3)<<3
+}
+
+// scalarMult returns k*p with 4 bit windows
+func scalarMult(p *jacobianPoint, k *big.Int) *jacobianPoint {
+	var multiples [16]*jacobianPoint
+	multiples[1] = p
+	for j := 2; j < 16; j++ {
+		multiples[j] = pointAdd(multiples[j-1], p)
+	}
+	r := infinity()
+	for i := (k.BitLen()+3)/4 - 1; i >= 0; i-- {
+		for j := 0; j < 4; j++ {
+			r = pointDouble(r)
+		}
+		if n := nibble(k, i); n != 0 {
+			r = pointAdd(r, multiples[n])
+		}
+	}
+	return r
+}
+
+var (
+	// baseTable[i][j] is j*16^i*G, in affine coordinates
+	baseTable     [64][16]*jacobianPoint
+	baseTableOnce sync.Once
+)
+
+func makeBaseTable() {
+	g := newAffinePoint(curveGx, curveGy)
+	for i := range baseTable {
+		p := infinity()
+		baseTableCT[i][0] = projectiveInfinity()
+		for j := 1; j < 16; j++ {
+			p = pointAdd(p, g)
+			baseTable[i][j] = newAffinePoint(p.affine())
+			baseTableCT[i][j] = projectivePoint{baseTable[i][j].x, baseTable[i][j].y, fieldElem{1}}
+		}
+		g = pointAdd(p, g)
+	}
+}
+
+// scalarBaseMult returns k*G by adding up precomputed multiples of G. k must be less than 2^256
+func scalarBaseMult(k *big.Int) *jacobianPoint {
+	baseTableOnce.Do(makeBaseTable)
+	r := infinity()
+	for i := 0; i < 64; i++ {
+		if n := nibble(k, i); n != 0 {
+			r = pointAdd(r, baseTable[i][n])
+		}
+	}
+	return r
+}
+
+//------------------------------------------------------------------------
+// constant time scalar multiplication
+
+// projectivePoint is in homogeneous coordinates (x/z, y/z), for the complete addition
+// formulas of Renes, Costello and Batina (https://eprint.iacr.org/2015/1060, algorithm 7).
+// They have no special cases for doubling or the point at infinity, (0, 1, 0),
+// so adding secret dependent points takes no secret dependent branches
+type projectivePoint struct {
+	x, y, z fieldElem
+}
+
+// 3*b for b = 7
+var fieldB3 = fieldElem{21}
+
+func projectiveInfinity() projectivePoint {
+	return projectivePoint{y: fieldElem{1}}
+}
+
+func projectiveAdd(p, q *projectivePoint) projectivePoint {
+	t0 := fieldMul(p.x, q.x)
+	t1 := fieldMul(p.y, q.y)
+	t2 := fieldMul(p.z, q.z)
+	t3 := fieldMul(fieldAdd(p.x, p.y), fieldAdd(q.x, q.y))
+	t3 = fieldSub(t3, fieldAdd(t0, t1))
+	t4 := fieldMul(fieldAdd(p.y, p.z), fieldAdd(q.y, q.z))
+	t4 = fieldSub(t4, fieldAdd(t1, t2))
+	y3 := fieldMul(fieldAdd(p.x, p.z), fieldAdd(q.x, q.z))
+	y3 = fieldSub(y3, fieldAdd(t0, t2))
+	t0 = fieldAdd(fieldAdd(t0, t0), t0)
+	t2 = fieldMul(fieldB3, t2)
+	z3 := fieldAdd(t1, t2)
+	t1 = fieldSub(t1, t2)
+	y3 = fieldMul(fieldB3, y3)
+	x3 := fieldSub(fieldMul(t3, t1), fieldMul(t4, y3))
+	y3 = fieldAdd(fieldMul(t1, z3), fieldMul(y3, t0))
+	z3 = fieldAdd(fieldMul(z3, t4), fieldMul(t0, t3))
+	return projectivePoint{x3, y3, z3}
+}
+
+// projectiveSelect returns table[n], reading every entry
+func projectiveSelect(table *[16]projectivePoint, n uint64) (r projectivePoint) {
+	for j := range table {
+		mask := ctEqual(uint64(j), n)
+		r.x = fieldSelect(r.x, table[j].x, mask)
+		r.y = fieldSelect(r.y, table[j].y, mask)
+		r.z = fieldSelect(r.z, table[j].z, mask)
+	}
+	return r
+}
+
+func (p *projectivePoint) jacobian() *jacobianPoint {
+	return &jacobianPoint{fieldMul(p.x, p.z), fieldMul(p.y, fieldMul(p.z, p.z)), p.z}
+}
+
+// scalarNibbles returns the 64 4 bit windows of k, least significant first. k must be less than 2^256
+func scalarNibbles(k *big.Int) (n [64]uint64) {
+	b := k.FillBytes(make([]byte, 32))
+	for i := range n {
+		n[i] = uint64(b[31-i/2]>>(4*uint(i%2))) & 15
+	}
+	return n
+}
+
+// baseTableCT[i][j] is j*16^i*G, with the point at infinity for j = 0
+var baseTableCT [64][16]projectivePoint
+
+// scalarBaseMultCT returns k*G in constant time, adding one entry
+// of each row of the table for every window, zero or not. k must be less than 2^256
+func scalarBaseMultCT(k *big.Int) *jacobianPoint {
+	baseTableOnce.Do(makeBaseTable)
+	r := projectiveInfinity()
+	for i, n := range scalarNibbles(k) {
+		q := projectiveSelect(&baseTableCT[i], n)
+		r = projectiveAdd(&r, &q)
+	}
+	return r.jacobian()
+}
+
+// scalarMultCT returns k*p in constant time with fixed 4 bit windows. k must be less than 2^256
+func scalarMultCT(p *jacobianPoint, k *big.Int) *jacobianPoint {
+	var table [16]projectivePoint
+	table[0] = projectiveInfinity()
+	if !p.isInfinity() {
+		x, y := p.affine()
+		table[1] = projectivePoint{fieldFromBig(x), fieldFromBig(y), fieldElem{1}}
+	} else {
+		table[1] = table[0]
+	}
+	for j := 2; j < 16; j++ {
+		table[j] = projectiveAdd(&table[j-1], &table[1])
+	}
+	nibbles := scalarNibbles(k)
+	r := projectiveInfinity()
+	for i := len(nibbles) - 1; i >= 0; i-- {
+		for j := 0; j < 4; j++ {
+			r = projectiveAdd(&r, &r)
+		}
+		q := projectiveSelect(&table, nibbles[i])
+		r = projectiveAdd(&r, &q)
+	}
+	return r.jacobian()
+}
+
+// onCurve checks y^2 = x^3 + 7
+func onCurve(x, y fieldElem) bool {
+	return fieldMul(y, y) == fieldAdd(fieldMul(fieldMul(x, x), x), fieldElem{7})
+}
+
+// liftX returns the point with the given x coordinate and an even y
+func liftX(x *big.Int) (*jacobianPoint, error) {
+	if x.Cmp(fieldP) >= 0 {
+		return nil, errors.New("x coordinate exceeds the field size")
+	}
+	xe := fieldFromBig(x)
+	c := fieldAdd(fieldMul(fieldMul(xe, xe), xe), fieldElem{7})
+	y := fieldExp(c, fieldSqrtExp)
+	if fieldMul(y, y) != c {
+		return nil, errors.New("x coordinate is not on the curve")
+	}
+	if y[0]&1 == 1 {
+		y = fieldSub(fieldElem{}, y)
+	}
+	return &jacobianPoint{xe, y, fieldElem{1}}, nil
+}
+
+// parsePubkey decodes a 33 byte compressed or 65 byte uncompressed pubkey
+func parsePubkey(pub []byte) (*jacobianPoint, error) {
+	switch {
+	case len(pub) == 33 && (pub[0] == 0x02 || pub[0] == 0x03):
+		p, err := liftX(new(big.Int).SetBytes(pub[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if pub[0] == 0x03 {
+			p = pointNeg(p)
+		}
+		return p, nil
+	case len(pub) == 65 && pub[0] == 0x04:
+		x, y := new(big.Int).SetBytes(pub[1:33]), new(big.Int).SetBytes(pub[33:])
+		if x.Cmp(fieldP) >= 0 || y.Cmp(fieldP) >= 0 {
+			return nil, errors.New("pubkey coordinate exceeds the field size")
+		}
+		p := newAffinePoint(x, y)
+		if !onCurve(p.x, p.y) {
+			return nil, errors.New("pubkey is not on the curve")
+		}
+		return p, nil
+	}
+	return nil, errors.New("invalid pubkey encoding")
+}
@@ -0,0 +1,80 @@
+package secp256k1
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestFieldArithmetic(t *testing.T) {
+	edges := []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(fieldP, big.NewInt(1)), new(big.Int).Sub(fieldP, big.NewInt(fieldC))}
+	var values []*big.Int
+	values = append(values, edges...)
+	for i := 0; i < 100; i++ {
+		v, _ := rand.Int(rand.Reader, fieldP)
+		values = append(values, v)
+	}
+	for i, a := range values {
+		b := values[(i*7+3)%len(values)]
+		ae, be := fieldFromBig(a), fieldFromBig(b)
+		if ae.big().Cmp(a) != 0 {
+			t.Fatalf("%X didn't survive conversion", a)
+		}
+		check := func(op string, got fieldElem, want *big.Int) {
+			want.Mod(want, fieldP)
+			if got.big().Cmp(want) != 0 {
+				t.Fatalf("%X %s %X = %X, expected %X", a, op, b, got.big(), want)
+			}
+		}
+		check("+", fieldAdd(ae, be), new(big.Int).Add(a, b))
+		check("-", fieldSub(ae, be), new(big.Int).Sub(a, b))
+		check("*", fieldMul(ae, be), new(big.Int).Mul(a, b))
+		if a.Sign() != 0 {
+			check("inv", fieldInv(ae), new(big.Int).ModInverse(a, fieldP))
+		}
+	}
+}
+
+func TestScalarMultCT(t *testing.T) {
+	nMinus1 := new(big.Int).Sub(curveN, big.NewInt(1))
+	scalars := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(15), big.NewInt(16), nMinus1}
+	for i := 0; i < 20; i++ {
+		k, _ := rand.Int(rand.Reader, curveN)
+		scalars = append(scalars, k)
+	}
+	p := scalarBaseMult(big.NewInt(12345))
+	for _, k := range scalars {
+		checkSamePoint(t, k, scalarBaseMultCT(k), scalarBaseMult(k))
+		checkSamePoint(t, k, scalarMultCT(p, k), scalarMult(p, k))
+	}
+}
+
+func checkSamePoint(t *testing.T, k *big.Int, got, want *jacobianPoint) {
+	if got.isInfinity() || want.isInfinity() {
+		if got.isInfinity() != want.isInfinity() {
+			t.Fatalf("Wrong point at infinity for %X", k)
+		}
+		return
+	}
+	gx, gy := got.affine()
+	wx, wy := want.affine()
+	if gx.Cmp(wx) != 0 || gy.Cmp(wy) != 0 {
+		t.Fatalf("Wrong multiple of %X", k)
+	}
+}
+
+func TestProjectiveAdd(t *testing.T) {
+	x, y := scalarBaseMult(big.NewInt(7)).affine()
+	p := projectivePoint{fieldFromBig(x), fieldFromBig(y), fieldElem{1}}
+	inf := projectiveInfinity()
+
+	// doubling and the point at infinity take the same formulas
+	double := projectiveAdd(&p, &p)
+	checkSamePoint(t, big.NewInt(14), double.jacobian(), scalarBaseMult(big.NewInt(14)))
+	sum := projectiveAdd(&p, &inf)
+	checkSamePoint(t, big.NewInt(7), sum.jacobian(), scalarBaseMult(big.NewInt(7)))
+	neg := projectivePoint{p.x, fieldSub(fieldElem{}, p.y), p.z}
+	if sum = projectiveAdd(&p, &neg); !sum.z.isZero() {
+		t.Fatal("P + -P is not the point at infinity")
+	}
+}
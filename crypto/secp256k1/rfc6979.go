@@ -0,0 +1,104 @@
+package secp256k1
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+	"math/big"
+)
+
+// Deterministic signing nonces per RFC 6979 section 3.2, with HMAC-SHA256.
+// The nonce is made here rather than by libsecp256k1 so it can be audited from Go:
+// the C code is only handed the finished nonce
+
+// rfc6979 generates the candidate nonces for one signature
+type rfc6979 struct {
+	q    *big.Int
+	qlen int
+	newH func() hash.Hash
+	k, v []byte
+	used bool // a candidate was returned, so k and v must be updated before the next
+}
+
+// newRFC6979 starts the nonce generation for the secret key x and message hash h,
+// in a group of order q. extra, if not empty, is the additional data k' of section 3.6,
+// which is mixed in after the key and hash
+func newRFC6979(q *big.Int, newH func() hash.Hash, x, h, extra []byte) *rfc6979 {
+	g := &rfc6979{q: q, qlen: q.BitLen(), newH: newH}
+	hlen := newH().Size()
+	g.v = make([]byte, hlen)
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	g.k = make([]byte, hlen)
+
+	xb := g.int2octets(new(big.Int).SetBytes(x))
+	hb := g.bits2octets(h)
+	g.k = g.mac(g.k, g.v, []byte{0x00}, xb, hb, extra)
+	g.v = g.mac(g.k, g.v)
+	g.k = g.mac(g.k, g.v, []byte{0x01}, xb, hb, extra)
+	g.v = g.mac(g.k, g.v)
+	return g
+}
+
+func (g *rfc6979) mac(key []byte, data ...[]byte) []byte {
+	m := hmac.New(g.newH, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// bits2int takes the leftmost qlen bits of b as an integer (section 2.3.2)
+func (g *rfc6979) bits2int(b []byte) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if blen := len(b) * 8; blen > g.qlen {
+		i.Rsh(i, uint(blen-g.qlen))
+	}
+	return i
+}
+
+// int2octets is the big endian encoding of i in as many bytes as q (section 2.3.3)
+func (g *rfc6979) int2octets(i *big.Int) []byte {
+	rlen := (g.qlen + 7) / 8
+	b := i.Bytes()
+	if len(b) > rlen {
+		return b[len(b)-rlen:]
+	}
+	return append(make([]byte, rlen-len(b)), b...)
+}
+
+// bits2octets reduces the hash mod q (section 2.3.4)
+func (g *rfc6979) bits2octets(h []byte) []byte {
+	z := g.bits2int(h)
+	if z.Cmp(g.q) >= 0 {
+		z.Sub(z, g.q)
+	}
+	return g.int2octets(z)
+}
+
+// next returns the next candidate nonce, in [1, q-1] (section 3.2 step h).
+// It's only called again if the last one couldn't make a signature
+func (g *rfc6979) next() *big.Int {
+	for {
+		if g.used {
+			g.k = g.mac(g.k, g.v, []byte{0x00})
+			g.v = g.mac(g.k, g.v)
+		}
+		g.used = true
+		var t []byte
+		for len(t)*8 < g.qlen {
+			g.v = g.mac(g.k, g.v)
+			t = append(t, g.v...)
+		}
+		if k := g.bits2int(t); k.Sign() > 0 && k.Cmp(g.q) < 0 {
+			return k
+		}
+	}
+}
+
+// NonceRFC6979 returns the first RFC 6979 signing nonce for the secp256k1 secret key
+// and 32 byte hash, as used by Sign. extra is the optional additional data
+func NonceRFC6979(seckey, hash, extra []byte) []byte {
+	return bytes32(newRFC6979(curveN, sha256.New, seckey, hash, extra).next())
+}
@@ -0,0 +1,111 @@
+package secp256k1
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func fromHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// RFC 6979 appendix A.2.5, ECDSA with P-256 and SHA-256
+func TestRFC6979P256(t *testing.T) {
+	curve := elliptic.P256()
+	x := fromHex("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+	for _, v := range []struct {
+		msg, k, r string
+	}{
+		{"sample", "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60", "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716"},
+		{"test", "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0", "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367"},
+	} {
+		h := sha256.Sum256([]byte(v.msg))
+		k := newRFC6979(curve.Params().N, sha256.New, x, h[:], nil).next()
+		if got := fmt.Sprintf("%064X", k); got != v.k {
+			t.Fatalf("%s: got nonce %s, expected %s", v.msg, got, v.k)
+		}
+		r, _ := curve.ScalarBaseMult(k.Bytes())
+		if got := fmt.Sprintf("%064X", r); got != v.r {
+			t.Fatalf("%s: got r %s, expected %s", v.msg, got, v.r)
+		}
+	}
+}
+
+// secp256k1 with SHA-256, as used by Sign. The signatures have low s.
+// Both backends are run against these: go test, and go test -tags purego
+var rfc6979Vectors = []struct {
+	seckey, msg, k, sig string
+}{
+	{
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"Satoshi Nakamoto",
+		"8F8A276C19F4149656B280621E358CCE24F5F52542772691EE69063B74F15D15",
+		"934B1EA10A4B3C1757E2B0C017D0B6143CE3C9A7E6A4A49860D7A6AB210EE3D82442CE9D2B916064108014783E923EC36B49743E2FFA1C4496F01A512AAFD9E5",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"All those moments will be lost in time, like tears in rain. Time to die...",
+		"38AA22D72376B4DBC472E06C3BA403EE0A394DA63FC58D88686C611ABA98D6B3",
+		"8600DBD41E348FE5C9465AB92D23E3DB8B98B873BEECD930736488696438CB6B547FE64427496DB33BF66019DACBF0039C04199ABB0122918601DB38A72CFC21",
+	},
+}
+
+func TestRFC6979Secp256k1(t *testing.T) {
+	for _, v := range rfc6979Vectors {
+		seckey := fromHex(v.seckey)
+		h := sha256.Sum256([]byte(v.msg))
+		if got := fmt.Sprintf("%X", NonceRFC6979(seckey, h[:], nil)); got != v.k {
+			t.Fatalf("%s: got nonce %s, expected %s", v.msg, got, v.k)
+		}
+		sig, err := Sign(h[:], seckey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprintf("%X", sig[:64]); got != v.sig {
+			t.Fatalf("%s: got signature %s, expected %s", v.msg, got, v.sig)
+		}
+		// r is the x coordinate of kG
+		r, _ := scalarBaseMult(new(big.Int).SetBytes(fromHex(v.k))).affine()
+		if !bytes.Equal(bytes32(r), sig[:32]) {
+			t.Fatalf("%s: r doesn't match the nonce", v.msg)
+		}
+		pub, _ := GeneratePubKey(seckey)
+		if err := VerifySignature(h[:], sig, pub); err != nil {
+			t.Fatal(err)
+		}
+		if recovered, err := RecoverPubkey(h[:], sig); err != nil || !bytes.Equal(recovered, pub) {
+			t.Fatalf("%s: recovered %X, %v", v.msg, recovered, err)
+		}
+	}
+}
+
+func TestSignWithEntropy(t *testing.T) {
+	pub, seckey := generateKeyPair()
+	h := sha256.Sum256([]byte("extra entropy"))
+	sig1, _ := Sign(h[:], seckey)
+	sig2, _ := Sign(h[:], seckey)
+	if !bytes.Equal(sig1, sig2) {
+		t.Fatal("Signatures without extra entropy should be deterministic")
+	}
+	extra := bytes.Repeat([]byte{0x42}, 32)
+	sig3, _ := SignWithEntropy(h[:], seckey, extra)
+	sig4, _ := SignWithEntropy(h[:], seckey, extra)
+	if !bytes.Equal(sig3, sig4) {
+		t.Fatal("Signatures with the same extra data should be the same")
+	}
+	if bytes.Equal(sig1, sig3) {
+		t.Fatal("Extra data didn't change the nonce")
+	}
+	if err := VerifySignature(h[:], sig3, pub); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package secp256k1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// BIP-340 Schnorr signatures, with 32 byte x-only pubkeys
+// and 64 byte R.x||s signatures over messages of any length
+
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func bytes32(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+// XOnlyPubkey returns the 32 byte x coordinate of a 33 or 65 byte pubkey
+func XOnlyPubkey(pubkey []byte) ([]byte, error) {
+	p, err := parsePubkey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	x, _ := p.affine()
+	return bytes32(x), nil
+}
+
+// SchnorrSign signs the message with the 32 byte seckey.
+// auxRand is 32 bytes of fresh randomness mixed into the nonce
+func SchnorrSign(msg, seckey, auxRand []byte) ([]byte, error) {
+	if len(auxRand) != 32 {
+		return nil, errors.New("aux randomness must be 32 bytes")
+	}
+	d := new(big.Int).SetBytes(seckey)
+	if len(seckey) != 32 || d.Sign() == 0 || d.Cmp(curveN) >= 0 {
+		return nil, errors.New("invalid seckey")
+	}
+	px, py := scalarBaseMultCT(d).affine()
+	if py.Bit(0) == 1 {
+		d.Sub(curveN, d)
+	}
+	pubX := bytes32(px)
+
+	t := bytes32(d)
+	for i, b := range taggedHash("BIP0340/aux", auxRand) {
+		t[i] ^= b
+	}
+	k := new(big.Int).SetBytes(taggedHash("BIP0340/nonce", t, pubX, msg))
+	k.Mod(k, curveN)
+	if k.Sign() == 0 {
+		return nil, errors.New("nonce is zero")
+	}
+	rx, ry := scalarBaseMultCT(k).affine()
+	if ry.Bit(0) == 1 {
+		k.Sub(curveN, k)
+	}
+	rX := bytes32(rx)
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", rX, pubX, msg))
+	e.Mod(e, curveN)
+	s := e.Mul(e, d)
+	s.Add(s, k).Mod(s, curveN)
+
+	sig := append(rX, bytes32(s)...)
+	if err := SchnorrVerify(msg, sig, pubX); err != nil {
+		return nil, errors.New("produced an invalid signature")
+	}
+	return sig, nil
+}
+
+// SchnorrVerify returns nil if the 64 byte signature of the message is valid for the x-only pubkey
+func SchnorrVerify(msg, sig, pubkey []byte) error {
+	if len(pubkey) != 32 {
+		return errors.New("schnorr pubkey must be 32 bytes")
+	}
+	if len(sig) != 64 {
+		return errors.New("schnorr signature must be 64 bytes")
+	}
+	p, err := liftX(new(big.Int).SetBytes(pubkey))
+	if err != nil {
+		return err
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	if r.Cmp(fieldP) >= 0 {
+		return errors.New("signature r exceeds the field size")
+	}
+	s := new(big.Int).SetBytes(sig[32:])
+	if s.Cmp(curveN) >= 0 {
+		return errors.New("signature s exceeds the curve order")
+	}
+
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", sig[:32], pubkey, msg))
+	e.Mod(e, curveN)
+	// R = s*G - e*P
+	R := pointAdd(scalarBaseMult(s), pointNeg(scalarMult(p, e)))
+	if R.isInfinity() {
+		return errors.New("invalid signature")
+	}
+	rx, ry := R.affine()
+	if ry.Bit(0) == 1 || !bytes.Equal(bytes32(rx), sig[:32]) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
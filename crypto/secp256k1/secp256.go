@@ -1,3 +1,6 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
 package secp256k1
 
 // TODO: set USE_SCALAR_4X64 depending on platform?
@@ -14,11 +17,25 @@ package secp256k1
 #define USE_SCALAR_INV_BUILTIN
 #define NDEBUG
 #include "./secp256k1/src/secp256k1.c"
+
+// nonce_function_given hands libsecp256k1 the nonce made in Go.
+// It has no other candidates if the nonce is unusable
+static int nonce_function_given(unsigned char *nonce32, const unsigned char *msg32, const unsigned char *key32, unsigned int counter, const void *data) {
+   if (counter != 0) {
+       return 0;
+   }
+   memcpy(nonce32, data, 32);
+   return 1;
+}
+
+static int secp256k1_ecdsa_sign_compact_nonce(const unsigned char *msg32, unsigned char *sig64, const unsigned char *seckey, const unsigned char *nonce32, int *recid) {
+   return secp256k1_ecdsa_sign_compact(msg32, sig64, seckey, nonce_function_given, nonce32, recid);
+}
 */
 import "C"
 
 import (
-	"bytes"
+	"crypto/sha256"
 	"errors"
 	"unsafe"
 
@@ -51,13 +68,16 @@ func Stop() {
 	C.secp256k1_stop()
 }
 
-func GenerateKeyPair() ([]byte, []byte) {
+func GenerateKeyPair() ([]byte, []byte, error) {
 
 	pubkey_len := C.int(65)
 	const seckey_len = 32
 
 	var pubkey []byte = make([]byte, pubkey_len)
-	var seckey []byte = randentropy.GetEntropyCSPRNG(seckey_len)
+	seckey, err := randentropy.GetEntropyCSPRNG(seckey_len)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var pubkey_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&pubkey[0]))
 	var seckey_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&seckey[0]))
@@ -72,7 +92,7 @@ func GenerateKeyPair() ([]byte, []byte) {
 	if ret != C.int(1) {
 		return GenerateKeyPair() //invalid secret, try again
 	}
-	return pubkey, seckey
+	return pubkey, seckey, nil
 }
 
 func GeneratePubKey(seckey []byte) ([]byte, error) {
@@ -99,8 +119,22 @@ func GeneratePubKey(seckey []byte) ([]byte, error) {
 	return pubkey, nil
 }
 
+// Sign makes a 65 byte r||s||recid signature of the 32 byte msg hash,
+// with the deterministic RFC 6979 nonce for the key and hash
 func Sign(msg []byte, seckey []byte) ([]byte, error) {
-	nonce := randentropy.GetEntropyCSPRNG(32)
+	return SignWithEntropy(msg, seckey, nil)
+}
+
+// SignWithEntropy is Sign with extra data, usually 32 random bytes, mixed into
+// the RFC 6979 nonce (section 3.6). The signature is no longer deterministic,
+// but its nonce is still safe if the extra data is not
+func SignWithEntropy(msg, seckey, extra []byte) ([]byte, error) {
+	if len(msg) != 32 {
+		return nil, errors.New("msg hash is not 32 bytes")
+	}
+	if err := VerifySeckeyValidity(seckey); err != nil {
+		return nil, errors.New("Invalid secret key")
+	}
 
 	var sig []byte = make([]byte, 65)
 	var recid C.int
@@ -109,30 +143,24 @@ func Sign(msg []byte, seckey []byte) ([]byte, error) {
 	var sig_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&sig[0]))
 	var seckey_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&seckey[0]))
 
-	var noncefp_ptr = &(*C.secp256k1_nonce_function_default)
-	var ndata_ptr = unsafe.Pointer(&nonce[0])
-
-	if C.secp256k1_ec_seckey_verify(seckey_ptr) != C.int(1) {
-		return nil, errors.New("Invalid secret key")
+	nonces := newRFC6979(curveN, sha256.New, seckey, msg, extra)
+	for {
+		nonce := bytes32(nonces.next())
+		var nonce_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&nonce[0]))
+
+		ret := C.secp256k1_ecdsa_sign_compact_nonce(
+			msg_ptr,
+			sig_ptr,
+			seckey_ptr,
+			nonce_ptr,
+			&recid)
+
+		if ret == C.int(1) {
+			sig[64] = byte(int(recid))
+			return sig, nil
+		}
+		// r or s was zero, try the next nonce
 	}
-
-	ret := C.secp256k1_ecdsa_sign_compact(
-		msg_ptr,
-		sig_ptr,
-		seckey_ptr,
-		noncefp_ptr,
-		ndata_ptr,
-		&recid)
-
-	sig[64] = byte(int(recid))
-
-	if ret != C.int(1) {
-		// nonce invalid, retry
-		return Sign(msg, seckey)
-	}
-
-	return sig, nil
-
 }
 
 func VerifySeckeyValidity(seckey []byte) error {
@@ -147,12 +175,13 @@ func VerifySeckeyValidity(seckey []byte) error {
 	return nil
 }
 
+// VerifyPubkeyValidity accepts compressed (33 byte) and uncompressed (65 byte) pubkeys
 func VerifyPubkeyValidity(pubkey []byte) error {
-	if len(pubkey) != 65 {
-		return errors.New("pub key is not 65 bytes")
+	if len(pubkey) != 33 && len(pubkey) != 65 {
+		return errors.New("pub key is not 33 or 65 bytes")
 	}
 	var pubkey_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&pubkey[0]))
-	ret := C.secp256k1_ec_pubkey_verify(pubkey_ptr, 65)
+	ret := C.secp256k1_ec_pubkey_verify(pubkey_ptr, C.int(len(pubkey)))
 	if int(ret) != 1 {
 		return errors.New("invalid pubkey")
 	}
@@ -165,8 +194,8 @@ func VerifySignatureValidity(sig []byte) bool {
 	if len(sig) != 65 {
 		return false
 	}
-	//malleability check, highest bit must be 1
-	if (sig[32] & 0x80) == 0x80 {
+	//malleability check, S must be in the lower half of the order
+	if !IsLowS(sig[32:64]) {
 		return false
 	}
 	//recovery id check
@@ -177,49 +206,83 @@ func VerifySignatureValidity(sig []byte) bool {
 	return true
 }
 
-//for compressed signatures, does not need pubkey
-func VerifySignature(msg []byte, sig []byte, pubkey1 []byte) error {
-	if msg == nil || sig == nil || pubkey1 == nil {
+// VerifySignature checks an ECDSA signature over the 32 byte msg directly against the pubkey.
+// The signature may be r||s, r||s||recid or DER, and the pubkey compressed or uncompressed.
+// Signatures with high S are refused
+func VerifySignature(msg []byte, sig []byte, pubkey []byte) error {
+	if msg == nil || sig == nil || pubkey == nil {
 		return errors.New("inputs must be non-nil")
 	}
-	if len(sig) != 65 {
-		return errors.New("invalid signature length")
-	}
-	if len(pubkey1) != 65 {
-		return errors.New("Invalid public key length")
-	}
-
-	//to enforce malleability, highest bit of S must be 0
-	//S starts at 32nd byte
-	if (sig[32] & 0x80) == 0x80 { //highest bit must be 1
-		return errors.New("Signature not malleable")
+	if len(msg) != 32 {
+		return errors.New("message must be a 32 byte hash")
 	}
-
-	if sig[64] >= 4 {
+	if len(sig) == 65 && sig[64] >= 4 {
 		return errors.New("Recover byte invalid")
 	}
-
-	// if pubkey recovered, signature valid
-	pubkey2, err := RecoverPubkey(msg, sig)
+	r, s, err := ParseSignature(sig)
 	if err != nil {
 		return err
 	}
-	if len(pubkey2) != 65 {
-		return errors.New("Invalid recovered public key length")
+	//to enforce malleability, S must be in the lower half of the order
+	if !IsLowS(s) {
+		return errors.New("Signature is malleable (high S)")
 	}
-	if !bytes.Equal(pubkey1, pubkey2) {
-		return errors.New("Public key does not match recovered public key")
+	if err := VerifyPubkeyValidity(pubkey); err != nil {
+		return err
 	}
 
-	return nil
+	der := EncodeDER(r, s)
+	var msg_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&msg[0]))
+	var sig_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&der[0]))
+	var pubkey_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&pubkey[0]))
+
+	ret := C.secp256k1_ecdsa_verify(msg_ptr, sig_ptr, C.int(len(der)), pubkey_ptr, C.int(len(pubkey)))
+	switch int(ret) {
+	case 1:
+		return nil
+	case 0:
+		return errors.New("Signature does not match public key")
+	case -1:
+		return errors.New("invalid pubkey")
+	default:
+		return errors.New("invalid signature")
+	}
+}
+
+// ECDH returns the x coordinate of seckey*pubkey, the standard secp256k1 shared secret
+func ECDH(seckey, pubkey []byte) ([]byte, error) {
+	if err := VerifySeckeyValidity(seckey); err != nil {
+		return nil, err
+	}
+	if err := VerifyPubkeyValidity(pubkey); err != nil {
+		return nil, err
+	}
+
+	point := make([]byte, len(pubkey))
+	copy(point, pubkey)
+
+	var point_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&point[0]))
+	var seckey_ptr *C.uchar = (*C.uchar)(unsafe.Pointer(&seckey[0]))
+
+	ret := C.secp256k1_ec_pubkey_tweak_mul(point_ptr, C.int(len(point)), seckey_ptr)
+	if ret != C.int(1) {
+		return nil, errors.New("Unable to multiply pubkey by seckey")
+	}
+	return point[1:33], nil
 }
 
 //recovers the public key from the signature
 //recovery of pubkey means correct signature
 func RecoverPubkey(msg []byte, sig []byte) ([]byte, error) {
+	if len(msg) != 32 {
+		return nil, errors.New("message must be a 32 byte hash")
+	}
 	if len(sig) != 65 {
 		return nil, errors.New("Invalid signature length")
 	}
+	if sig[64] >= 4 {
+		return nil, errors.New("Recover byte invalid")
+	}
 
 	var pubkey []byte = make([]byte, 65)
 
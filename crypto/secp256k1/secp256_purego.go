@@ -0,0 +1,238 @@
+//go:build purego || !cgo
+// +build purego !cgo
+
+package secp256k1
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+)
+
+// The pure Go backend, built with the purego tag or without cgo.
+// It has the same API as the libsecp256k1 one in secp256.go, on top of
+// the arithmetic in curve.go. Points are multiplied by secret keys and nonces
+// in constant time, but the scalar arithmetic mod n uses math/big, which is not
+// constant time, so libsecp256k1 is still the better choice for signing
+
+func Stop() {}
+
+func GenerateKeyPair() ([]byte, []byte, error) {
+	for {
+		seckey, err := randentropy.GetEntropyCSPRNG(32)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pubkey, err := GeneratePubKey(seckey); err == nil {
+			return pubkey, seckey, nil
+		}
+	}
+}
+
+func GeneratePubKey(seckey []byte) ([]byte, error) {
+	if err := VerifySeckeyValidity(seckey); err != nil {
+		return nil, err
+	}
+	return encodePubkey(scalarBaseMultCT(new(big.Int).SetBytes(seckey))), nil
+}
+
+// encodePubkey returns the 65 byte uncompressed encoding of p
+func encodePubkey(p *jacobianPoint) []byte {
+	x, y := p.affine()
+	return append(append([]byte{0x04}, bytes32(x)...), bytes32(y)...)
+}
+
+// Sign makes a 65 byte r||s||recid signature of the 32 byte msg hash,
+// with the deterministic RFC 6979 nonce for the key and hash
+func Sign(msg []byte, seckey []byte) ([]byte, error) {
+	return SignWithEntropy(msg, seckey, nil)
+}
+
+// SignWithEntropy is Sign with extra data, usually 32 random bytes, mixed into
+// the RFC 6979 nonce (section 3.6). The signature is no longer deterministic,
+// but its nonce is still safe if the extra data is not
+func SignWithEntropy(msg, seckey, extra []byte) ([]byte, error) {
+	if len(msg) != 32 {
+		return nil, errors.New("msg hash is not 32 bytes")
+	}
+	if err := VerifySeckeyValidity(seckey); err != nil {
+		return nil, errors.New("Invalid secret key")
+	}
+	d := new(big.Int).SetBytes(seckey)
+	e := new(big.Int).SetBytes(msg)
+	e.Mod(e, curveN)
+
+	nonces := newRFC6979(curveN, sha256.New, seckey, msg, extra)
+	for {
+		k := nonces.next()
+		rx, ry := scalarBaseMultCT(k).affine()
+		var recid byte
+		if ry.Bit(0) == 1 {
+			recid = 1
+		}
+		if rx.Cmp(curveN) >= 0 {
+			recid |= 2
+		}
+		r := new(big.Int).Mod(rx, curveN)
+		if r.Sign() == 0 {
+			continue
+		}
+		// s = k^-1 (e + rd)
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, e)
+		// k^-1 = k^(n-2), which unlike ModInverse doesn't branch on the bits of k
+		s.Mul(s, new(big.Int).Exp(k, curveNMinus2, curveN))
+		s.Mod(s, curveN)
+		if s.Sign() == 0 {
+			continue
+		}
+		// low s, which negates the nonce point
+		if s.Cmp(curveHalfN) > 0 {
+			s.Sub(curveN, s)
+			recid ^= 1
+		}
+		return append(append(bytes32(r), bytes32(s)...), recid), nil
+	}
+}
+
+var curveNMinus2 = new(big.Int).Sub(curveN, big.NewInt(2))
+
+func VerifySeckeyValidity(seckey []byte) error {
+	if len(seckey) != 32 {
+		return errors.New("priv key is not 32 bytes")
+	}
+	d := new(big.Int).SetBytes(seckey)
+	if d.Sign() == 0 || d.Cmp(curveN) >= 0 {
+		return errors.New("invalid seckey")
+	}
+	return nil
+}
+
+// VerifyPubkeyValidity accepts compressed (33 byte) and uncompressed (65 byte) pubkeys
+func VerifyPubkeyValidity(pubkey []byte) error {
+	if len(pubkey) != 33 && len(pubkey) != 65 {
+		return errors.New("pub key is not 33 or 65 bytes")
+	}
+	if _, err := parsePubkey(pubkey); err != nil {
+		return errors.New("invalid pubkey")
+	}
+	return nil
+}
+
+func VerifySignatureValidity(sig []byte) bool {
+	//64+1
+	if len(sig) != 65 {
+		return false
+	}
+	//malleability check, S must be in the lower half of the order
+	if !IsLowS(sig[32:64]) {
+		return false
+	}
+	//recovery id check
+	if sig[64] >= 4 {
+		return false
+	}
+
+	return true
+}
+
+// VerifySignature checks an ECDSA signature over the 32 byte msg directly against the pubkey.
+// The signature may be r||s, r||s||recid or DER, and the pubkey compressed or uncompressed.
+// Signatures with high S are refused
+func VerifySignature(msg []byte, sig []byte, pubkey []byte) error {
+	if msg == nil || sig == nil || pubkey == nil {
+		return errors.New("inputs must be non-nil")
+	}
+	if len(msg) != 32 {
+		return errors.New("message must be a 32 byte hash")
+	}
+	if len(sig) == 65 && sig[64] >= 4 {
+		return errors.New("Recover byte invalid")
+	}
+	rB, sB, err := ParseSignature(sig)
+	if err != nil {
+		return err
+	}
+	//to enforce malleability, S must be in the lower half of the order
+	if !IsLowS(sB) {
+		return errors.New("Signature is malleable (high S)")
+	}
+	if err := VerifyPubkeyValidity(pubkey); err != nil {
+		return err
+	}
+	q, _ := parsePubkey(pubkey)
+
+	r, s := new(big.Int).SetBytes(rB), new(big.Int).SetBytes(sB)
+	e := new(big.Int).SetBytes(msg)
+	w := new(big.Int).ModInverse(s, curveN)
+	u1 := e.Mul(e, w).Mod(e, curveN)
+	u2 := w.Mul(w, r).Mod(w, curveN)
+	p := pointAdd(scalarBaseMult(u1), scalarMult(q, u2))
+	if p.isInfinity() {
+		return errors.New("Signature does not match public key")
+	}
+	x, _ := p.affine()
+	if x.Mod(x, curveN).Cmp(r) != 0 {
+		return errors.New("Signature does not match public key")
+	}
+	return nil
+}
+
+// ECDH returns the x coordinate of seckey*pubkey, the standard secp256k1 shared secret
+func ECDH(seckey, pubkey []byte) ([]byte, error) {
+	if err := VerifySeckeyValidity(seckey); err != nil {
+		return nil, err
+	}
+	if err := VerifyPubkeyValidity(pubkey); err != nil {
+		return nil, err
+	}
+	q, _ := parsePubkey(pubkey)
+	x, _ := scalarMultCT(q, new(big.Int).SetBytes(seckey)).affine()
+	return bytes32(x), nil
+}
+
+// RecoverPubkey recovers the public key from the signature.
+// Recovery of the pubkey means the signature is correct
+func RecoverPubkey(msg []byte, sig []byte) ([]byte, error) {
+	if len(msg) != 32 {
+		return nil, errors.New("message must be a 32 byte hash")
+	}
+	if len(sig) != 65 {
+		return nil, errors.New("Invalid signature length")
+	}
+	if sig[64] >= 4 {
+		return nil, errors.New("Recover byte invalid")
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(curveN) >= 0 || s.Cmp(curveN) >= 0 {
+		return nil, errors.New("Failed to recover public key")
+	}
+
+	// the nonce point R has x = r (+ n) and the y parity of the recovery id
+	rx := new(big.Int).Set(r)
+	if sig[64]&2 != 0 {
+		rx.Add(rx, curveN)
+	}
+	rp, err := liftX(rx)
+	if err != nil {
+		return nil, errors.New("Failed to recover public key")
+	}
+	if sig[64]&1 == 1 {
+		rp = pointNeg(rp)
+	}
+
+	// Q = r^-1 (sR - eG)
+	rInv := new(big.Int).ModInverse(r, curveN)
+	e := new(big.Int).SetBytes(msg)
+	e.Neg(e).Mod(e, curveN)
+	u1 := e.Mul(e, rInv).Mod(e, curveN)
+	u2 := new(big.Int).Mul(s, rInv)
+	u2.Mod(u2, curveN)
+	q := pointAdd(scalarBaseMult(u1), scalarMult(rp, u2))
+	if q.isInfinity() {
+		return nil, errors.New("Failed to recover public key")
+	}
+	return encodePubkey(q), nil
+}
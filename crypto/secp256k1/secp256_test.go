@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"math/big"
 	"testing"
 
 	"github.com/eris-ltd/eris-keys/crypto/randentropy"
@@ -12,12 +13,28 @@ import (
 const TESTS = 10000 // how many tests
 const SigSize = 65  //64+1
 
+func randBytes(n int) []byte {
+	b, err := randentropy.GetEntropyMixed(n)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func generateKeyPair() ([]byte, []byte) {
+	pubkey, seckey, err := GenerateKeyPair()
+	if err != nil {
+		panic(err)
+	}
+	return pubkey, seckey
+}
+
 func Test_Secp256_00(t *testing.T) {
 
-	var nonce []byte = randentropy.GetEntropyMixed(32) //going to get bitcoins stolen!
+	nonce, err := randentropy.GetEntropyMixed(32) //going to get bitcoins stolen!
 
-	if len(nonce) != 32 {
-		t.Fatal()
+	if err != nil || len(nonce) != 32 {
+		t.Fatal(err)
 	}
 
 }
@@ -40,7 +57,7 @@ func CompactSigTest(sig []byte) {
 
 //test pubkey/private generation
 func Test_Secp256_01(t *testing.T) {
-	pubkey, seckey := GenerateKeyPair()
+	pubkey, seckey := generateKeyPair()
 	if err := VerifySeckeyValidity(seckey); err != nil {
 		t.Fatal()
 	}
@@ -51,8 +68,8 @@ func Test_Secp256_01(t *testing.T) {
 
 //test size of messages
 func Test_Secp256_02s(t *testing.T) {
-	pubkey, seckey := GenerateKeyPair()
-	msg := randentropy.GetEntropyMixed(32)
+	pubkey, seckey := generateKeyPair()
+	msg := randBytes(32)
 	sig, _ := Sign(msg, seckey)
 	CompactSigTest(sig)
 	if sig == nil {
@@ -74,8 +91,8 @@ func Test_Secp256_02s(t *testing.T) {
 
 //test signing message
 func Test_Secp256_02(t *testing.T) {
-	pubkey1, seckey := GenerateKeyPair()
-	msg := randentropy.GetEntropyMixed(32)
+	pubkey1, seckey := generateKeyPair()
+	msg := randBytes(32)
 	sig, _ := Sign(msg, seckey)
 	if sig == nil {
 		t.Fatal("Signature nil")
@@ -97,8 +114,8 @@ func Test_Secp256_02(t *testing.T) {
 
 //test pubkey recovery
 func Test_Secp256_02a(t *testing.T) {
-	pubkey1, seckey1 := GenerateKeyPair()
-	msg := randentropy.GetEntropyMixed(32)
+	pubkey1, seckey1 := generateKeyPair()
+	msg := randBytes(32)
 	sig, _ := Sign(msg, seckey1)
 
 	if sig == nil {
@@ -125,9 +142,9 @@ func Test_Secp256_02a(t *testing.T) {
 
 //test random messages for the same pub/private key
 func Test_Secp256_03(t *testing.T) {
-	_, seckey := GenerateKeyPair()
+	_, seckey := generateKeyPair()
 	for i := 0; i < TESTS; i++ {
-		msg := randentropy.GetEntropyMixed(32)
+		msg := randBytes(32)
 		sig, _ := Sign(msg, seckey)
 		CompactSigTest(sig)
 
@@ -142,8 +159,8 @@ func Test_Secp256_03(t *testing.T) {
 //test random messages for different pub/private keys
 func Test_Secp256_04(t *testing.T) {
 	for i := 0; i < TESTS; i++ {
-		pubkey1, seckey := GenerateKeyPair()
-		msg := randentropy.GetEntropyMixed(32)
+		pubkey1, seckey := generateKeyPair()
+		msg := randBytes(32)
 		sig, _ := Sign(msg, seckey)
 		CompactSigTest(sig)
 
@@ -166,15 +183,15 @@ func Test_Secp256_04(t *testing.T) {
 //	-SIPA look at this
 
 func randSig() []byte {
-	sig := randentropy.GetEntropyMixed(65)
+	sig := randBytes(65)
 	sig[32] &= 0x70
 	sig[64] %= 4
 	return sig
 }
 
 func Test_Secp256_06a_alt0(t *testing.T) {
-	pubkey1, seckey := GenerateKeyPair()
-	msg := randentropy.GetEntropyMixed(32)
+	pubkey1, seckey := generateKeyPair()
+	msg := randBytes(32)
 	sig, _ := Sign(msg, seckey)
 
 	if sig == nil {
@@ -204,13 +221,13 @@ func Test_Secp256_06a_alt0(t *testing.T) {
 //test random messages against valid signature: should fail
 
 func Test_Secp256_06b(t *testing.T) {
-	pubkey1, seckey := GenerateKeyPair()
-	msg := randentropy.GetEntropyMixed(32)
+	pubkey1, seckey := generateKeyPair()
+	msg := randBytes(32)
 	sig, _ := Sign(msg, seckey)
 
 	fail_count := 0
 	for i := 0; i < TESTS; i++ {
-		msg = randentropy.GetEntropyMixed(32)
+		msg = randBytes(32)
 		pubkey2, _ := RecoverPubkey(msg, sig)
 		if bytes.Equal(pubkey1, pubkey2) == true {
 			t.Fail()
@@ -236,3 +253,58 @@ func TestInvalidKey(t *testing.T) {
 		t.Errorf("pvk %x varify sec key should have returned error", p1)
 	}
 }
+
+func compressPubkey(pubkey []byte) []byte {
+	return append([]byte{0x02 + pubkey[64]&1}, pubkey[1:33]...)
+}
+
+//test direct verification of each signature and pubkey encoding
+func TestVerifySignatureEncodings(t *testing.T) {
+	pubkey, seckey := generateKeyPair()
+	msg := randBytes(32)
+	sig, _ := Sign(msg, seckey)
+
+	r, s, err := ParseSignature(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pub := range [][]byte{pubkey, compressPubkey(pubkey)} {
+		for _, sg := range [][]byte{sig, sig[:64], EncodeDER(r, s)} {
+			if err := VerifySignature(msg, sg, pub); err != nil {
+				t.Fatalf("Signature %X failed to verify against %X: %v", sg, pub, err)
+			}
+		}
+	}
+
+	other := randBytes(32)
+	if VerifySignature(other, sig[:64], compressPubkey(pubkey)) == nil {
+		t.Fatal("Signature verified for the wrong message")
+	}
+
+	// the high S twin of a valid signature is refused
+	highS := new(big.Int).Sub(curveN, new(big.Int).SetBytes(s)).Bytes()
+	sig2 := append(append([]byte{}, r...), make([]byte, 32-len(highS))...)
+	sig2 = append(sig2, highS...)
+	if VerifySignature(msg, sig2, pubkey) == nil {
+		t.Fatal("High S signature was accepted")
+	}
+}
+
+func TestDER(t *testing.T) {
+	r := append([]byte{0x80}, make([]byte, 31)...)
+	s := append(make([]byte, 31), 0x01)
+	der := EncodeDER(r, s)
+	if der[3] != 33 || der[4] != 0 || der[len(der)-2] != 1 || der[len(der)-1] != 1 {
+		t.Fatalf("Bad DER encoding %X", der)
+	}
+	r2, s2, err := ParseSignature(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, r2) || !bytes.Equal(s, s2) {
+		t.Fatalf("DER round trip failed. Got %X %X", r2, s2)
+	}
+	if _, _, err := ParseSignature(append(der, 0)); err == nil {
+		t.Fatal("Expected trailing bytes to be refused")
+	}
+}
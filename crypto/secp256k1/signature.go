@@ -0,0 +1,105 @@
+package secp256k1
+
+import (
+	"errors"
+	"math/big"
+)
+
+// order of the curve, and half of it for low-S checks
+var (
+	curveN, _     = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	curveHalfN, _ = new(big.Int).SetString("7FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF5D576E7357A4501DDFE92F46681B20A0", 16)
+)
+
+// ParseSignature returns the 32 byte r and s of a signature given
+// as r||s (64 bytes), r||s||recid (65 bytes), or DER
+func ParseSignature(sig []byte) (r, s []byte, err error) {
+	switch {
+	case len(sig) == 64 || len(sig) == 65:
+		r, s = sig[:32], sig[32:64]
+	case len(sig) > 0 && sig[0] == 0x30:
+		r, s, err = parseDER(sig)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, errors.New("invalid signature length")
+	}
+	rInt, sInt := new(big.Int).SetBytes(r), new(big.Int).SetBytes(s)
+	if rInt.Sign() == 0 || sInt.Sign() == 0 || rInt.Cmp(curveN) >= 0 || sInt.Cmp(curveN) >= 0 {
+		return nil, nil, errors.New("signature values out of range")
+	}
+	return r, s, nil
+}
+
+// IsLowS returns true if s is at most half the curve order.
+// Signatures with high S are malleable and are refused
+func IsLowS(s []byte) bool {
+	return new(big.Int).SetBytes(s).Cmp(curveHalfN) <= 0
+}
+
+// EncodeDER encodes r and s as a DER sequence of two integers
+func EncodeDER(r, s []byte) []byte {
+	rb, sb := derInt(r), derInt(s)
+	der := []byte{0x30, byte(4 + len(rb) + len(sb)), 0x02, byte(len(rb))}
+	der = append(der, rb...)
+	der = append(der, 0x02, byte(len(sb)))
+	return append(der, sb...)
+}
+
+// minimal big endian integer, with a zero byte if the high bit is set
+func derInt(b []byte) []byte {
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+func parseDER(sig []byte) (r, s []byte, err error) {
+	if len(sig) < 8 || sig[0] != 0x30 || int(sig[1]) != len(sig)-2 {
+		return nil, nil, errors.New("invalid DER signature")
+	}
+	rest := sig[2:]
+	r, rest, err = parseDERInt(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, rest, err = parseDERInt(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) != 0 {
+		return nil, nil, errors.New("trailing bytes in DER signature")
+	}
+	return r, s, nil
+}
+
+// parse a DER integer into 32 bytes, returning the remaining bytes
+func parseDERInt(b []byte) ([]byte, []byte, error) {
+	if len(b) < 2 || b[0] != 0x02 {
+		return nil, nil, errors.New("invalid DER integer")
+	}
+	l := int(b[1])
+	if l == 0 || len(b) < 2+l {
+		return nil, nil, errors.New("invalid DER integer length")
+	}
+	v := b[2 : 2+l]
+	if v[0]&0x80 != 0 {
+		return nil, nil, errors.New("negative DER integer")
+	}
+	if len(v) > 1 && v[0] == 0 && v[1]&0x80 == 0 {
+		return nil, nil, errors.New("non-minimal DER integer")
+	}
+	for len(v) > 0 && v[0] == 0 {
+		v = v[1:]
+	}
+	if len(v) > 32 {
+		return nil, nil, errors.New("DER integer too large")
+	}
+	out := make([]byte, 32)
+	copy(out[32-len(v):], v)
+	return out, b[2+l:], nil
+}
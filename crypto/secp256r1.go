@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+
+	uuid "github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/wayn3h0/go-uuid"
+)
+
+//-----------------------------------------------------------------------------
+// secp256r1 (NIST P-256) keys
+//
+// Private keys are the 32 byte scalar, pubkeys the 65 byte uncompressed point
+// (33 byte compressed pubkeys are accepted for verification),
+// and signatures are 64 byte r||s (DER is accepted for verification)
+
+func newKeySecp256r1(addrType AddrType) (*Key, error) {
+	for {
+		priv, err := randentropy.GetEntropyCSPRNG(32)
+		if err != nil {
+			return nil, err
+		}
+		// not every 256 bit int is a valid scalar
+		if _, err := privKeySecp256r1(priv); err == nil {
+			return keyFromPrivSecp256r1(addrType, priv)
+		}
+	}
+}
+
+func privKeySecp256r1(priv []byte) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(priv)
+	if len(priv) != 32 || d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("invalid secp256r1 private key")
+	}
+	k := &ecdsa.PrivateKey{D: d}
+	k.PublicKey.Curve = curve
+	k.PublicKey.X, k.PublicKey.Y = curve.ScalarBaseMult(priv)
+	return k, nil
+}
+
+func keyFromPrivSecp256r1(addrType AddrType, priv []byte) (*Key, error) {
+	if addrType != AddrTypeRipemd160Sha256 && addrType != AddrTypeSha256 {
+		return nil, fmt.Errorf("secp256r1 keys need a ripemd160sha256 or sha256 address, not %s", addrType)
+	}
+	k, err := privKeySecp256r1(priv)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := uuid.NewRandom()
+	return &Key{
+		Id:         id,
+		Type:       KeyType{CurveTypeSecp256r1, addrType},
+		Address:    AddressFromPub(addrType, elliptic.Marshal(k.Curve, k.X, k.Y)),
+		PrivateKey: priv,
+	}, nil
+}
+
+func pubKeySecp256r1(k *Key) ([]byte, error) {
+	priv, err := privKeySecp256r1(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return elliptic.Marshal(priv.Curve, priv.X, priv.Y), nil
+}
+
+func signSecp256r1(k *Key, hash []byte) ([]byte, error) {
+	priv, err := privKeySecp256r1(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := ecdsa.Sign(randentropy.Reader, priv, hash)
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+func canonicalPubSecp256r1(pubB []byte) ([]byte, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+	switch len(pubB) {
+	case 65:
+		x, y = elliptic.Unmarshal(curve, pubB)
+	case 33:
+		x, y = elliptic.UnmarshalCompressed(curve, pubB)
+	}
+	if x == nil {
+		return nil, fmt.Errorf("invalid secp256r1 pubkey %X", pubB)
+	}
+	return elliptic.MarshalCompressed(curve, x, y), nil
+}
+
+func verifySigSecp256r1(hash, sig, pubB []byte) (bool, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+	switch len(pubB) {
+	case 65:
+		x, y = elliptic.Unmarshal(curve, pubB)
+	case 33:
+		x, y = elliptic.UnmarshalCompressed(curve, pubB)
+	}
+	if x == nil {
+		return false, fmt.Errorf("invalid secp256r1 pubkey %X", pubB)
+	}
+	pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+
+	var r, s *big.Int
+	if len(sig) == 64 {
+		r, s = new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	} else {
+		var der struct{ R, S *big.Int }
+		rest, err := asn1.Unmarshal(sig, &der)
+		if err != nil || len(rest) != 0 {
+			return false, fmt.Errorf("signature must be 64 byte r||s or DER")
+		}
+		r, s = der.R, der.S
+	}
+	return ecdsa.Verify(pub, hash, r, s), nil
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestSecp256r1(t *testing.T) {
+	key, err := NewKey(KeyType{CurveTypeSecp256r1, AddrTypeSha256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := key.Pubkey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pub) != 65 {
+		t.Fatalf("Expected a 65 byte pubkey, got %d", len(pub))
+	}
+
+	// ripemd160 addresses are made by tendermint for its own key types
+	if _, err := NewKey(KeyType{CurveTypeSecp256r1, AddrTypeRipemd160}); err == nil {
+		t.Fatal("Expected an error for a secp256r1 key with a ripemd160 address")
+	}
+	if _, err := NewKeyFromPriv(KeyType{CurveTypeSecp256r1, AddrTypeSha3}, key.PrivateKey); err == nil {
+		t.Fatal("Expected an error for a secp256r1 key with a sha3 address")
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), pub)
+	compressed := elliptic.MarshalCompressed(elliptic.P256(), x, y)
+	if addr := Sha256(compressed); string(addr) != string(key.Address) {
+		t.Fatalf("Wrong address. Got %X, expected %X", key.Address, addr)
+	}
+
+	hash := Sha256([]byte("a message"))
+	sig, err := key.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])})
+	for _, p := range [][]byte{pub, compressed} {
+		for _, s := range [][]byte{sig, der} {
+			if res, err := Verify(CurveTypeSecp256r1, hash, s, p); err != nil || !res {
+				t.Fatalf("Signature %X failed to verify against %X: %v", s, p, err)
+			}
+		}
+	}
+	if res, _ := Verify(CurveTypeSecp256r1, Sha256([]byte("another message")), sig, pub); res {
+		t.Fatal("Signature verified for the wrong message")
+	}
+
+	// interoperable with the standard library
+	r, s, _ := ecdsa.Sign(rand.Reader, &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, D: new(big.Int).SetBytes(key.PrivateKey)}, hash)
+	stdSig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	if res, err := Verify(CurveTypeSecp256r1, hash, stdSig, pub); err != nil || !res {
+		t.Fatalf("Standard library signature failed to verify: %v", err)
+	}
+
+	// json round trip
+	b, err := key.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2 := new(Key)
+	if err := key2.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if key2.Type.String() != "secp256r1,sha256" {
+		t.Fatalf("Wrong key type after json round trip: %s", key2.Type)
+	}
+}
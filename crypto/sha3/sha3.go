@@ -45,6 +45,7 @@ type digest struct {
 	outputSize int               // desired output size in bytes
 	capacity   int               // number of bytes to leave untouched during squeeze/absorb
 	absorbed   int               // number of bytes absorbed thus far
+	dsbyte     byte              // domain separation bits and the opening pad bit
 }
 
 // minInt returns the lesser of two integer arguments, to simplify the absorption routine.
@@ -156,9 +157,10 @@ func (d *digest) Write(p []byte) (int, error) {
 // xoring an opening and closing bit into the appropriate lanes.
 func (d *digest) pad() {
 	offset := d.absorbed % d.rate()
-	// The opening pad bit must be shifted into position based on the number of bytes absorbed
+	// The opening pad bit must be shifted into position based on the number of bytes absorbed.
+	// FIPS 202 SHA3 prefixes it with the domain separation bits 01
 	padOpenLane := offset / laneSize
-	d.a[padOpenLane] ^= 0x0000000000000001 << uint(8*(offset%laneSize))
+	d.a[padOpenLane] ^= uint64(d.dsbyte) << uint(8*(offset%laneSize))
 	// The closing padding bit is always in the last position
 	padCloseLane := (d.rate() / laneSize) - 1
 	d.a[padCloseLane] ^= 0x8000000000000000
@@ -210,7 +212,18 @@ func (d *digest) Sum(in []byte) []byte {
 // from the Keccak specification, all of which set capacity=2*outputSize. Note that the final
 // NIST standard for SHA3 may specify different input/output lengths.
 // The output size is indicated in bits but converted into bytes internally.
-func NewKeccak224() hash.Hash { return &digest{outputSize: 224 / 8, capacity: 2 * 224 / 8} }
-func NewKeccak256() hash.Hash { return &digest{outputSize: 256 / 8, capacity: 2 * 256 / 8} }
-func NewKeccak384() hash.Hash { return &digest{outputSize: 384 / 8, capacity: 2 * 384 / 8} }
-func NewKeccak512() hash.Hash { return &digest{outputSize: 512 / 8, capacity: 2 * 512 / 8} }
+func NewKeccak224() hash.Hash { return newDigest(224, 0x01) }
+func NewKeccak256() hash.Hash { return newDigest(256, 0x01) }
+func NewKeccak384() hash.Hash { return newDigest(384, 0x01) }
+func NewKeccak512() hash.Hash { return newDigest(512, 0x01) }
+
+// The NewX constructors are the final FIPS 202 SHA3, which differs from Keccak only in padding.
+// Ethereum's "sha3" is Keccak256, not New256
+func New224() hash.Hash { return newDigest(224, 0x06) }
+func New256() hash.Hash { return newDigest(256, 0x06) }
+func New384() hash.Hash { return newDigest(384, 0x06) }
+func New512() hash.Hash { return newDigest(512, 0x06) }
+
+func newDigest(bits int, dsbyte byte) hash.Hash {
+	return &digest{outputSize: bits / 8, capacity: 2 * bits / 8, dsbyte: dsbyte}
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/eris-ltd/eris-keys/crypto/secp256k1"
+)
+
+//-----------------------------------------------------------------------------
+// secp256k1 signature encodings
+
+const (
+	SigFormatCompact = "compact" // 64 byte r||s
+	SigFormatRSV     = "rsv"     // 65 byte r||s||recid, as returned by Sign
+	SigFormatDER     = "der"     // ASN.1 DER sequence of r and s
+)
+
+// ConvertSignature re-encodes a secp256k1 signature in the given format.
+// The input may be in any format, but converting to rsv needs the recovery id,
+// so it must already be rsv (use RecoverableSignature otherwise)
+func ConvertSignature(sig []byte, format string) ([]byte, error) {
+	r, s, err := secp256k1.ParseSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case SigFormatCompact:
+		return append(append([]byte{}, r...), s...), nil
+	case SigFormatDER:
+		return secp256k1.EncodeDER(r, s), nil
+	case SigFormatRSV:
+		if len(sig) != 65 {
+			return nil, fmt.Errorf("the recovery id can't be found without the hash and pubkey")
+		}
+		return append(append(append([]byte{}, r...), s...), sig[64]), nil
+	}
+	return nil, fmt.Errorf("unknown signature format %s", format)
+}
+
+// RecoverableSignature finds the recovery id of the signature by trying each
+// one against the pubkey, and returns the 65 byte r||s||recid signature
+func RecoverableSignature(sig, hash, pub []byte) ([]byte, error) {
+	compact, err := ConvertSignature(sig, SigFormatCompact)
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) == 33 {
+		return nil, fmt.Errorf("the uncompressed pubkey is needed to find the recovery id")
+	}
+	for recid := byte(0); recid < 4; recid++ {
+		rsv := append(compact[:64:64], recid)
+		if recovered, err := secp256k1.RecoverPubkey(hash, rsv); err == nil && bytes.Equal(recovered, pub) {
+			return rsv, nil
+		}
+	}
+	return nil, fmt.Errorf("signature does not match pubkey %X", pub)
+}
+
+// CheckSignatureFormat returns an error if the signature isn't encoded in the format
+func CheckSignatureFormat(sig []byte, format string) error {
+	var ok bool
+	switch format {
+	case SigFormatCompact:
+		ok = len(sig) == 64
+	case SigFormatRSV:
+		ok = len(sig) == 65
+	case SigFormatDER:
+		ok = len(sig) > 0 && sig[0] == 0x30
+	default:
+		return fmt.Errorf("unknown signature format %s", format)
+	}
+	if !ok {
+		return fmt.Errorf("signature is not in %s format", format)
+	}
+	_, _, err := secp256k1.ParseSignature(sig)
+	return err
+}
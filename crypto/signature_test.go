@@ -0,0 +1,62 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConvertSignature(t *testing.T) {
+	key, err := NewKey(KeyType{CurveTypeSecp256k1, AddrTypeSha3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := key.Pubkey()
+	hash := Sha256([]byte("a message"))
+	rsv, err := key.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compact, err := ConvertSignature(rsv, SigFormatCompact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := ConvertSignature(rsv, SigFormatDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSignatureFormat(compact, SigFormatCompact); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSignatureFormat(der, SigFormatDER); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSignatureFormat(der, SigFormatCompact); err == nil {
+		t.Fatal("Expected DER signature to fail the compact format check")
+	}
+
+	for _, sig := range [][]byte{rsv, compact, der} {
+		if res, err := Verify(CurveTypeSecp256k1, hash, sig, pub); err != nil || !res {
+			t.Fatalf("Signature %X failed to verify: %v", sig, err)
+		}
+	}
+
+	// back from DER
+	compact2, err := ConvertSignature(der, SigFormatCompact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(compact, compact2) {
+		t.Fatalf("DER round trip failed. Got %X, expected %X", compact2, compact)
+	}
+	if _, err := ConvertSignature(der, SigFormatRSV); err == nil {
+		t.Fatal("Expected conversion to rsv without a recovery id to fail")
+	}
+	rsv2, err := RecoverableSignature(der, hash, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rsv, rsv2) {
+		t.Fatalf("Wrong recoverable signature. Got %X, expected %X", rsv2, rsv)
+	}
+}
@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxVanityPrefix is the longest vanity prefix we'll search for, in hex characters.
+// Each character multiplies the expected search time by 16
+const MaxVanityPrefix = 8
+
+// VanityProgressInterval is how often NewVanityKey reports its progress
+var VanityProgressInterval = 5 * time.Second
+
+// vanityNewKey makes the candidate keys, and can be replaced in tests
+var vanityNewKey = NewKey
+
+// VanityDifficulty is the expected number of keys to try to find an address with the prefix
+func VanityDifficulty(prefix string) float64 {
+	return math.Pow(16, float64(len(prefix)))
+}
+
+// CheckVanityPrefix checks that prefix is hex and not too long to find
+func CheckVanityPrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("empty vanity prefix")
+	}
+	if len(prefix) > MaxVanityPrefix {
+		return fmt.Errorf("vanity prefix %s is longer than %d characters", prefix, MaxVanityPrefix)
+	}
+	for _, c := range strings.ToLower(prefix) {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return fmt.Errorf("vanity prefix %s is not hex", prefix)
+		}
+	}
+	return nil
+}
+
+// NewVanityKey generates keys of the given type on the given number of goroutines
+// until one has an address starting with the hex prefix (case insensitive).
+// The address is the key's usual one, so AddressFromPub for all but ed25519 keys.
+// If progress isn't nil, it's called every VanityProgressInterval with the number of keys tried.
+// If a worker fails to make a key, or panics, the search stops with its error.
+// It also stops when ctx is done
+func NewVanityKey(ctx context.Context, typ KeyType, prefix string, workers int, progress func(tried uint64, elapsed time.Duration)) (*Key, error) {
+	if err := CheckVanityPrefix(prefix); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		return nil, fmt.Errorf("need at least one worker")
+	}
+	// make sure the type is valid before starting the workers
+	if _, err := NewKey(typ); err != nil {
+		return nil, err
+	}
+	prefix = strings.ToLower(prefix)
+
+	var (
+		tried uint64
+		found = make(chan *Key, 1)
+		errs  = make(chan error, 1)
+		done  = make(chan struct{})
+		wg    sync.WaitGroup
+	)
+	fail := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					fail(fmt.Errorf("vanity search failed: %v", r))
+				}
+			}()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				key, err := vanityNewKey(typ)
+				if err != nil {
+					fail(fmt.Errorf("vanity search failed: %v", err))
+					return
+				}
+				atomic.AddUint64(&tried, 1)
+				if strings.HasPrefix(hex.EncodeToString(key.Address), prefix) {
+					select {
+					case found <- key:
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(VanityProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case key := <-found:
+			close(done)
+			wg.Wait()
+			return key, nil
+		case err := <-errs:
+			close(done)
+			wg.Wait()
+			return nil, err
+		case <-ctx.Done():
+			close(done)
+			wg.Wait()
+			return nil, fmt.Errorf("vanity search stopped after %d keys: %v", atomic.LoadUint64(&tried), ctx.Err())
+		case <-ticker.C:
+			if progress != nil {
+				progress(atomic.LoadUint64(&tried), time.Since(start))
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVanityKey(t *testing.T) {
+	VanityProgressInterval = time.Millisecond
+	defer func() { VanityProgressInterval = 5 * time.Second }()
+
+	for _, typ := range []KeyType{{CurveTypeEd25519, AddrTypeRipemd160}, {CurveTypeSecp256k1, AddrTypeSha3}} {
+		key, err := NewVanityKey(context.Background(), typ, "aB", 4, func(uint64, time.Duration) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr := fmt.Sprintf("%X", key.Address); !strings.HasPrefix(addr, "AB") {
+			t.Fatalf("Address %s doesn't start with the prefix", addr)
+		}
+		// the address is the one the key would get anyway
+		again, err := NewKeyFromPriv(typ, key.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again.Address, key.Address) {
+			t.Fatalf("Vanity key has address %X, expected %X", key.Address, again.Address)
+		}
+	}
+
+	for _, prefix := range []string{"", "XYZ", "123456789"} {
+		if _, err := NewVanityKey(context.Background(), KeyType{CurveTypeEd25519, AddrTypeRipemd160}, prefix, 1, nil); err == nil {
+			t.Fatalf("Expected an error for prefix %q", prefix)
+		}
+	}
+}
+
+func TestVanityKeyErrors(t *testing.T) {
+	defer func() { vanityNewKey = NewKey }()
+	typ := KeyType{CurveTypeEd25519, AddrTypeRipemd160}
+
+	vanityNewKey = func(KeyType) (*Key, error) { return nil, fmt.Errorf("no entropy") }
+	if _, err := NewVanityKey(context.Background(), typ, "ab", 4, nil); err == nil || !strings.Contains(err.Error(), "no entropy") {
+		t.Fatalf("Expected the error making a key, got %v", err)
+	}
+	vanityNewKey = func(KeyType) (*Key, error) { panic("entropy source exploded") }
+	if _, err := NewVanityKey(context.Background(), typ, "ab", 4, nil); err == nil || !strings.Contains(err.Error(), "exploded") {
+		t.Fatalf("Expected the panic as an error, got %v", err)
+	}
+}
+
+func TestVanityKeyCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := NewVanityKey(ctx, KeyType{CurveTypeEd25519, AddrTypeRipemd160}, "abcdef12", 2, nil); err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Fatalf("Expected the search to stop, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/eris-ltd/eris-keys/crypto"
+
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/common/go/common"
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/common/go/log"
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/spf13/cobra"
@@ -44,15 +46,63 @@ var (
 	KeyPort  string
 
 	//keygenCmd only
-	NoPassword bool
-	KeyType    string
+	NoPassword    bool
+	KeyType       string
+	VanityPrefix  string
+	VanityWorkers int
+	EntropySource string
 
 	//hashCmd only
 	HashType string
 	HexByte  bool
+	HashFile string
 
 	// lockCmd only
 	UnlockTime int // minutes
+
+	// keygenCmd and importCmd
+	ValidatorKey bool
+
+	// signCmd only
+	SignValidator bool
+	SignHash      string
+	SignFile      string
+	PartialFile   string
+	MultisigAddr  string
+
+	// signTxCmd only
+	TxChainID string
+
+	// ecdhCmd only
+	PeerPub  string
+	ECDHSalt string
+	ECDHInfo string
+
+	// encryptCmd only
+	EncryptTo    string
+	EncryptCurve string
+
+	// multisigNewCmd and frostDKGCmd
+	Threshold int
+
+	// frost commands
+	FrostParticipants []string
+	FrostDir          string
+	FrostN            int
+	FrostIDs          []string
+	FrostIdentities   []string
+	FrostID           string
+
+	// verifyAggregateCmd only
+	AggPubs []string
+	AggPops []string
+
+	// signCmd and verifyCmd
+	EthPersonal bool
+	EIP712File  string
+	SigFormat   string // also convertSigCmd
+	SigScheme   string // also pubKeyCmd
+	BatchMode   bool
 )
 
 var EKeys = &cobra.Command{
@@ -71,18 +121,34 @@ func Execute() {
 
 func BuildKeysCommand() {
 	nameCmd.AddCommand(nameRmCmd, nameLsCmd)
+	auditCmd.AddCommand(auditVerifyCmd, auditQueryCmd)
+	multisigCmd.AddCommand(multisigNewCmd, multisigVerifyCmd)
+	frostCmd.AddCommand(frostDKGCmd, frostSignCmd, frostRespondCmd, frostGroupCmd)
 
 	EKeys.AddCommand(keygenCmd)
 	EKeys.AddCommand(lockCmd)
 	EKeys.AddCommand(unlockCmd)
 	EKeys.AddCommand(nameCmd)
 	EKeys.AddCommand(signCmd)
+	EKeys.AddCommand(signTxCmd)
 	EKeys.AddCommand(pubKeyCmd)
 	EKeys.AddCommand(verifyCmd)
+	EKeys.AddCommand(recoverPubCmd)
+	EKeys.AddCommand(convertSigCmd)
+	EKeys.AddCommand(ecdhCmd)
+	EKeys.AddCommand(encryptCmd)
+	EKeys.AddCommand(decryptCmd)
+	EKeys.AddCommand(popCmd)
+	EKeys.AddCommand(verifyPopCmd)
+	EKeys.AddCommand(aggregateCmd)
+	EKeys.AddCommand(verifyAggregateCmd)
+	EKeys.AddCommand(multisigCmd)
+	EKeys.AddCommand(frostCmd)
 	EKeys.AddCommand(hashCmd)
 	EKeys.AddCommand(serverCmd)
 	EKeys.AddCommand(importCmd)
 	EKeys.AddCommand(convertCmd)
+	EKeys.AddCommand(auditCmd)
 	addKeysFlags()
 }
 
@@ -131,10 +197,17 @@ var nameRmCmd = &cobra.Command{
 var signCmd = &cobra.Command{
 	Use:   "sign",
 	Short: "eris-keys sign --addr <address> <hash>",
-	Long:  "eris-keys sign --addr <address> <hash>",
+	Long:  "eris-keys sign --addr <address> <hash>\neris-keys sign --addr <address> --hash sha256 [<msg> | --file <path>]\n\nWith --hash and no msg or file, the message is read from stdin",
 	Run:   cliSign,
 }
 
+var signTxCmd = &cobra.Command{
+	Use:   "sign-tx",
+	Short: "eris-keys sign-tx --addr <address> <tx json> | /path/to/tx.json",
+	Long:  "sign an ethereum transaction given as json with fields nonce, gasPrice, gas, to, value, data, chainId.\nIf chainId is set the transaction is signed with EIP-155 replay protection.\nPrints the raw signed transaction and its hash.\n\nWith --chain-id, sign a tendermint SendTx or CallTx given in wire json, eg. [1,{\"inputs\":[...],\"outputs\":[...]}].\nEvery input belonging to the key is signed and the signed transaction is printed",
+	Run:   cliSignTx,
+}
+
 var pubKeyCmd = &cobra.Command{
 	Use:   "pub",
 	Short: "eris-keys pub --addr <addr>",
@@ -149,6 +222,125 @@ var verifyCmd = &cobra.Command{
 	Run:   cliVerify,
 }
 
+var recoverPubCmd = &cobra.Command{
+	Use:   "recover-pub",
+	Short: "eris-keys recover-pub <hash> <sig>",
+	Long:  "recover the public key that made a 65 byte secp256k1 signature of the hash,\nand print it along with its address for each address type",
+	Run:   cliRecoverPub,
+}
+
+var ecdhCmd = &cobra.Command{
+	Use:   "ecdh",
+	Short: "eris-keys ecdh --addr <address> --peer-pub <pub>",
+	Long:  "compute a 32 byte shared secret with the owner of the peer's public key.\nEd25519 keys agree over curve25519, secp256k1 keys with standard ECDH.\nThe raw secret is run through HKDF-SHA256 with the optional --salt and --info",
+	Run:   cliECDH,
+}
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "eris-keys encrypt --type <curve> --to <pub> [<msg>]",
+	Long:  "encrypt a message (or stdin) to the owner of an ed25519 or secp256k1 public key.\nPrints a json envelope with the key type, ephemeral public key, nonce and AES-GCM ciphertext",
+	Run:   cliEncrypt,
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "eris-keys decrypt --addr <address> [<envelope> | /path/to/envelope]",
+	Long:  "decrypt an envelope made by encrypt with the key, inside the daemon. The envelope may also be piped to stdin",
+	Run:   cliDecrypt,
+}
+
+var popCmd = &cobra.Command{
+	Use:   "pop",
+	Short: "eris-keys pop --addr <address>",
+	Long:  "print a proof of possession for a bls12381 key: a signature of its own public key.\nShare it with the public key so others can safely aggregate signatures of the same message",
+	Run:   cliPop,
+}
+
+var verifyPopCmd = &cobra.Command{
+	Use:   "verify-pop",
+	Short: "eris-keys verify-pop <pub> <pop>",
+	Long:  "check the proof of possession of a bls12381 public key",
+	Run:   cliVerifyPop,
+}
+
+var aggregateCmd = &cobra.Command{
+	Use:   "aggregate",
+	Short: "eris-keys aggregate <sig> <sig> ...",
+	Long:  "combine bls12381 signatures into a single 96 byte aggregate signature",
+	Run:   cliAggregate,
+}
+
+var verifyAggregateCmd = &cobra.Command{
+	Use:   "verify-aggregate",
+	Short: "eris-keys verify-aggregate --pubs <pub>,<pub>,... <sig> <hash> [<hash> ...]",
+	Long:  "verify an aggregate bls12381 signature.\nWith one hash, every public key signed it. Otherwise each public key signed the hash in the same position.\nUnless every hash is different, each public key's proof of possession must be given with --pops",
+	Run:   cliVerifyAggregate,
+}
+
+var multisigCmd = &cobra.Command{
+	Use:   "multisig",
+	Short: "Manage threshold multisig accounts",
+	Long:  "Manage m-of-n multisig accounts. Members sign with `eris-keys sign --partial <file>`,\ncollecting their signatures in the file until it can be verified with `eris-keys multisig verify <file>`",
+	Run:   func(cmd *cobra.Command, args []string) { cmd.Help() },
+}
+
+var multisigNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "eris-keys multisig new --threshold <m> <pub> <pub> ...",
+	Long:  "store an m-of-n multisig account of the members' public keys and print its address.\nThe address only depends on the key type, threshold and set of public keys,\nso each member can create the same account with their own daemon",
+	Run:   cliMultisigNew,
+}
+
+var multisigVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "eris-keys multisig verify --addr <multisig> <file>",
+	Long:  "check that at least the threshold of members of the multisig at --addr or --name signed the message in a file made by `sign --partial`.\nThe multisig stored by our daemon is used, not the one in the file",
+	Run:   cliMultisigVerify,
+}
+
+var frostCmd = &cobra.Command{
+	Use:   "frost",
+	Short: "Manage FROST threshold ed25519 keys",
+	Long:  "Make and sign with FROST threshold ed25519 keys, whose shares are held by the participants' own daemons.\nThe rounds are run by a coordinator which reaches the daemons with --participants <host:port>,...\nor by writing request files to --rounds-dir, which participants answer with `eris-keys frost respond <file>`.\nWith files, run the coordinator again once the responses are in to start the next round",
+	Run:   func(cmd *cobra.Command, args []string) { cmd.Help() },
+}
+
+var frostDKGCmd = &cobra.Command{
+	Use:   "dkg",
+	Short: "eris-keys frost dkg --threshold <m> (--participants <host:port>,... | --rounds-dir <dir> --n <n>)",
+	Long:  "run a distributed key generation between n participants, who get ids 1 to n in order.\nEach stores its share of the group key, and the group's address and pubkey are printed",
+	Run:   cliFrostDKG,
+}
+
+var frostSignCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "eris-keys frost sign --addr <group> (--participants <host:port>,... | --rounds-dir <dir> --n <n>) [--ids <id>,...] <hash>",
+	Long:  "have at least the threshold of the group's participants sign the hash and print the ed25519 signature.\nWith --rounds-dir, use a new directory for each signature",
+	Run:   cliFrostSign,
+}
+
+var frostRespondCmd = &cobra.Command{
+	Use:   "respond",
+	Short: "eris-keys frost respond <request file>",
+	Long:  "answer a coordinator's request file with our daemon, writing the .response.json next to it",
+	Run:   cliFrostRespond,
+}
+
+var frostGroupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "eris-keys frost group --addr <group> [--id <id>]",
+	Long:  "print the public part of a frost group key we hold a share of",
+	Run:   cliFrostGroup,
+}
+
+var convertSigCmd = &cobra.Command{
+	Use:   "convert-sig",
+	Short: "eris-keys convert-sig --sig-format compact|rsv|der <sig> [<hash> <pub>]",
+	Long:  "re-encode a secp256k1 signature as 64 byte r||s (compact), 65 byte r||s||recid (rsv) or DER.\nThe recovery id for rsv is found from the hash and public key if the signature doesn't have one",
+	Run:   cliConvertSig,
+}
+
 var convertCmd = &cobra.Command{
 	Use:   "convert",
 	Short: "eris-keys convert --addr <address>",
@@ -158,8 +350,8 @@ var convertCmd = &cobra.Command{
 
 var hashCmd = &cobra.Command{
 	Use:   "hash",
-	Short: "eris-keys hash <some data>",
-	Long:  "eris-keys hash <some data>",
+	Short: "eris-keys hash [<some data> | --file <path>]",
+	Long:  "eris-keys hash [<some data> | --file <path>]\n\nSupported types: ripemd160, sha256, sha512, sha3 (ethereum's keccak256), keccak224, keccak256, keccak384, keccak512,\nsha3-224, sha3-256, sha3-384, sha3-512 (FIPS 202), blake2b-256, hash256 (double sha256) and hash160 (ripemd160 of sha256)",
 	Run:   cliHash,
 }
 var serverCmd = &cobra.Command{
@@ -175,6 +367,27 @@ var importCmd = &cobra.Command{
 	Run:   cliImport,
 }
 
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log of key operations",
+	Long:  "Inspect the hash-chained audit log of key operations kept by the daemon",
+	Run:   func(cmd *cobra.Command, args []string) { cmd.Help() },
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "eris-keys audit verify",
+	Long:  "check that no entry of the audit log has been altered or removed",
+	Run:   cliAuditVerify,
+}
+
+var auditQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "eris-keys audit query --addr <address>",
+	Long:  "list all audit log entries for a key",
+	Run:   cliAuditQuery,
+}
+
 func addKeysFlags() {
 	EKeys.PersistentFlags().IntVarP(&logLevel, "log", "l", 0, "specify the location of the directory containing key files")
 	EKeys.PersistentFlags().StringVarP(&KeysDir, "dir", "", DefaultDir, "specify the location of the directory containing key files")
@@ -183,18 +396,79 @@ func addKeysFlags() {
 	EKeys.PersistentFlags().StringVarP(&KeyHost, "host", "", DefaultHost, "set the host for talking to the key daemon")
 	EKeys.PersistentFlags().StringVarP(&KeyPort, "port", "", DefaultPort, "set the port for key daemon to listen on")
 
-	keygenCmd.Flags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "specify the type of key to create. Supports 'secp256k1,sha3' (ethereum),  'secp256k1,ripemd160sha2' (bitcoin), 'ed25519,ripemd160' (tendermint)")
+	keygenCmd.Flags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "specify the type of key to create. Supports 'secp256k1,sha3' (ethereum),  'secp256k1,ripemd160sha2' (bitcoin), 'ed25519,ripemd160' (tendermint), 'secp256r1,sha256' (NIST P-256), 'bls12381,sha256' (BLS signatures)")
 	keygenCmd.Flags().BoolVarP(&NoPassword, "no-pass", "", false, "don't use a password for this key")
+	keygenCmd.Flags().StringVarP(&VanityPrefix, "vanity-prefix", "", "", "search for a key whose address starts with the given hex (up to 8 characters). Its progress is printed while it runs")
+	keygenCmd.Flags().IntVarP(&VanityWorkers, "vanity-workers", "", 0, "the number of goroutines searching for a vanity address. At most, and by default, the daemon's number of cpus")
+	keygenCmd.Flags().StringVarP(&EntropySource, "entropy-source", "", "", "where the daemon gets the key's randomness: 'os', 'mixed' (the default), 'file:<path>' to mix in bytes this command reads from a file or device, or 'dice' to mix in dice rolls read from stdin")
 
 	hashCmd.PersistentFlags().StringVarP(&HashType, "type", "t", DefaultHashType, "specify the hash function to use")
 	hashCmd.PersistentFlags().BoolVarP(&HexByte, "hex", "", false, "the input should be hex decoded to bytes first")
+	hashCmd.Flags().StringVarP(&HashFile, "file", "f", "", "hash the contents of a file, or stdin if '-'. With no file or data, stdin is hashed")
 
 	importCmd.PersistentFlags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "import a key")
 	importCmd.Flags().BoolVarP(&NoPassword, "no-pass", "", false, "don't use a password for this key")
+	for _, c := range []*cobra.Command{keygenCmd, importCmd} {
+		c.Flags().BoolVarP(&ValidatorKey, "validator", "", false, "make it a tendermint validator key, which only signs votes and proposals with sign --validator")
+	}
 
 	verifyCmd.PersistentFlags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "key type")
 
+	signCmd.Flags().BoolVarP(&SignValidator, "validator", "", false, "sign hex encoded tendermint vote or proposal sign bytes, refusing to double sign")
+	signCmd.Flags().StringVarP(&SignHash, "hash", "", "none", "hash the message before signing. Supports 'sha256', 'sha3', 'ripemd160' and 'none' (message is already a hex hash)")
+	signCmd.Flags().StringVarP(&SignFile, "file", "f", "", "sign the contents of a file, or stdin if '-'")
+	signCmd.Flags().BoolVarP(&HexByte, "hex", "", false, "with --hash or --eth-personal, the message argument should be hex decoded to bytes first")
+	signCmd.Flags().BoolVarP(&EthPersonal, "eth-personal", "", false, "sign the message with the ethereum personal_sign prefix. Returns r||s||v")
+	signCmd.Flags().StringVarP(&EIP712File, "eip712", "", "", "sign the EIP-712 typed data in the given json file. Returns r||s||v")
+
+	signCmd.Flags().StringVarP(&SigFormat, "sig-format", "", "", "encoding of secp256k1 signatures: 'compact' (r||s), 'rsv' (r||s||recid, the default) or 'der'")
+	signCmd.Flags().BoolVarP(&BatchMode, "batch", "", false, "sign newline delimited hashes read from stdin in one request, printing a signature per line")
+	verifyCmd.Flags().BoolVarP(&BatchMode, "batch", "", false, "verify lines of '<hash> <sig> <pub>' read from stdin in one request, printing true or false per line")
+	verifyCmd.Flags().StringVarP(&SigFormat, "sig-format", "", "", "require the secp256k1 signature to be encoded as 'compact', 'rsv' or 'der'. Any is accepted by default")
+	signCmd.Flags().StringVarP(&SigScheme, "scheme", "", "", "signature scheme for secp256k1 keys: 'ecdsa' (the default) or 'schnorr' (BIP-340)")
+	verifyCmd.Flags().StringVarP(&SigScheme, "scheme", "", "", "signature scheme for secp256k1 keys: 'ecdsa' (the default) or 'schnorr' (BIP-340, the pubkey may be x-only)")
+	pubKeyCmd.Flags().StringVarP(&SigScheme, "scheme", "", "", "with 'schnorr', print the 32 byte x-only pubkey of a secp256k1 key")
+	convertSigCmd.Flags().StringVarP(&SigFormat, "sig-format", "", "", "the format to convert to: 'compact', 'rsv' or 'der'")
+
+	signCmd.Flags().StringVarP(&PartialFile, "partial", "", "", "sign as a member of a multisig, adding the signature to the given file. The file is created if it doesn't exist")
+	signCmd.Flags().StringVarP(&MultisigAddr, "multisig", "", "", "with --partial, the address of the multisig. Defaults to the one in the file")
+	multisigNewCmd.Flags().IntVarP(&Threshold, "threshold", "", 1, "the number of members that must sign")
+	multisigNewCmd.Flags().StringVarP(&KeyType, "type", "t", DefaultKeyType, "the type of the members' keys")
+
+	frostDKGCmd.Flags().IntVarP(&Threshold, "threshold", "", 2, "the number of participants needed to sign")
+	frostDKGCmd.Flags().BoolVarP(&NoPassword, "no-pass", "", false, "with --participants, don't use a password for the daemons' shares")
+	frostRespondCmd.Flags().BoolVarP(&NoPassword, "no-pass", "", false, "don't use a password for our share of a new group key")
+	for _, c := range []*cobra.Command{frostDKGCmd, frostSignCmd} {
+		c.Flags().StringSliceVarP(&FrostParticipants, "participants", "", nil, "the participants' daemons, eg. localhost:4767,otherhost:4767")
+		c.Flags().StringVarP(&FrostDir, "rounds-dir", "", "", "exchange requests and responses with the participants through files in this directory")
+		c.Flags().IntVarP(&FrostN, "n", "", 0, "with --rounds-dir, the number of participants")
+	}
+	frostDKGCmd.Flags().StringSliceVarP(&FrostIdentities, "identities", "", nil, "the addresses of the participants' identity keys, needed by a daemon holding more than one")
+	frostSignCmd.Flags().StringSliceVarP(&FrostIDs, "ids", "", nil, "the participants' ids, needed by a daemon holding more than one share of the group")
+	frostGroupCmd.Flags().StringVarP(&FrostID, "id", "", "", "which of our shares to use, if we hold more than one")
+
+	verifyAggregateCmd.Flags().StringSliceVarP(&AggPubs, "pubs", "", nil, "the signers' public keys")
+	verifyAggregateCmd.Flags().StringSliceVarP(&AggPops, "pops", "", nil, "the proofs of possession of the public keys, in the same order")
+
+	encryptCmd.Flags().StringVarP(&EncryptTo, "to", "", "", "the recipient's public key")
+	encryptCmd.Flags().StringVarP(&EncryptCurve, "type", "t", "", "the curve of the recipient's public key: 'ed25519' or 'secp256k1'")
+
+	ecdhCmd.Flags().StringVarP(&PeerPub, "peer-pub", "", "", "the peer's public key")
+	ecdhCmd.Flags().StringVarP(&ECDHSalt, "salt", "", "", "hex encoded HKDF salt")
+	ecdhCmd.Flags().StringVarP(&ECDHInfo, "info", "", "", "hex encoded HKDF info, eg. to bind the secret to its purpose")
+
+	signTxCmd.Flags().StringVarP(&TxChainID, "chain-id", "", "", "sign a tendermint transaction for the given chain id")
+
+	verifyCmd.Flags().BoolVarP(&EthPersonal, "eth-personal", "", false, "verify an ethereum personal_sign signature. `eris-keys verify --eth-personal <msg> <sig> <pub>`")
+	verifyCmd.Flags().StringVarP(&EIP712File, "eip712", "", "", "verify a signature of the EIP-712 typed data in the given json file. `eris-keys verify --eip712 <file> <sig> <pub>`")
+	verifyCmd.Flags().BoolVarP(&HexByte, "hex", "", false, "with --eth-personal, the message argument should be hex decoded to bytes first")
+
 	unlockCmd.PersistentFlags().IntVarP(&UnlockTime, "time", "t", 10, "number of minutes to unlock key for. defaults to 10, 0 for forever")
+
+	serverCmd.Flags().StringSliceVarP(&CORSAllowedOrigins, "cors-origins", "", nil, "origins allowed to make cross-origin requests (eg. http://localhost:3000). none by default")
+	serverCmd.Flags().StringSliceVarP(&CORSAllowedMethods, "cors-methods", "", nil, "methods allowed for cross-origin requests. defaults to GET,POST")
+	serverCmd.Flags().StringSliceVarP(&CORSAllowedHeaders, "cors-headers", "", nil, "headers allowed for cross-origin requests. defaults to Origin,Accept,Content-Type")
+	serverCmd.Flags().BoolVarP(&crypto.Secp256k1NonceEntropy, "nonce-entropy", "", false, "mix randomness into the deterministic RFC 6979 nonces of secp256k1 signatures")
 }
 
 func checkMakeDataDir(dir string) error {
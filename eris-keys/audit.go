@@ -0,0 +1,267 @@
+package keys
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------
+// audit log
+//
+// Every key operation made by the core functions is appended to
+// <keys dir>/audit.log as a line of json. Each entry contains the hash
+// of the one before it, so removing or editing an entry breaks the chain
+// from that point on. Removing entries from the end doesn't, so the count
+// and hash of the last entry are also kept in <keys dir>/audit.head.
+// Truncating both files together still can't be detected from the keys dir,
+// so keep the head printed by `eris-keys audit verify` somewhere else too.
+
+// hash of the (non-existent) entry before the first one
+var auditGenesis = strings.Repeat("0", 64)
+
+type AuditEntry struct {
+	Time    string `json:"time"`
+	Op      string `json:"op"`
+	Addr    string `json:"addr"`
+	MsgHash string `json:"msg_hash,omitempty"`
+	Client  string `json:"client"`
+	Result  string `json:"result"`
+	Prev    string `json:"prev"`
+	Hash    string `json:"hash"`
+}
+
+// computeHash hashes the entry with its Hash field empty
+func (e AuditEntry) computeHash() string {
+	e.Hash = ""
+	b, _ := json.Marshal(e)
+	h := sha256.Sum256(b)
+	return fmt.Sprintf("%X", h[:])
+}
+
+// auditHead is the count and hash of the last entry, written after each append
+type auditHead struct {
+	Count int    `json:"count"`
+	Hash  string `json:"hash"`
+}
+
+type auditLog struct {
+	mtx       sync.Mutex
+	lastFile  string // file the last hash was read from
+	lastHash  string
+	lastCount int
+}
+
+var auditor = new(auditLog)
+
+func auditLogFile() (string, error) {
+	dir, err := filepath.Abs(KeysDir)
+	if err != nil {
+		return "", err
+	}
+	if err := checkMakeDataDir(dir); err != nil {
+		return "", err
+	}
+	return path.Join(dir, "audit.log"), nil
+}
+
+// auditOp records an operation and its result.
+// It returns opErr if it's not nil, so core functions can pass their error
+// through it, otherwise any error writing the log
+func auditOp(op, addr string, msg []byte, client string, opErr error) error {
+	entry := AuditEntry{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Op:     op,
+		Addr:   strings.ToUpper(addr),
+		Client: client,
+		Result: "ok",
+	}
+	if len(msg) > 0 {
+		h := sha256.Sum256(msg)
+		entry.MsgHash = fmt.Sprintf("%X", h[:])
+	}
+	if opErr != nil {
+		entry.Result = opErr.Error()
+	}
+
+	if err := auditor.append(entry); err != nil {
+		logger.Errorf("Failed to write audit log: %v\n", err)
+		if opErr == nil {
+			return fmt.Errorf("failed to write audit log: %v", err)
+		}
+	}
+	return opErr
+}
+
+func (l *auditLog) append(entry AuditEntry) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	file, err := auditLogFile()
+	if err != nil {
+		return err
+	}
+	if l.lastFile != file {
+		entries, err := readAuditLog(file)
+		if err != nil {
+			return err
+		}
+		// don't chain onto a truncated log, or its head would be overwritten
+		if err := checkAuditHead(file, entries); err != nil {
+			return err
+		}
+		l.lastHash, l.lastCount = auditGenesis, len(entries)
+		if len(entries) > 0 {
+			l.lastHash = entries[len(entries)-1].Hash
+		}
+		l.lastFile = file
+	}
+
+	entry.Prev = l.lastHash
+	entry.Hash = entry.computeHash()
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	l.lastHash = entry.Hash
+	l.lastCount++
+	return writeAuditHead(file, auditHead{l.lastCount, l.lastHash})
+}
+
+func auditHeadFile(logFile string) string {
+	return strings.TrimSuffix(logFile, ".log") + ".head"
+}
+
+// writeAuditHead writes to a temp file and renames it, like saveValidatorState
+func writeAuditHead(logFile string, head auditHead) error {
+	b, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	file := auditHeadFile(logFile)
+	if err := ioutil.WriteFile(file+".tmp", b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// checkAuditHead checks the entries end where the head says they do.
+// Logs from before there were heads have none
+func checkAuditHead(logFile string, entries []AuditEntry) error {
+	b, err := ioutil.ReadFile(auditHeadFile(logFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var head auditHead
+	if err := json.Unmarshal(b, &head); err != nil {
+		return fmt.Errorf("audit head is invalid: %v", err)
+	}
+	if len(entries) < head.Count {
+		return fmt.Errorf("audit log truncated: it has %d entries, the head records %d ending in %s", len(entries), head.Count, head.Hash)
+	}
+	if len(entries) != head.Count || (head.Count > 0 && entries[head.Count-1].Hash != head.Hash) {
+		return fmt.Errorf("audit log doesn't match its head: %d entries ending in %s", head.Count, head.Hash)
+	}
+	return nil
+}
+
+func readAuditLog(file string) ([]AuditEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for i := 1; scanner.Scan(); i++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("audit log line %d is invalid: %v", i, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+//------------------------------------------------------------------------
+// core functions for reading the log
+
+// coreAuditVerify checks the hash chain and the head,
+// and returns the number of entries and the hash of the last one
+func coreAuditVerify() (int, string, error) {
+	auditor.mtx.Lock()
+	defer auditor.mtx.Unlock()
+
+	file, err := auditLogFile()
+	if err != nil {
+		return 0, "", err
+	}
+	entries, err := readAuditLog(file)
+	if err != nil {
+		return 0, "", err
+	}
+	prev := auditGenesis
+	for i, e := range entries {
+		if e.Prev != prev {
+			return i, "", fmt.Errorf("audit log broken at entry %d: previous hash %s does not match %s", i+1, e.Prev, prev)
+		}
+		if h := e.computeHash(); h != e.Hash {
+			return i, "", fmt.Errorf("audit log broken at entry %d: hash %s does not match contents (%s)", i+1, e.Hash, h)
+		}
+		prev = e.Hash
+	}
+	if err := checkAuditHead(file, entries); err != nil {
+		return len(entries), "", err
+	}
+	return len(entries), prev, nil
+}
+
+// coreAuditQuery returns all entries for the address
+func coreAuditQuery(addr string) ([]AuditEntry, error) {
+	if _, err := hex.DecodeString(addr); err != nil {
+		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	file, err := auditLogFile()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readAuditLog(file)
+	if err != nil {
+		return nil, err
+	}
+	var found []AuditEntry
+	for _, e := range entries {
+		if e.Addr == strings.ToUpper(addr) {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
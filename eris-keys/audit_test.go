@@ -0,0 +1,84 @@
+package keys
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eris-keys-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldDir := KeysDir
+	KeysDir = dir
+	defer func() { KeysDir = oldDir }()
+
+	addr, err := coreKeygen(AUTH, keyType, "127.0.0.1:5555")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSign(testSigData, toHex(addr), "127.0.0.1:5555"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSign("not hex", toHex(addr), "127.0.0.1:5555"); err == nil {
+		t.Fatal("Expected signing invalid hex to fail")
+	}
+
+	entries, err := coreAuditQuery(toHex(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %d", len(entries))
+	}
+	if entries[0].Op != "gen" || entries[1].Op != "sign" || entries[1].Result != "ok" || entries[2].Result == "ok" {
+		t.Fatalf("Unexpected audit entries %v", entries)
+	}
+	if entries[1].Client != "127.0.0.1:5555" || entries[1].MsgHash == "" {
+		t.Fatalf("Audit entry is missing client or message hash: %v", entries[1])
+	}
+
+	n, head, err := coreAuditVerify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || head != entries[2].Hash {
+		t.Fatalf("Expected 3 verified entries ending in %s, got %d ending in %s", entries[2].Hash, n, head)
+	}
+
+	// drop the last entry
+	file, _ := auditLogFile()
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	if err := ioutil.WriteFile(file, bytes.Join(lines[:2], nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := coreAuditVerify(); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("Expected truncated audit log to fail verification, got %v", err)
+	}
+	// a restarted daemon won't write to it either
+	auditor.lastFile = ""
+	if _, err := coreSign(testSigData, toHex(addr), ""); err == nil {
+		t.Fatal("Expected signing to fail with a truncated audit log")
+	}
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// tamper with the log
+	b = bytes.Replace(b, []byte(`"op":"sign"`), []byte(`"op":"pub"`), 1)
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := coreAuditVerify(); err == nil {
+		t.Fatal("Expected tampered audit log to fail verification")
+	}
+}
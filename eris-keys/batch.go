@@ -0,0 +1,84 @@
+package keys
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+//------------------------------------------------------------------------
+// batch signing and verification
+//
+// Lists of hex encoded messages, addresses, signatures and pubkeys
+// are passed over http as comma separated strings
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// coreSignBatch signs each hash with the key at the same index in addrs,
+// or with the only key if there's just one. Any error fails the whole batch
+func coreSignBatch(hashes, addrs []string, client string) ([][]byte, error) {
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("no messages to sign")
+	}
+	if len(addrs) != 1 && len(addrs) != len(hashes) {
+		return nil, fmt.Errorf("got %d addresses for %d messages", len(addrs), len(hashes))
+	}
+	sigs := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		addr := addrs[0]
+		if len(addrs) > 1 {
+			addr = addrs[i]
+		}
+		sig, err := coreSign(hash, addr, client)
+		if err != nil {
+			return nil, fmt.Errorf("error signing message %d: %v", i, err)
+		}
+		sigs[i] = sig
+	}
+	return sigs, nil
+}
+
+// coreVerifyBatch verifies each signature of a hash against the pubkey
+// at the same index in pubs, or the only pubkey if there's just one
+func coreVerifyBatch(typ string, pubs, hashes, sigs []string) ([]bool, error) {
+	keyT, err := crypto.KeyTypeFromString(typ)
+	if err != nil {
+		return nil, err
+	}
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("no signatures to verify")
+	}
+	if len(sigs) != len(hashes) {
+		return nil, fmt.Errorf("got %d signatures for %d messages", len(sigs), len(hashes))
+	}
+	if len(pubs) != 1 && len(pubs) != len(hashes) {
+		return nil, fmt.Errorf("got %d pubkeys for %d messages", len(pubs), len(hashes))
+	}
+
+	hashesB := make([][]byte, len(hashes))
+	sigsB := make([][]byte, len(hashes))
+	pubsB := make([][]byte, len(hashes))
+	for i := range hashes {
+		if hashesB[i], err = hex.DecodeString(hashes[i]); err != nil {
+			return nil, fmt.Errorf("hash %d is invalid hex: %s", i, err.Error())
+		}
+		if sigsB[i], err = hex.DecodeString(sigs[i]); err != nil {
+			return nil, fmt.Errorf("sig %d is invalid hex: %s", i, err.Error())
+		}
+		pub := pubs[0]
+		if len(pubs) > 1 {
+			pub = pubs[i]
+		}
+		if pubsB[i], err = hex.DecodeString(pub); err != nil {
+			return nil, fmt.Errorf("pub %d is invalid hex: %s", i, err.Error())
+		}
+	}
+	return crypto.VerifyBatch(keyT.CurveType, hashesB, sigsB, pubsB)
+}
@@ -0,0 +1,87 @@
+package keys
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+//------------------------------------------------------------------------
+// bls12381 proofs of possession and signature aggregation
+//
+// Lists are passed over http as comma separated strings, as for batches
+
+// coreProofOfPossession signs the pubkey of the bls12381 key at addr
+func coreProofOfPossession(addr, client string) (proof []byte, err error) {
+	defer func() {
+		if err = auditOp("pop", addr, nil, client, err); err != nil {
+			proof = nil
+		}
+	}()
+
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	key, err := GetKey(addrB)
+	if err != nil {
+		return nil, err
+	}
+	return key.ProofOfPossession()
+}
+
+func coreVerifyProofOfPossession(pub, proof string) (bool, error) {
+	pubB, err := hex.DecodeString(pub)
+	if err != nil {
+		return false, fmt.Errorf("pub is invalid hex: %s", err.Error())
+	}
+	proofB, err := hex.DecodeString(proof)
+	if err != nil {
+		return false, fmt.Errorf("proof is invalid hex: %s", err.Error())
+	}
+	return crypto.VerifyProofOfPossession(pubB, proofB)
+}
+
+func coreAggregate(sigs []string) ([]byte, error) {
+	sigsB, err := decodeList("sig", sigs)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.AggregateSignatures(sigsB)
+}
+
+// coreVerifyAggregate verifies an aggregate signature of one hash by all the pubkeys,
+// or of each hash by the pubkey at the same index. Unless every pubkey signed a
+// different hash, each one needs a proof of possession
+func coreVerifyAggregate(sig string, hashes, pubs, pops []string) (bool, error) {
+	sigB, err := hex.DecodeString(sig)
+	if err != nil {
+		return false, fmt.Errorf("sig is invalid hex: %s", err.Error())
+	}
+	hashesB, err := decodeList("hash", hashes)
+	if err != nil {
+		return false, err
+	}
+	pubsB, err := decodeList("pub", pubs)
+	if err != nil {
+		return false, err
+	}
+	popsB, err := decodeList("pop", pops)
+	if err != nil {
+		return false, err
+	}
+	return crypto.VerifyAggregate(hashesB, sigB, pubsB, popsB)
+}
+
+func decodeList(name string, list []string) ([][]byte, error) {
+	out := make([][]byte, len(list))
+	for i, s := range list {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s %d is invalid hex: %s", name, i, err.Error())
+		}
+		out[i] = b
+	}
+	return out, nil
+}
@@ -1,10 +1,19 @@
 package keys
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
 
 	. "github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/common/go/common"
 
@@ -26,7 +35,25 @@ func cliKeygen(cmd *cobra.Command, args []string) {
 		auth = hiddenAuth()
 	}
 
-	r, err := Call("gen", map[string]string{"auth": auth, "type": KeyType, "name": KeyName})
+	genArgs := map[string]string{"auth": auth, "type": KeyType, "name": KeyName, "validator": fmt.Sprintf("%v", ValidatorKey)}
+	if VanityPrefix != "" {
+		IfExit(crypto.CheckVanityPrefix(VanityPrefix))
+		logger.Printf("Searching for an address starting with %s. This takes about %.0f tries\n", strings.ToUpper(VanityPrefix), crypto.VanityDifficulty(VanityPrefix))
+		genArgs["vanity"] = VanityPrefix
+		if VanityWorkers > 0 {
+			genArgs["workers"] = strconv.Itoa(VanityWorkers)
+		}
+		search, err := randentropy.GetEntropyCSPRNG(8)
+		IfExit(err)
+		genArgs["search"] = hex.EncodeToString(search)
+		done := make(chan struct{})
+		defer close(done)
+		go printVanityProgress(genArgs["search"], done)
+	}
+	if EntropySource != "" {
+		genArgs["entropy"] = entropySourceArg(EntropySource)
+	}
+	r, err := Call("gen", genArgs)
 	if _, ok := err.(ErrConnectionRefused); ok {
 		ExitConnectErr(err)
 	}
@@ -34,6 +61,56 @@ func cliKeygen(cmd *cobra.Command, args []string) {
 	logger.Println(r)
 }
 
+// printVanityProgress asks the daemon how far the vanity search has got
+// every crypto.VanityProgressInterval until done is closed
+func printVanityProgress(search string, done chan struct{}) {
+	ticker := time.NewTicker(crypto.VanityProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		r, err := Call("gen/progress", map[string]string{"search": search})
+		if err != nil {
+			// the search hasn't started yet, or has just finished
+			continue
+		}
+		p := new(VanityProgress)
+		if err := json.Unmarshal([]byte(r), p); err != nil || p.Rate == 0 {
+			continue
+		}
+		logger.Printf("Tried %d keys (%.0f/s). Expected time: %v, about %v left\n", p.Tried, p.Rate,
+			time.Duration(p.ExpectedSeconds)*time.Second, time.Duration(p.RemainingSeconds)*time.Second)
+	}
+}
+
+// entropySourceArg resolves a --entropy-source for the daemon:
+// dice rolls are read from stdin, and files are read here and their bytes sent,
+// so the daemon never opens a path it's given
+func entropySourceArg(source string) string {
+	switch {
+	case source == "dice":
+		logger.Printf("Enter at least %d rolls of a six sided die (digits 1 to 6), then ctrl-d\n", randentropy.DiceRolls)
+		b, err := ioutil.ReadAll(os.Stdin)
+		IfExit(err)
+		return "dice:" + string(b)
+	case strings.HasPrefix(source, "file:"):
+		f, err := os.Open(strings.TrimPrefix(source, "file:"))
+		IfExit(err)
+		defer f.Close()
+		// a device never ends, so only read what the daemon can use
+		b := make([]byte, randentropy.FileSourceBytes)
+		n, err := io.ReadFull(f, b)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			IfExit(err)
+		}
+		return "bytes:" + hex.EncodeToString(b[:n])
+	}
+	return source
+}
+
 func cliLock(cmd *cobra.Command, args []string) {
 	r, err := Call("lock", map[string]string{"addr": KeyAddr, "name": KeyName})
 	if _, ok := err.(ErrConnectionRefused); ok {
@@ -43,6 +120,72 @@ func cliLock(cmd *cobra.Command, args []string) {
 	logger.Println(r)
 }
 
+func cliECDH(cmd *cobra.Command, args []string) {
+	if PeerPub == "" {
+		Exit(fmt.Errorf("enter the peer's public key with --peer-pub"))
+	}
+	r, err := Call("ecdh", map[string]string{"addr": KeyAddr, "name": KeyName, "pub": PeerPub, "salt": ECDHSalt, "info": ECDHInfo})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+// cliEncrypt encrypts the message argument, or stdin, and prints the envelope
+func cliEncrypt(cmd *cobra.Command, args []string) {
+	if EncryptTo == "" {
+		Exit(fmt.Errorf("enter the recipient's public key with --to"))
+	}
+	if EncryptCurve == "" {
+		Exit(fmt.Errorf("enter the recipient's curve with --type (ed25519 or secp256k1)"))
+	}
+	var msg []byte
+	switch len(args) {
+	case 0:
+		b, err := ioutil.ReadAll(os.Stdin)
+		IfExit(err)
+		msg = b
+	case 1:
+		msg = []byte(args[0])
+	default:
+		Exit(fmt.Errorf("enter a message to encrypt, or pipe it to stdin"))
+	}
+	r, err := Call("encrypt", map[string]string{"type": EncryptCurve, "pub": EncryptTo, "msg": hex.EncodeToString(msg)})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+// cliDecrypt decrypts an envelope (or the path to one, or stdin)
+// and writes the plaintext to stdout
+func cliDecrypt(cmd *cobra.Command, args []string) {
+	var envelope []byte
+	var err error
+	switch len(args) {
+	case 0:
+		envelope, err = ioutil.ReadAll(os.Stdin)
+	case 1:
+		envelope = []byte(args[0])
+		if _, statErr := os.Stat(args[0]); statErr == nil {
+			envelope, err = ioutil.ReadFile(args[0])
+		}
+	default:
+		Exit(fmt.Errorf("enter an envelope to decrypt, or the path to one"))
+	}
+	IfExit(err)
+	r, err := Call("decrypt", map[string]string{"addr": KeyAddr, "name": KeyName, "msg": string(envelope)})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	plainText, err := hex.DecodeString(r)
+	IfExit(err)
+	os.Stdout.Write(plainText)
+}
+
 func cliConvert(cmd *cobra.Command, args []string) {
 	r, err := Call("mint", map[string]string{"addr": KeyAddr, "name": KeyName})
 	if _, ok := err.(ErrConnectionRefused); ok {
@@ -65,7 +208,7 @@ func cliUnlock(cmd *cobra.Command, args []string) {
 // since pubs are not saved, the key needs to be unlocked to get the pub
 // TODO: save the pubkey (backwards compatibly...)
 func cliPub(cmd *cobra.Command, args []string) {
-	r, err := Call("pub", map[string]string{"addr": KeyAddr, "name": KeyName})
+	r, err := Call("pub", map[string]string{"addr": KeyAddr, "name": KeyName, "scheme": SigScheme})
 	if _, ok := err.(ErrConnectionRefused); ok {
 		ExitConnectErr(err)
 	}
@@ -75,11 +218,25 @@ func cliPub(cmd *cobra.Command, args []string) {
 
 func cliSign(cmd *cobra.Command, args []string) {
 	_, addr, name := KeysDir, KeyAddr, KeyName
-	if len(args) != 1 {
-		Exit(fmt.Errorf("enter a msg/hash to sign"))
+	if EthPersonal || EIP712File != "" {
+		cliSignEth(addr, name, args)
+		return
 	}
-	msg := args[0]
-	r, err := Call("sign", map[string]string{"addr": addr, "name": name, "msg": msg})
+	if BatchMode {
+		cliSignBatch(addr, name, args)
+		return
+	}
+	if PartialFile != "" {
+		cliSignPartial(addr, name, args)
+		return
+	}
+	msg, err := signMessage(args)
+	IfExit(err)
+	method := "sign"
+	if SignValidator {
+		method = "sign/validator"
+	}
+	r, err := Call(method, map[string]string{"addr": addr, "name": name, "msg": msg, "format": SigFormat, "scheme": SigScheme})
 	if _, ok := err.(ErrConnectionRefused); ok {
 		ExitConnectErr(err)
 	}
@@ -87,12 +244,371 @@ func cliSign(cmd *cobra.Command, args []string) {
 	logger.Println(r)
 }
 
+func cliSignEth(addr, name string, args []string) {
+	mode, msg, args, err := ethMessage(args)
+	IfExit(err)
+	if len(args) != 0 {
+		Exit(fmt.Errorf("too many arguments"))
+	}
+	r, err := Call("sign/eth", map[string]string{"addr": addr, "name": name, "mode": mode, "msg": msg})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+// ethMessage returns the ethereum signing mode, the hex encoded message
+// and the remaining args. For --eip712 the message is the typed data file.
+// For --eth-personal it's the first argument (hex decoded if --hex), or --file
+func ethMessage(args []string) (mode, msg string, rest []string, err error) {
+	var b []byte
+	switch {
+	case EthPersonal && EIP712File != "":
+		return "", "", nil, fmt.Errorf("choose one of --eth-personal or --eip712")
+	case EIP712File != "":
+		mode = "eip712"
+		b, err = ioutil.ReadFile(EIP712File)
+	case SignFile != "":
+		mode = "personal"
+		if SignFile == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(SignFile)
+		}
+	case len(args) > 0:
+		mode = "personal"
+		b = []byte(args[0])
+		if HexByte {
+			b, err = hex.DecodeString(args[0])
+		}
+		args = args[1:]
+	default:
+		return "", "", nil, fmt.Errorf("enter a message to sign")
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+	return mode, hex.EncodeToString(b), args, nil
+}
+
+// cliSignPartial signs as a member of a multisig and adds the signature to the --partial file.
+// If the file exists, the multisig and the message default to the ones in it
+func cliSignPartial(addr, name string, args []string) {
+	var sigs *MultisigSigs
+	if b, err := ioutil.ReadFile(PartialFile); err == nil {
+		sigs = new(MultisigSigs)
+		IfExit(json.Unmarshal(b, sigs))
+		if sigs.Multisig == nil {
+			Exit(fmt.Errorf("%s is not a multisig signature file", PartialFile))
+		}
+	} else if !os.IsNotExist(err) {
+		IfExit(err)
+	}
+
+	multisig, msg := MultisigAddr, ""
+	if sigs != nil {
+		if multisig == "" {
+			multisig = sigs.Multisig.Address
+		}
+		if len(args) == 0 && SignFile == "" {
+			msg = sigs.Msg
+		}
+	}
+	if multisig == "" {
+		Exit(fmt.Errorf("enter the multisig address with --multisig"))
+	}
+	if msg == "" {
+		var err error
+		msg, err = signMessage(args)
+		IfExit(err)
+	}
+
+	r, err := Call("multisig/sign", map[string]string{"addr": addr, "name": name, "multisig": multisig, "msg": msg})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	partial := new(MultisigSigs)
+	IfExit(json.Unmarshal([]byte(r), partial))
+	if sigs == nil {
+		sigs = partial
+	} else {
+		IfExit(sigs.add(partial))
+	}
+	b, err := json.MarshalIndent(sigs, "", "\t")
+	IfExit(err)
+	IfExit(ioutil.WriteFile(PartialFile, b, 0600))
+	logger.Printf("%d of %d signatures for multisig %s\n", len(sigs.Sigs), sigs.Multisig.Threshold, sigs.Multisig.Address)
+}
+
+// readLines returns the non-empty lines of stdin
+func readLines() ([]string, error) {
+	b, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, l := range strings.Split(string(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
+
+// cliSignBatch signs newline delimited hex hashes from stdin in one request
+// and prints a signature per line. With --hash the lines are messages to hash first
+func cliSignBatch(addr, name string, args []string) {
+	if len(args) != 0 || SignFile != "" {
+		Exit(fmt.Errorf("with --batch the hashes are read from stdin"))
+	}
+	msgs, err := readLines()
+	IfExit(err)
+	if SignHash != "none" && !HexByte {
+		for i, m := range msgs {
+			msgs[i] = hex.EncodeToString([]byte(m))
+		}
+	}
+	r, err := Call("sign/batch", map[string]string{"addr": addr, "name": name, "msgs": strings.Join(msgs, ","), "hash": SignHash, "format": SigFormat})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	var sigs []string
+	IfExit(json.Unmarshal([]byte(r), &sigs))
+	for _, sig := range sigs {
+		logger.Println(sig)
+	}
+}
+
+// signMessage returns the hex encoded message to sign.
+// With --hash none it's the hex hash given as argument, or the raw contents of --file.
+// Otherwise the argument, --file or stdin is streamed through the hasher
+func signMessage(args []string) (string, error) {
+	var r io.Reader
+	switch {
+	case SignFile != "":
+		if len(args) > 0 {
+			return "", fmt.Errorf("enter either a msg/hash or a --file to sign, not both")
+		}
+		if SignFile == "-" {
+			r = os.Stdin
+		} else {
+			f, err := os.Open(SignFile)
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+			r = f
+		}
+	case len(args) == 1:
+		if SignHash == "none" {
+			return args[0], nil
+		}
+		if HexByte {
+			d, err := hex.DecodeString(args[0])
+			if err != nil {
+				return "", fmt.Errorf("msg is invalid hex: %v", err)
+			}
+			r = bytes.NewReader(d)
+		} else {
+			r = strings.NewReader(args[0])
+		}
+	case len(args) == 0 && SignHash != "none":
+		r = os.Stdin
+	default:
+		return "", fmt.Errorf("enter a msg/hash to sign")
+	}
+
+	if SignHash == "none" {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	}
+	h, err := hashReader(SignHash, r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h), nil
+}
+
+func cliSignTx(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		Exit(fmt.Errorf("enter a json transaction or the path to one"))
+	}
+	tx := args[0]
+	if _, err := os.Stat(tx); err == nil {
+		txBytes, err := ioutil.ReadFile(tx)
+		IfExit(err)
+		tx = string(txBytes)
+	}
+	r, err := Call("sign/tx", map[string]string{"addr": KeyAddr, "name": KeyName, "tx": tx, "chain_id": TxChainID})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	if TxChainID != "" {
+		logger.Println(r)
+		return
+	}
+	signed := new(SignedTx)
+	IfExit(json.Unmarshal([]byte(r), signed))
+	logger.Println(signed.Raw)
+	logger.Println(signed.Hash)
+}
+
 func cliVerify(cmd *cobra.Command, args []string) {
+	if EthPersonal || EIP712File != "" {
+		mode, msg, args, err := ethMessage(args)
+		IfExit(err)
+		if len(args) != 2 {
+			Exit(fmt.Errorf("enter a message or typed data, a signature, and a public key"))
+		}
+		r, err := Call("verify/eth", map[string]string{"mode": mode, "pub": args[1], "msg": msg, "sig": args[0]})
+		if _, ok := err.(ErrConnectionRefused); ok {
+			ExitConnectErr(err)
+		}
+		IfExit(err)
+		logger.Println(r)
+		return
+	}
+	if BatchMode {
+		cliVerifyBatch(args)
+		return
+	}
 	if len(args) != 3 {
 		Exit(fmt.Errorf("enter a msg/hash, a signature, and a public key"))
 	}
 	msg, sig, pub := args[0], args[1], args[2]
-	r, err := Call("verify", map[string]string{"type": KeyType, "pub": pub, "msg": msg, "sig": sig})
+	r, err := Call("verify", map[string]string{"type": KeyType, "pub": pub, "msg": msg, "sig": sig, "format": SigFormat, "scheme": SigScheme})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+// cliVerifyBatch verifies lines of "<hash> <sig> <pub>" from stdin in one request
+// and prints true or false for each
+func cliVerifyBatch(args []string) {
+	if len(args) != 0 {
+		Exit(fmt.Errorf("with --batch the hashes, signatures and public keys are read from stdin"))
+	}
+	lines, err := readLines()
+	IfExit(err)
+	var msgs, sigs, pubs []string
+	for i, l := range lines {
+		fields := strings.Fields(l)
+		if len(fields) != 3 {
+			Exit(fmt.Errorf("line %d: expected <hash> <sig> <pub>", i+1))
+		}
+		msgs, sigs, pubs = append(msgs, fields[0]), append(sigs, fields[1]), append(pubs, fields[2])
+	}
+	r, err := Call("verify/batch", map[string]string{"type": KeyType, "pubs": strings.Join(pubs, ","), "msgs": strings.Join(msgs, ","), "sigs": strings.Join(sigs, ",")})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	var results []bool
+	IfExit(json.Unmarshal([]byte(r), &results))
+	for _, res := range results {
+		logger.Println(res)
+	}
+}
+
+// convert-sig doesn't need any keys so it doesn't talk to the daemon
+func cliConvertSig(cmd *cobra.Command, args []string) {
+	if len(args) != 1 && len(args) != 3 {
+		Exit(fmt.Errorf("enter a signature, and for --sig-format rsv the hash and public key"))
+	}
+	if SigFormat == "" {
+		Exit(fmt.Errorf("choose the output format with --sig-format"))
+	}
+	sig, err := hex.DecodeString(args[0])
+	IfExit(err)
+	var out []byte
+	if SigFormat == crypto.SigFormatRSV && len(args) == 3 {
+		hash, err := hex.DecodeString(args[1])
+		IfExit(err)
+		pub, err := hex.DecodeString(args[2])
+		IfExit(err)
+		out, err = crypto.RecoverableSignature(sig, hash, pub)
+		IfExit(err)
+	} else {
+		out, err = crypto.ConvertSignature(sig, SigFormat)
+		IfExit(err)
+	}
+	logger.Printf("%X\n", out)
+}
+
+func cliRecoverPub(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		Exit(fmt.Errorf("enter a hash and a signature"))
+	}
+	r, err := Call("recover", map[string]string{"hash": args[0], "sig": args[1]})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	rec := new(RecoveredKey)
+	IfExit(json.Unmarshal([]byte(r), rec))
+	logger.Println(rec.Pub)
+	for _, addrType := range crypto.AddrTypes {
+		if addr, ok := rec.Addresses[addrType.String()]; ok {
+			logger.Printf("%s %s\n", addrType, addr)
+		}
+	}
+}
+
+func cliPop(cmd *cobra.Command, args []string) {
+	r, err := Call("pop", map[string]string{"addr": KeyAddr, "name": KeyName})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+func cliVerifyPop(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		Exit(fmt.Errorf("enter a public key and its proof of possession"))
+	}
+	r, err := Call("verify/pop", map[string]string{"pub": args[0], "pop": args[1]})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+func cliAggregate(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		Exit(fmt.Errorf("enter the signatures to aggregate"))
+	}
+	r, err := Call("aggregate", map[string]string{"sigs": strings.Join(args, ",")})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+func cliVerifyAggregate(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		Exit(fmt.Errorf("enter an aggregate signature and the hash, or hashes, it signs"))
+	}
+	if len(AggPubs) == 0 {
+		Exit(fmt.Errorf("enter the signers' public keys with --pubs"))
+	}
+	r, err := Call("verify/aggregate", map[string]string{
+		"sig":  args[0],
+		"msgs": strings.Join(args[1:], ","),
+		"pubs": strings.Join(AggPubs, ","),
+		"pops": strings.Join(AggPops, ","),
+	})
 	if _, ok := err.(ErrConnectionRefused); ok {
 		ExitConnectErr(err)
 	}
@@ -100,7 +616,173 @@ func cliVerify(cmd *cobra.Command, args []string) {
 	logger.Println(r)
 }
 
+func cliMultisigNew(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		Exit(fmt.Errorf("enter the members' public keys"))
+	}
+	r, err := Call("multisig/new", map[string]string{"type": KeyType, "threshold": fmt.Sprintf("%d", Threshold), "pubs": strings.Join(args, ","), "name": KeyName})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+// cliMultisigVerify verifies a file of signatures collected by sign --partial, or stdin
+func cliMultisigVerify(cmd *cobra.Command, args []string) {
+	var b []byte
+	var err error
+	switch len(args) {
+	case 0:
+		b, err = ioutil.ReadAll(os.Stdin)
+	case 1:
+		b, err = ioutil.ReadFile(args[0])
+	default:
+		Exit(fmt.Errorf("enter the path to the signature file"))
+	}
+	IfExit(err)
+	if KeyAddr == "" && KeyName == "" {
+		Exit(fmt.Errorf("enter the multisig's address with --addr or --name"))
+	}
+	r, err := Call("multisig/verify", map[string]string{"sigs": string(b), "multisig": KeyAddr, "name": KeyName})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+// frostTransportFlags returns the transport given by --participants or --rounds-dir,
+// and the number of participants
+func frostTransportFlags() (frostTransport, int) {
+	switch {
+	case len(FrostParticipants) > 0 && FrostDir != "":
+		Exit(fmt.Errorf("enter either --participants or --rounds-dir, not both"))
+	case len(FrostParticipants) > 0:
+		t := make(frostHTTP, len(FrostParticipants))
+		for i, p := range FrostParticipants {
+			if !strings.HasPrefix(p, "http://") && !strings.HasPrefix(p, "https://") {
+				p = "http://" + p
+			}
+			t[i] = p
+		}
+		return t, len(t)
+	case FrostDir != "":
+		if FrostN < 1 {
+			Exit(fmt.Errorf("enter the number of participants with --n"))
+		}
+		IfExit(checkMakeDataDir(FrostDir))
+		return &frostFiles{dir: FrostDir}, FrostN
+	}
+	Exit(fmt.Errorf("enter the participants with --participants or --rounds-dir"))
+	return nil, 0
+}
+
+// frostPending exits after listing the request files still to be answered
+func frostPending(t frostTransport) {
+	files, ok := t.(*frostFiles)
+	if !ok {
+		return
+	}
+	logger.Println("Waiting for responses to:")
+	for _, f := range files.pending {
+		logger.Println("\t" + f)
+	}
+	logger.Println("Each participant should run `eris-keys frost respond <file>`, then run this command again")
+	os.Exit(0)
+}
+
+func cliFrostDKG(cmd *cobra.Command, args []string) {
+	t, n := frostTransportFlags()
+	session, err := newFrostSession()
+	IfExit(err)
+	if files, ok := t.(*frostFiles); ok {
+		session, err = files.session()
+		IfExit(err)
+	}
+	// with files, each participant picks their own password when they respond
+	var auth string
+	if _, ok := t.(frostHTTP); ok && !NoPassword {
+		auth = hiddenAuth()
+	}
+	group, err := frostDKG(t, n, Threshold, FrostIdentities, session, KeyName, auth)
+	if err == errFrostPending {
+		frostPending(t)
+	}
+	IfExit(err)
+	logger.Println("Address:", group.Address)
+	logger.Printf("Pubkey: %X\n", group.GroupPub)
+}
+
+func cliFrostSign(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		Exit(fmt.Errorf("enter the hash to sign"))
+	}
+	if KeyAddr == "" && KeyName == "" {
+		Exit(fmt.Errorf("enter the group's address with --addr or --name"))
+	}
+	t, n := frostTransportFlags()
+	sig, err := frostSign(t, n, FrostIDs, KeyAddr, KeyName, args[0])
+	if err == errFrostPending {
+		frostPending(t)
+	}
+	IfExit(err)
+	logger.Printf("%X\n", sig)
+}
+
+// cliFrostRespond answers a coordinator's request file with our daemon.
+// Only frost requests are made, so a request file can't be used to do anything else with our keys
+func cliFrostRespond(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		Exit(fmt.Errorf("enter the path to the request file"))
+	}
+	b, err := ioutil.ReadFile(args[0])
+	IfExit(err)
+	req := new(FrostRequest)
+	IfExit(json.Unmarshal(b, req))
+	if !strings.HasPrefix(req.Method, "frost/") {
+		Exit(fmt.Errorf("refusing to answer a %q request", req.Method))
+	}
+	if req.Method == "frost/dkg/finish" && !NoPassword {
+		req.Args["auth"] = hiddenAuth()
+	}
+	r, err := Call(req.Method, req.Args)
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	responseFile := frostResponseFile(args[0])
+	IfExit(ioutil.WriteFile(responseFile, []byte(r), 0600))
+	logger.Println("Wrote", responseFile)
+}
+
+func cliFrostGroup(cmd *cobra.Command, args []string) {
+	r, err := Call("frost/group", map[string]string{"addr": KeyAddr, "name": KeyName, "id": FrostID})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+// files and stdin are hashed locally rather than sent to the daemon
 func cliHash(cmd *cobra.Command, args []string) {
+	if HashFile != "" || len(args) == 0 {
+		if len(args) != 0 {
+			Exit(fmt.Errorf("enter either something to hash or a --file, not both"))
+		}
+		r := io.Reader(os.Stdin)
+		if HashFile != "" && HashFile != "-" {
+			f, err := os.Open(HashFile)
+			IfExit(err)
+			defer f.Close()
+			r = f
+		}
+		h, err := hashReader(HashType, r)
+		IfExit(err)
+		logger.Printf("%X\n", h)
+		return
+	}
 	if len(args) != 1 {
 		Exit(fmt.Errorf("enter something to hash"))
 	}
@@ -133,7 +815,7 @@ func cliImport(cmd *cobra.Command, args []string) {
 		auth = hiddenAuth()
 	}
 
-	r, err := Call("import", map[string]string{"auth": auth, "name": KeyName, "type": KeyType, "key": key})
+	r, err := Call("import", map[string]string{"auth": auth, "name": KeyName, "type": KeyType, "key": key, "validator": fmt.Sprintf("%v", ValidatorKey)})
 	if _, ok := err.(ErrConnectionRefused); ok {
 		ExitConnectErr(err)
 	}
@@ -183,3 +865,25 @@ func cliNameRm(cmd *cobra.Command, args []string) {
 	IfExit(err)
 	logger.Println(r)
 }
+
+func cliAuditVerify(cmd *cobra.Command, args []string) {
+	r, err := Call("audit/verify", map[string]string{})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	logger.Println(r)
+}
+
+func cliAuditQuery(cmd *cobra.Command, args []string) {
+	r, err := Call("audit/query", map[string]string{"addr": KeyAddr, "name": KeyName})
+	if _, ok := err.(ErrConnectionRefused); ok {
+		ExitConnectErr(err)
+	}
+	IfExit(err)
+	var entries []AuditEntry
+	IfExit(json.Unmarshal([]byte(r), &entries))
+	for _, e := range entries {
+		logger.Printf("%s %s %s msg=%s client=%s result=%s\n", e.Time, e.Op, e.Addr, e.MsgHash, e.Client, e.Result)
+	}
+}
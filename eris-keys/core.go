@@ -1,7 +1,9 @@
 package keys
 
 import (
+	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -11,12 +13,16 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/eris-ltd/eris-keys/crypto"
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+	"github.com/eris-ltd/eris-keys/crypto/sha3"
 
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/tendermint/account"
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/tendermint/wire"
+	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/golang.org/x/crypto/blake2b"
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/golang.org/x/crypto/ripemd160"
 )
 
@@ -25,6 +31,14 @@ var ErrLocked = fmt.Errorf("account is locked")
 var AccountManager *Manager
 
 func GetKey(addr []byte) (*crypto.Key, error) {
+	if isFrostShare(addr) {
+		return nil, fmt.Errorf("%X is a share of a frost group key and can only sign with `eris-keys frost sign`", addr)
+	}
+	return getKey(addr)
+}
+
+// getKey is GetKey for any key, including frost shares
+func getKey(addr []byte) (*crypto.Key, error) {
 	// first check if the key is unlocked
 	k := AccountManager.GetKey(addr)
 	if k != nil {
@@ -98,9 +112,12 @@ func writeKey(keyDir string, addr, keyJson []byte) ([]byte, error) {
 	return addr, nil
 }
 
-func coreImport(auth, keyType, theKey string) ([]byte, error) {
+func coreImport(auth, keyType, theKey, client string) (addr []byte, err error) {
+	defer func() {
+		err = auditOp("import", fmt.Sprintf("%X", addr), nil, client, err)
+	}()
+
 	var keyStore crypto.KeyStore
-	var err error
 
 	logger.Infof("Importing key. Type (%s). Encrypted (%v)\n", keyType, auth != "")
 
@@ -148,9 +165,12 @@ func coreImport(auth, keyType, theKey string) ([]byte, error) {
 	return key.Address, nil
 }
 
-func coreKeygen(auth, keyType string) ([]byte, error) {
+func coreKeygen(auth, keyType, client string) (addr []byte, err error) {
+	defer func() {
+		err = auditOp("gen", fmt.Sprintf("%X", addr), nil, client, err)
+	}()
+
 	var keyStore crypto.KeyStore
-	var err error
 
 	logger.Infof("Generating new key. Type (%s). Encrypted (%v)\n", keyType, auth != "")
 
@@ -176,8 +196,133 @@ func coreKeygen(auth, keyType string) ([]byte, error) {
 	return key.Address, nil
 }
 
-func coreSign(hash, addr string) ([]byte, error) {
+// coreKeygenVanity is coreKeygen for a key whose address starts with the hex prefix.
+// The search runs on the given number of goroutines until it's found or ctx is done.
+// Its progress is logged, and kept for coreVanityProgress under the search id if it's given
+func coreKeygenVanity(ctx context.Context, auth, keyType, prefix, search string, workers int, client string) (addr []byte, err error) {
+	defer func() {
+		err = auditOp("gen", fmt.Sprintf("%X", addr), nil, client, err)
+	}()
+
+	keyT, err := crypto.KeyTypeFromString(keyType)
+	if err != nil {
+		return nil, err
+	}
+	if err := crypto.CheckVanityPrefix(prefix); err != nil {
+		return nil, err
+	}
+	expected := crypto.VanityDifficulty(prefix)
+	logger.Infof("Searching for a key with address prefix %s. Type (%s). Workers (%d). Expected tries (%.0f)\n", strings.ToUpper(prefix), keyType, workers, expected)
+
+	if search != "" {
+		if err := vanitySearches.start(search, expected); err != nil {
+			return nil, err
+		}
+		defer vanitySearches.stop(search)
+	}
+	key, err := crypto.NewVanityKey(ctx, keyT, prefix, workers, func(tried uint64, elapsed time.Duration) {
+		p := newVanityProgress(tried, expected, elapsed)
+		logger.Infof("Tried %d keys (%.0f/s). Expected time: %v, %v elapsed\n", tried, p.Rate, time.Duration(p.ExpectedSeconds)*time.Second, elapsed)
+		if search != "" {
+			vanitySearches.update(search, p)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	var keyStore crypto.KeyStore
+	if auth == "" {
+		if keyStore, err = newKeyStore(); err != nil {
+			return nil, err
+		}
+	} else {
+		keyStore = AccountManager.KeyStore()
+	}
+	if err := keyStore.StoreKey(key, auth); err != nil {
+		return nil, err
+	}
+	logger.Infof("Generated new key. Address (%x). Type (%s). Encrypted (%v)\n", key.Address, key.Type, auth != "")
+	return key.Address, nil
+}
+
+// coreKeygenEntropy is coreKeygen with the private key drawn from an entropy source
+// (see randentropy.ParseSource) instead of the default one
+func coreKeygenEntropy(auth, keyType, source, client string) (addr []byte, err error) {
+	defer func() {
+		err = auditOp("gen", fmt.Sprintf("%X", addr), nil, client, err)
+	}()
+
+	keyT, err := crypto.KeyTypeFromString(keyType)
+	if err != nil {
+		return nil, err
+	}
+	src, err := randentropy.ParseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	logger.Infof("Generating new key. Type (%s). Encrypted (%v). Entropy source (%s)\n", keyType, auth != "", src.Name())
+
+	key, err := crypto.NewKeyFromReader(keyT, src)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key %s %s", keyType, err)
+	}
+
+	var keyStore crypto.KeyStore
+	if auth == "" {
+		if keyStore, err = newKeyStore(); err != nil {
+			return nil, err
+		}
+	} else {
+		keyStore = AccountManager.KeyStore()
+	}
+	if err := keyStore.StoreKey(key, auth); err != nil {
+		return nil, err
+	}
+	logger.Infof("Generated new key. Address (%x). Type (%s). Encrypted (%v)\n", key.Address, key.Type, auth != "")
+	return key.Address, nil
+}
+
+// coreSign signs the hash with the key at addr.
+// client identifies the requester (eg. its remote address)
+// and is checked against the key's signing policy, if any
+func coreSign(hash, addr, client string) ([]byte, error) {
+	return coreSignScheme(hash, addr, "", client)
+}
+
+// coreSignScheme signs with the given signature scheme,
+// or the key's default if it's empty (see crypto.SigSchemeSchnorr).
+// It refuses validator keys, which must sign with coreSignValidator
+func coreSignScheme(hash, addr, scheme, client string) (sig []byte, err error) {
+	defer func() {
+		hashB, _ := hex.DecodeString(hash)
+		if err = auditOp("sign", addr, hashB, client, err); err != nil {
+			sig = nil
+		}
+	}()
+	if err := checkNotValidator(addr); err != nil {
+		return nil, err
+	}
+	return policySign(hash, addr, scheme, client)
+}
+
+// coreSignTx is coreSign for sign bytes the daemon built itself, eg. a tendermint tx's.
+// They can't be mistaken for votes or proposals, so validator keys can sign them
+func coreSignTx(signBytes, addr, client string) (sig []byte, err error) {
+	defer func() {
+		signBytesB, _ := hex.DecodeString(signBytes)
+		if err = auditOp("sign", addr, signBytesB, client, err); err != nil {
+			sig = nil
+		}
+	}()
+	return policySign(signBytes, addr, "", client)
+}
+
+// policySign checks the signing policy and signs. It is not audited
+func policySign(hash, addr, scheme, client string) ([]byte, error) {
 	hashB, err := hex.DecodeString(hash)
 	if err != nil {
 		return nil, fmt.Errorf("hash is invalid hex: %s", err.Error())
@@ -187,18 +332,88 @@ func coreSign(hash, addr string) ([]byte, error) {
 		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
 	}
 
+	policy, err := loadPolicy(addr)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if policy != nil {
+		if err := policy.checkRequest(hashB, client, now); err != nil {
+			return nil, err
+		}
+	}
+
 	key, err := GetKey(addrB)
 	if err != nil {
 		return nil, err
 	}
-	sig, err := key.Sign(hashB)
+
+	if policy != nil {
+		if err := policy.checkUnlock(addrB, now); err != nil {
+			return nil, err
+		}
+		if policy.MaxSigsPerMinute > 0 {
+			if err := sigRates.allow(strings.ToUpper(addr), policy.MaxSigsPerMinute, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sig, err := key.SignScheme(hashB, scheme)
 	if err != nil {
 		return nil, fmt.Errorf("error signing %x using %x: %v", hashB, addrB, err)
 	}
+	if policy != nil && policy.MaxSigsPerMinute > 0 {
+		sigRates.record(strings.ToUpper(addr), now)
+	}
+	metricSignatures.inc(key.Type.String())
 	return sig, nil
 }
 
-func coreVerify(typ, pub, hash, sig string) (result bool, err error) {
+// checkFormatKey returns an error if the key at addr can't make signatures in the format,
+// so that we don't sign only to throw the signature away
+func checkFormatKey(addr, format string) error {
+	if format == "" {
+		return nil
+	}
+	if curve, err := keyCurveType(addr); err != nil {
+		return err
+	} else if curve != crypto.CurveTypeSecp256k1 {
+		return fmt.Errorf("signature formats are only supported for secp256k1 keys")
+	}
+	return nil
+}
+
+// formatSig re-encodes a secp256k1 signature as compact, rsv or der.
+// An empty format leaves the signature as is
+func formatSig(sig []byte, format string) ([]byte, error) {
+	if format == "" {
+		return sig, nil
+	}
+	// only secp256k1 signatures carry a recovery id
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("signature formats are only supported for secp256k1 keys")
+	}
+	return crypto.ConvertSignature(sig, format)
+}
+
+// checkSigFormat returns an error if the hex encoded signature isn't in the format
+func checkSigFormat(sig, format string) error {
+	if format == "" {
+		return nil
+	}
+	sigB, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("sig is invalid hex: %s", err.Error())
+	}
+	return crypto.CheckSignatureFormat(sigB, format)
+}
+
+func coreVerify(typ, pub, hash, sig string) (bool, error) {
+	return coreVerifyScheme(typ, "", pub, hash, sig)
+}
+
+func coreVerifyScheme(typ, scheme, pub, hash, sig string) (result bool, err error) {
 	keyT, err := crypto.KeyTypeFromString(typ)
 	if err != nil {
 		return result, err
@@ -216,7 +431,7 @@ func coreVerify(typ, pub, hash, sig string) (result bool, err error) {
 		return result, fmt.Errorf("sig is invalid hex: %s", err.Error())
 	}
 
-	result, err = crypto.Verify(keyT.CurveType, hashB, sigB, pubB)
+	result, err = crypto.VerifyScheme(keyT.CurveType, scheme, hashB, sigB, pubB)
 	if err != nil {
 		return result, fmt.Errorf("error verifying signature %x for pubkey %x: %v", sigB, pubB, err)
 	}
@@ -240,7 +455,60 @@ func corePub(addr string) ([]byte, error) {
 	return pub, nil
 }
 
-func coreConvert(addr string) ([]byte, error) {
+// keyCurveType returns the curve of the key at addr, for checking it before signing
+func keyCurveType(addr string) (crypto.CurveType, error) {
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return 0, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	key, err := GetKey(addrB)
+	if err != nil {
+		return 0, err
+	}
+	return key.Type.CurveType, nil
+}
+
+// RecoveredKey is the pubkey that made a secp256k1 signature,
+// and its address for each address type
+type RecoveredKey struct {
+	Pub       string            `json:"pub"`
+	Addresses map[string]string `json:"addresses"`
+}
+
+func coreRecover(hash, sig string) (*RecoveredKey, error) {
+	hashB, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("hash is invalid hex: %s", err.Error())
+	}
+	sigB, err := hex.DecodeString(sig)
+	if err != nil {
+		return nil, fmt.Errorf("sig is invalid hex: %s", err.Error())
+	}
+	pub, err := crypto.RecoverPubkey(hashB, sigB)
+	if err != nil {
+		return nil, fmt.Errorf("error recovering pubkey from %x: %v", sigB, err)
+	}
+
+	rec := &RecoveredKey{
+		Pub:       fmt.Sprintf("%X", pub),
+		Addresses: make(map[string]string),
+	}
+	for _, addrType := range crypto.AddrTypes {
+		// ripemd160 addresses are only defined for ed25519 keys
+		if addr := crypto.AddressFromPub(addrType, pub); addr != nil {
+			rec.Addresses[addrType.String()] = fmt.Sprintf("%X", addr)
+		}
+	}
+	return rec, nil
+}
+
+func coreConvert(addr, client string) (privVal []byte, err error) {
+	defer func() {
+		if err = auditOp("convert", addr, nil, client, err); err != nil {
+			privVal = nil
+		}
+	}()
+
 	type privValidator struct {
 		Address    []byte                 `json:"address"`
 		PubKey     account.PubKeyEd25519  `json:"pub_key"`
@@ -270,22 +538,40 @@ func coreConvert(addr string) ([]byte, error) {
 	var privKey account.PrivKeyEd25519
 	copy(privKey[:], key.PrivateKey)
 
-	privVal := &privValidator{
-		Address: []byte(addr),
-		PubKey:  pubKey,
-		PrivKey: privKey,
+	// carry over the last signed height/round/step
+	// so tendermint keeps protecting against double signing,
+	// and keep the key from signing anything else here
+	if err := markValidator(addr); err != nil {
+		return nil, err
+	}
+	state, err := loadValidatorState(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	pv := &privValidator{
+		Address:    []byte(addr),
+		PubKey:     pubKey,
+		PrivKey:    privKey,
+		LastHeight: state.LastHeight,
+		LastRound:  state.LastRound,
+		LastStep:   state.LastStep,
 	}
 
-	return wire.JSONBytes(privVal), nil
+	return wire.JSONBytes(pv), nil
 }
 
-func coreUnlock(auth, addr, timeout string) error {
+func coreUnlock(auth, addr, timeout, client string) (err error) {
+	defer func() {
+		err = auditOp("unlock", addr, nil, client, err)
+	}()
+
 	addrB, err := hex.DecodeString(addr)
 	if err != nil {
 		return fmt.Errorf("addr is invalid hex: %s", err.Error())
 	}
 
-	if _, err := GetKey(addrB); err == nil {
+	if _, err := getKey(addrB); err == nil {
 		return fmt.Errorf("Key is already unlocked or was never encrypted")
 	}
 
@@ -304,16 +590,97 @@ func coreUnlock(auth, addr, timeout string) error {
 	return nil
 }
 
+func coreLock(addr, client string) (err error) {
+	defer func() {
+		err = auditOp("lock", addr, nil, client, err)
+	}()
+
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	return AccountManager.Lock(addrB)
+}
+
+// coreECDH derives a 32 byte key from the shared secret between the key at addr
+// and the peer's pubkey, using HKDF with the optional hex salt and info.
+// The private key never leaves the daemon
+func coreECDH(addr, peerPub, salt, info, client string) (secret []byte, err error) {
+	defer func() {
+		peerB, _ := hex.DecodeString(peerPub)
+		if err = auditOp("ecdh", addr, peerB, client, err); err != nil {
+			secret = nil
+		}
+	}()
+
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	peerB, err := hex.DecodeString(peerPub)
+	if err != nil {
+		return nil, fmt.Errorf("peer pub is invalid hex: %s", err.Error())
+	}
+	saltB, err := hex.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("salt is invalid hex: %s", err.Error())
+	}
+	infoB, err := hex.DecodeString(info)
+	if err != nil {
+		return nil, fmt.Errorf("info is invalid hex: %s", err.Error())
+	}
+
+	key, err := GetKey(addrB)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := key.SharedSecret(peerB)
+	if err != nil {
+		return nil, fmt.Errorf("error computing shared secret for %x: %v", addrB, err)
+	}
+	return crypto.HKDF(shared, saltB, infoB, 32)
+}
+
+// coreEncrypt encrypts the hex message to the pubkey of the given curve, returning the json envelope
+func coreEncrypt(curve, pub, msg string) ([]byte, error) {
+	curveType, err := crypto.CurveTypeFromString(curve)
+	if err != nil {
+		return nil, err
+	}
+	pubB, err := hex.DecodeString(pub)
+	if err != nil {
+		return nil, fmt.Errorf("pub is invalid hex: %s", err.Error())
+	}
+	msgB, err := hex.DecodeString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("msg is invalid hex: %s", err.Error())
+	}
+	return crypto.Encrypt(curveType, pubB, msgB)
+}
+
+// coreDecrypt opens an envelope encrypted to the key at addr
+func coreDecrypt(addr, envelope, client string) (plainText []byte, err error) {
+	defer func() {
+		if err = auditOp("decrypt", addr, []byte(envelope), client, err); err != nil {
+			plainText = nil
+		}
+	}()
+
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	key, err := GetKey(addrB)
+	if err != nil {
+		return nil, err
+	}
+	return key.Decrypt([]byte(envelope))
+}
+
 func coreHash(typ, data string, hexD bool) ([]byte, error) {
-	var hasher hash.Hash
-	switch typ {
-	case "ripemd160":
-		hasher = ripemd160.New()
-	case "sha256":
-		hasher = sha256.New()
-	// case "sha3":
-	default:
-		return nil, fmt.Errorf("Unknown hash type %s", typ)
+	hasher, err := newHasher(typ)
+	if err != nil {
+		return nil, err
 	}
 	if hexD {
 		d, err := hex.DecodeString(data)
@@ -327,6 +694,79 @@ func coreHash(typ, data string, hexD bool) ([]byte, error) {
 	return hasher.Sum(nil), nil
 }
 
+// hash types. "sha3" is ethereum's keccak256, not FIPS SHA3
+var hashTypes = []string{
+	"ripemd160", "sha256", "sha512", "sha3",
+	"keccak224", "keccak256", "keccak384", "keccak512",
+	"sha3-224", "sha3-256", "sha3-384", "sha3-512",
+	"blake2b-256", "hash256", "hash160",
+}
+
+func newHasher(typ string) (hash.Hash, error) {
+	switch typ {
+	case "ripemd160":
+		return ripemd160.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "sha3", "keccak256":
+		return sha3.NewKeccak256(), nil
+	case "keccak224":
+		return sha3.NewKeccak224(), nil
+	case "keccak384":
+		return sha3.NewKeccak384(), nil
+	case "keccak512":
+		return sha3.NewKeccak512(), nil
+	case "sha3-224":
+		return sha3.New224(), nil
+	case "sha3-256":
+		return sha3.New256(), nil
+	case "sha3-384":
+		return sha3.New384(), nil
+	case "sha3-512":
+		return sha3.New512(), nil
+	case "blake2b-256":
+		return blake2b.New256(nil)
+	case "hash256":
+		// bitcoin's double sha256
+		return &outerHash{sha256.New(), sha256.New}, nil
+	case "hash160":
+		// bitcoin's ripemd160 of sha256
+		return &outerHash{sha256.New(), ripemd160.New}, nil
+	default:
+		return nil, fmt.Errorf("Unknown hash type %s. Supported: %s", typ, strings.Join(hashTypes, ", "))
+	}
+}
+
+// outerHash hashes the sum of its inner hash again
+type outerHash struct {
+	hash.Hash
+	outer func() hash.Hash
+}
+
+func (h *outerHash) Sum(b []byte) []byte {
+	o := h.outer()
+	o.Write(h.Hash.Sum(nil))
+	return o.Sum(b)
+}
+
+func (h *outerHash) Size() int {
+	return h.outer().Size()
+}
+
+// hashReader streams everything from r through the hasher
+func hashReader(typ string, r io.Reader) ([]byte, error) {
+	hasher, err := newHasher(typ)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(hasher, r); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
 //----------------------------------------------------------------
 // manage names for keys
 
@@ -339,7 +779,7 @@ func coreNameAdd(name, addr string) error {
 	if err != nil {
 		return err
 	}
-	if _, err := os.Stat(path.Join(keysDir, addr)); err != nil {
+	if _, err := os.Stat(path.Join(keysDir, addr)); err != nil && !isMultisig(addr) && !isFrostGroup(addr) {
 		return fmt.Errorf("Unknown key %s", addr)
 	}
 	return ioutil.WriteFile(path.Join(namesDir, name), []byte(addr), 0600)
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"math/big"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/common/go/common"
@@ -17,7 +20,7 @@ import (
 var (
 	AUTH       = ""
 	KEY_TYPES  = []string{"secp256k1,sha3", "ed25519,ripemd160", "secp256k1,ripemd160sha256"}
-	HASH_TYPES = []string{"sha256", "ripemd160"}
+	HASH_TYPES = []string{"sha256", "ripemd160", "sha512", "sha3", "keccak256", "keccak512", "sha3-224", "sha3-256", "sha3-384", "sha3-512", "blake2b-256", "hash256", "hash160"}
 )
 
 func init() {
@@ -32,7 +35,7 @@ func dumpKey(k *crypto.Key) {
 }
 
 func testKeygenAndPub(t *testing.T, typ string) {
-	addr, err := coreKeygen(AUTH, typ)
+	addr, err := coreKeygen(AUTH, typ, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -55,7 +58,7 @@ func TestKeygenAndPub(t *testing.T) {
 }
 
 func testSignAndVerify(t *testing.T, typ string) {
-	addr, err := coreKeygen(AUTH, typ)
+	addr, err := coreKeygen(AUTH, typ, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -67,7 +70,7 @@ func testSignAndVerify(t *testing.T, typ string) {
 
 	hash := crypto.Sha3([]byte("the hash of something!"))
 
-	sig, err := coreSign(toHex(hash), toHex(addr))
+	sig, err := coreSign(toHex(hash), toHex(addr), "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -107,8 +110,40 @@ type hashInfo struct {
 }
 
 var hashData = map[string]hashInfo{
-	"sha256":    {"hi", "8F434346648F6B96DF89DDA901C5176B10A6D83961DD3C1AC88B59B2DC327AA4"},
-	"ripemd160": {"hi", "242485AB6BFD3502BCB3442EA2E211687B8E4D89"},
+	"sha256":      {"hi", "8F434346648F6B96DF89DDA901C5176B10A6D83961DD3C1AC88B59B2DC327AA4"},
+	"ripemd160":   {"hi", "242485AB6BFD3502BCB3442EA2E211687B8E4D89"},
+	"sha512":      {"hi", "150A14ED5BEA6CC731CF86C41566AC427A8DB48EF1B9FD626664B3BFBB99071FA4C922F33DDE38719B8C8354E2B7AB9D77E0E67FC12843920A712E73D558E197"},
+	"sha3":        {"hi", "7624778DEDC75F8B322B9FA1632A610D40B85E106C7D9BF0E743A9CE291B9C6F"},
+	"keccak256":   {"hi", "7624778DEDC75F8B322B9FA1632A610D40B85E106C7D9BF0E743A9CE291B9C6F"},
+	"keccak512":   {"hi", "02A43A9688CA677D9D145232C48BE9B7B99F6745BB70BAFB6D4F30D92D3277F3D5593EAB808539DA34EADE7ACD354F0E1A5146DA598241771946F26DF743F236"},
+	"sha3-224":    {"hi", "4538AACC6CCAE167EB462BD2D6CED3537EDF6F8D88AF709BE7B130C0"},
+	"sha3-256":    {"hi", "B39C14C8DA3B23811F6415B7E0B33526D7E07A46F2CF0484179435767E4A8804"},
+	"sha3-384":    {"hi", "1A3F82224AE3C0933D97C7E2E5D48B73F93EADF91057A1B54D4EBDD9EC37BD4D73C8FB9B96515DABFC6FA0A33791F3D4"},
+	"sha3-512":    {"hi", "154013CB8140C753F0AC358DA6110FE237481B26C75C3DDC1B59EAF9DD7B46A0A3AEB2CEF164B3C82D65B38A4E26EA9930B7B2CB3C01DA4BA331C95E62CCB9C3"},
+	"blake2b-256": {"hi", "6815CB4AEB1580A91EF673E63FF03BDB6E855C3A896DB3F2765E03281A61134A"},
+	"hash256":     {"hi", "BC4F48D7A8651DC97AE415F0B47A52EF1A2702098202392B88BC925F6E89EE17"},
+	"hash160":     {"hi", "82A16ECBDE8095683BD8237049CCFFC7EF034C48"},
+}
+
+func TestHashReader(t *testing.T) {
+	// streamed in pieces larger than a block, as for a file
+	data := strings.Repeat("hi", 100000)
+	for _, typ := range HASH_TYPES {
+		h1, err := hashReader(typ, strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2, err := coreHash(typ, data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if toHex(h1) != toHex(h2) {
+			t.Fatalf("Streamed %s hash %X doesn't match %X", typ, h1, h2)
+		}
+	}
+	if _, err := hashReader("md5", strings.NewReader(data)); err == nil {
+		t.Fatal("Expected an error for an unknown hash type")
+	}
 }
 
 func TestHash(t *testing.T) {
@@ -117,6 +152,165 @@ func TestHash(t *testing.T) {
 	}
 }
 
+func TestSignEth(t *testing.T) {
+	addr, err := coreKeygen(AUTH, "secp256k1,sha3", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := corePub(toHex(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typedData := `{"types":{"EIP712Domain":[{"name":"name","type":"string"}],"Thing":[{"name":"id","type":"uint256"}]},` +
+		`"primaryType":"Thing","domain":{"name":"test"},"message":{"id":"0x2a"}}`
+	for mode, msg := range map[string]string{
+		"personal": toHex([]byte("hello")),
+		"eip712":   toHex([]byte(typedData)),
+	} {
+		sig, err := coreSignEth(mode, msg, toHex(addr), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sig) != 65 || (sig[64] != 27 && sig[64] != 28) {
+			t.Fatalf("Expected r||s||v signature, got %X", sig)
+		}
+		res, err := coreVerifyEth(mode, toHex(pub), msg, toHex(sig))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res {
+			t.Fatalf("Ethereum signature (mode %s) failed to verify", mode)
+		}
+	}
+
+	addr, err = coreKeygen(AUTH, "ed25519,ripemd160", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the key type is checked before signing, so the failed attempt uses up no signature
+	writeTestPolicy(t, toHex(addr), `{"max_sigs_per_minute": 1}`)
+	if _, err := coreSignEth("personal", toHex([]byte("hello")), toHex(addr), ""); err == nil || !strings.Contains(err.Error(), "secp256k1") {
+		t.Fatalf("Expected ethereum signing with an ed25519 key to fail, got %v", err)
+	}
+	if _, err := coreSign(toHex(crypto.Sha3([]byte("hello"))), toHex(addr), ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignEthTx(t *testing.T) {
+	addr, err := coreKeygen(AUTH, "secp256k1,sha3", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := corePub(toHex(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txJSON := `{"nonce": 1, "gasPrice": "20000000000", "gas": 21000, "to": "0x3535353535353535353535353535353535353535", "value": "0xde0b6b3a7640000", "chainId": 1}`
+	signed, err := coreSignEthTx(txJSON, toHex(addr), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := hex.DecodeString(signed.Raw[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Hash != "0x"+hex.EncodeToString(crypto.Sha3(raw)) {
+		t.Fatalf("Wrong transaction hash %s", signed.Hash)
+	}
+
+	// the signature is the last three items: v = recid + 37 for chainId 1, r and s
+	tx, _ := crypto.EthTxFromJSON([]byte(txJSON))
+	items := rlpListItems(t, raw)
+	if len(items) != 9 {
+		t.Fatalf("Expected 9 items in the signed transaction, got %d", len(items))
+	}
+	v, r, s := new(big.Int).SetBytes(items[6]), items[7], items[8]
+	if v.Int64() != 37 && v.Int64() != 38 {
+		t.Fatalf("Wrong v %v for chainId 1", v)
+	}
+	sig := append(append(make([]byte, 32-len(r)), r...), append(make([]byte, 32-len(s)), s...)...)
+	sig = append(sig, byte(v.Int64()-37))
+	res, err := crypto.Verify(crypto.CurveTypeSecp256k1, tx.SigHash(), sig, pub)
+	if err != nil || !res {
+		t.Fatalf("Transaction signature failed to verify: %v", err)
+	}
+}
+
+// rlpListItems decodes an rlp list of strings
+func rlpListItems(t *testing.T, b []byte) [][]byte {
+	// length returns the offset and length of the payload of the item at b[0]
+	length := func(b []byte, short, long byte) (int, int) {
+		if b[0] < long {
+			return 1, int(b[0] - short)
+		}
+		n := int(b[0] - long + 1)
+		l := new(big.Int).SetBytes(b[1 : 1+n])
+		return 1 + n, int(l.Int64())
+	}
+	if b[0] < 0xc0 {
+		t.Fatal("Expected an rlp list")
+	}
+	off, l := length(b, 0xc0, 0xf8)
+	if off+l != len(b) {
+		t.Fatalf("Wrong rlp list length %d for %d bytes", l, len(b)-off)
+	}
+	var items [][]byte
+	for b = b[off:]; len(b) > 0; {
+		switch {
+		case b[0] < 0x80:
+			items, b = append(items, b[:1]), b[1:]
+		case b[0] < 0xc0:
+			off, l := length(b, 0x80, 0xb8)
+			items, b = append(items, b[off:off+l]), b[off+l:]
+		default:
+			t.Fatal("Unexpected nested rlp list")
+		}
+	}
+	return items
+}
+
+func TestSignMessage(t *testing.T) {
+	data := bytes.Repeat([]byte("a large file "), 100000)
+	f, err := ioutil.TempFile("", "eris-keys-sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(data)
+	f.Close()
+
+	defer func() { SignHash, SignFile = "none", "" }()
+	SignHash, SignFile = "sha256", f.Name()
+	msg, err := signMessage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := hex.EncodeToString(crypto.Sha256(data)); msg != expected {
+		t.Fatalf("Got %s, expected %s", msg, expected)
+	}
+
+	SignFile = ""
+	msg, err = signMessage([]string{"hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ToUpper(msg) != hashData["sha256"].expected {
+		t.Fatalf("Got %s, expected %s", msg, hashData["sha256"].expected)
+	}
+
+	SignHash = "none"
+	msg, err = signMessage([]string{"ABCD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "ABCD" {
+		t.Fatalf("Expected message to be passed through, got %s", msg)
+	}
+}
+
 //--------------------------------------------------------------------------------
 
 func toHex(b []byte) string {
@@ -130,6 +324,10 @@ func checkAddrFromPub(typ string, pub, addr []byte) error {
 		addr2 = crypto.Sha3(pub[1:])[12:]
 	case "secp256k1,ripemd160sha256":
 		addr2 = crypto.Ripemd160(crypto.Sha256(pub))
+	case "secp256r1,sha256":
+		addr2 = crypto.Sha256(append([]byte{0x02 + pub[64]&1}, pub[1:33]...))
+	case "bls12381,sha256":
+		addr2 = crypto.Sha256(pub)
 	case "ed25519,ripemd160":
 		// XXX: something weird here. I have seen this oscillate!
 		// addr2 = binary.BinaryRipemd160(pub)
@@ -144,3 +342,138 @@ func checkAddrFromPub(typ string, pub, addr []byte) error {
 	}
 	return nil
 }
+
+func TestRecover(t *testing.T) {
+	hash := toHex(crypto.Sha256([]byte(testSigData)))
+	for _, typ := range []string{"secp256k1,sha3", "secp256k1,ripemd160sha256"} {
+		addr, err := coreKeygen(AUTH, typ, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := corePub(toHex(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := coreSign(hash, toHex(addr), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := coreRecover(hash, toHex(sig))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Pub != toHex(pub) {
+			t.Fatalf("Recovered wrong pubkey. Got %s, expected %X", rec.Pub, pub)
+		}
+		keyT, _ := crypto.KeyTypeFromString(typ)
+		if rec.Addresses[keyT.AddrType.String()] != toHex(addr) {
+			t.Fatalf("Recovered wrong address for %s. Got %v, expected %X", typ, rec.Addresses, addr)
+		}
+	}
+
+	if _, err := coreRecover(hash, toHex(make([]byte, 64))); err == nil {
+		t.Fatal("Expected recovery from a signature without a recovery id to fail")
+	}
+}
+
+func TestSignFormats(t *testing.T) {
+	addr, err := coreKeygen(AUTH, "secp256k1,sha3", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := corePub(toHex(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := toHex(crypto.Sha256([]byte(testSigData)))
+	sig, err := coreSign(hash, toHex(addr), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{"compact", "rsv", "der"} {
+		fsig, err := formatSig(sig, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkSigFormat(toHex(fsig), format); err != nil {
+			t.Fatal(err)
+		}
+		res, err := coreVerify("secp256k1,sha3", toHex(pub), hash, toHex(fsig))
+		if err != nil || !res {
+			t.Fatalf("%s signature failed to verify: %v", format, err)
+		}
+	}
+	if _, err := formatSig(sig[:64], "der"); err == nil {
+		t.Fatal("Expected formatting an ed25519 sized signature to fail")
+	}
+}
+
+func TestECDH(t *testing.T) {
+	for _, typ := range KEY_TYPES {
+		addr1, err := coreKeygen(AUTH, typ, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr2, err := coreKeygen(AUTH, typ, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub1, _ := corePub(toHex(addr1))
+		pub2, _ := corePub(toHex(addr2))
+
+		s1, err := coreECDH(toHex(addr1), toHex(pub2), "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s2, err := coreECDH(toHex(addr2), toHex(pub1), "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(s1, s2) {
+			t.Fatalf("Shared secrets (type %s) don't match: %X, %X", typ, s1, s2)
+		}
+		s3, err := coreECDH(toHex(addr1), toHex(pub2), "", toHex([]byte("other purpose")), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(s1, s3) {
+			t.Fatalf("Expected different hkdf info to give a different secret")
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	msg := []byte("a secret for the validator")
+	for _, typ := range KEY_TYPES {
+		addr, err := coreKeygen(AUTH, typ, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, _ := corePub(toHex(addr))
+		envelope, err := coreEncrypt(strings.Split(typ, ",")[0], toHex(pub), toHex(msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := coreDecrypt(toHex(addr), string(envelope), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, msg) {
+			t.Fatalf("Decrypted %q (type %s), expected %q", plain, typ, msg)
+		}
+	}
+}
+
+func TestSecp256r1(t *testing.T) {
+	typ := "secp256r1,sha256"
+	testKeygenAndPub(t, typ)
+	testSignAndVerify(t, typ)
+	testServerSignAndVerify(t, typ)
+}
+
+func TestBLS12381(t *testing.T) {
+	typ := "bls12381,sha256"
+	testKeygenAndPub(t, typ)
+	testSignAndVerify(t, typ)
+	testServerSignAndVerify(t, typ)
+}
@@ -0,0 +1,96 @@
+package keys
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+//------------------------------------------------------------------------
+// ethereum signing modes
+//
+// personal: msg is the (hex encoded) message to sign with personal_sign
+// eip712:   msg is the (hex encoded) json typed data
+//
+// signatures are returned as 65 byte r||s||v with v = 27 or 28
+
+func ethDigest(mode, msg string) ([]byte, error) {
+	msgB, err := hex.DecodeString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("msg is invalid hex: %s", err.Error())
+	}
+	switch mode {
+	case "personal":
+		return crypto.EthPersonalHash(msgB), nil
+	case "eip712":
+		return crypto.EIP712Hash(msgB)
+	default:
+		return nil, fmt.Errorf("unknown ethereum signing mode %s", mode)
+	}
+}
+
+func coreSignEth(mode, msg, addr, client string) ([]byte, error) {
+	digest, err := ethDigest(mode, msg)
+	if err != nil {
+		return nil, err
+	}
+	if curve, err := keyCurveType(addr); err != nil {
+		return nil, err
+	} else if curve != crypto.CurveTypeSecp256k1 {
+		return nil, fmt.Errorf("ethereum signatures require a secp256k1 key")
+	}
+	sig, err := coreSign(hex.EncodeToString(digest), addr, client)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.ToEthSignature(sig)
+}
+
+func coreVerifyEth(mode, pub, msg, sig string) (bool, error) {
+	digest, err := ethDigest(mode, msg)
+	if err != nil {
+		return false, err
+	}
+	sigB, err := hex.DecodeString(sig)
+	if err != nil {
+		return false, fmt.Errorf("sig is invalid hex: %s", err.Error())
+	}
+	sigB, err = crypto.FromEthSignature(sigB)
+	if err != nil {
+		return false, err
+	}
+	return coreVerify("secp256k1,sha3", pub, hex.EncodeToString(digest), hex.EncodeToString(sigB))
+}
+
+// SignedTx is the result of signing a transaction
+type SignedTx struct {
+	Raw  string `json:"raw"`
+	Hash string `json:"hash"`
+}
+
+// coreSignEthTx signs the json encoded ethereum transaction,
+// with EIP-155 replay protection if it has a chainId
+func coreSignEthTx(txJSON, addr, client string) (*SignedTx, error) {
+	tx, err := crypto.EthTxFromJSON([]byte(txJSON))
+	if err != nil {
+		return nil, err
+	}
+	if curve, err := keyCurveType(addr); err != nil {
+		return nil, err
+	} else if curve != crypto.CurveTypeSecp256k1 {
+		return nil, fmt.Errorf("ethereum transactions require a secp256k1 key")
+	}
+	sig, err := coreSign(hex.EncodeToString(tx.SigHash()), addr, client)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := tx.EncodeSigned(sig)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedTx{
+		Raw:  "0x" + hex.EncodeToString(raw),
+		Hash: "0x" + hex.EncodeToString(crypto.Sha3(raw)),
+	}, nil
+}
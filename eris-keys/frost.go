@@ -0,0 +1,744 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/tendermint/account"
+	uuid "github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/wayn3h0/go-uuid"
+	"github.com/eris-ltd/eris-keys/crypto"
+	"github.com/eris-ltd/eris-keys/crypto/ed25519"
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+)
+
+//------------------------------------------------------------------------
+// frost threshold ed25519 keys
+//
+// Each participant's daemon holds one share of a group key made by a
+// distributed key generation, so the group's private key never exists in one place.
+// The address of the group is the ed25519,ripemd160 address of its pubkey.
+// Each share is a key in the keystore, encrypted with the participant's password
+// unless they chose not to have one, and must be unlocked to sign.
+//
+// The DKG and signing rounds are driven by a coordinator (see frost_coordinator.go),
+// which only relays public messages and is not trusted. Each participant signs its
+// round 1 package with an identity key, an ed25519,ripemd160 key whose pubkey every other
+// participant's operator has pinned out of band in <keys dir>/frost/identities.json.
+// Secret shares sent between participants are encrypted to a fresh key each participant
+// makes for the session and signs in its package, so only the intended participant can read them.
+// In round 2 every participant also signs a hash of all the packages it was given, and no one
+// stores their share unless everyone saw the same packages, so the coordinator can't show
+// different participants different packages.
+// DKG sessions and signing nonces only live in the daemon's memory,
+// so a restart aborts them and a nonce can never be used twice.
+// They expire, and there can only be so many at once, so they can't fill the memory
+
+// FrostDKGPackage is a participant's public round 1 message of the DKG,
+// signed by its identity key
+type FrostDKGPackage struct {
+	Commitment    *ed25519.DKGCommitment `json:"commitment"`
+	EncryptionPub []byte                 `json:"encryption_pub"`
+	IdentityPub   []byte                 `json:"identity_pub"`
+	Signature     []byte                 `json:"signature"`
+}
+
+// signBytes is what the identity key signs: the package in the session
+func (p *FrostDKGPackage) signBytes(session string, threshold int) []byte {
+	b, _ := json.Marshal(&FrostDKGPackage{Commitment: p.Commitment, EncryptionPub: p.EncryptionPub, IdentityPub: p.IdentityPub})
+	return []byte(fmt.Sprintf("frost-dkg-round1/%s/%d/%s", session, threshold, b))
+}
+
+// FrostDKGRound2 is a participant's round 2 message: its secret shares for the others,
+// and its identity's signature over the hash of all the round 1 packages it was given
+type FrostDKGRound2 struct {
+	Shares []*FrostDKGShare `json:"shares"`
+	Echo   []byte           `json:"echo"`
+}
+
+func frostEchoBytes(session string, digest []byte) []byte {
+	return []byte(fmt.Sprintf("frost-dkg-echo/%s/%X", session, digest))
+}
+
+// FrostDKGShare is a secret share from one participant to another,
+// encrypted to the recipient's encryption pub
+type FrostDKGShare struct {
+	From     uint16 `json:"from"`
+	To       uint16 `json:"to"`
+	Envelope []byte `json:"envelope"`
+}
+
+// FrostGroup is the public part of a group key
+type FrostGroup struct {
+	Address   string            `json:"address"`
+	GroupPub  []byte            `json:"group_pub"`
+	Threshold int               `json:"threshold"`
+	PubShares map[uint16][]byte `json:"pub_shares"`
+
+	// the address of the daemon's own share, to unlock it with
+	ShareAddress string `json:"share_address,omitempty"`
+}
+
+const (
+	// dkg sessions a daemon may be in at once, and how long they may take
+	frostMaxDKGs = 32
+	frostDKGTTL  = time.Hour
+
+	// unused commitments a share may have at once, and how long they last
+	frostMaxNonces = 64
+	frostNonceTTL  = 10 * time.Minute
+)
+
+type frostDKGSession struct {
+	secret   *ed25519.DKGSecret
+	encKey   *crypto.Key
+	identity *crypto.Key
+	pkg      *FrostDKGPackage   // our own
+	packages []*FrostDKGPackage // set in round 2
+	digest   []byte             // of the packages, set in round 2
+	expires  time.Time
+}
+
+type frostNonces struct {
+	nonces  *ed25519.SigningNonces
+	expires time.Time
+}
+
+var frostState = struct {
+	sync.Mutex
+	dkgs map[string]*frostDKGSession
+
+	// by share (see frostShareKey), then by the hiding commitment
+	nonces map[string]map[string]*frostNonces
+}{
+	dkgs:   make(map[string]*frostDKGSession),
+	nonces: make(map[string]map[string]*frostNonces),
+}
+
+// pruneFrostDKGs forgets the expired dkg sessions. frostState must be locked
+func pruneFrostDKGs(now time.Time) {
+	for key, s := range frostState.dkgs {
+		if now.After(s.expires) {
+			delete(frostState.dkgs, key)
+		}
+	}
+}
+
+// frostPendingNonces forgets the share's expired nonces
+// and returns the rest. frostState must be locked
+func frostPendingNonces(share string, now time.Time) map[string]*frostNonces {
+	pending := frostState.nonces[share]
+	for hiding, n := range pending {
+		if now.After(n.expires) {
+			delete(pending, hiding)
+		}
+	}
+	if len(pending) == 0 {
+		delete(frostState.nonces, share)
+		return nil
+	}
+	return pending
+}
+
+func parseFrostID(id string) (uint16, error) {
+	i, err := strconv.ParseUint(id, 10, 16)
+	if err != nil || i == 0 {
+		return 0, fmt.Errorf("participant id must be a number from 1 to 65535")
+	}
+	return uint16(i), nil
+}
+
+func frostDKGKey(session string, id uint16) string {
+	return fmt.Sprintf("%s/%d", session, id)
+}
+
+func frostShareKey(addr string, id uint16) string {
+	return fmt.Sprintf("%s/%d", strings.ToUpper(addr), id)
+}
+
+// frostAddress is the address of the group pub as an ed25519,ripemd160 key
+func frostAddress(groupPub []byte) string {
+	var pub account.PubKeyEd25519
+	copy(pub[:], groupPub)
+	return fmt.Sprintf("%X", pub.Address())
+}
+
+//------------------------------------------------------------------------
+// share storage
+
+func returnFrostDir(dir string) (string, error) {
+	dir = path.Join(dir, "frost")
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return dir, checkMakeDataDir(dir)
+}
+
+func frostGroupDir(addr string) (string, error) {
+	dir, err := returnFrostDir(KeysDir)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, strings.ToUpper(addr)), nil
+}
+
+func isFrostGroup(addr string) bool {
+	dir, err := frostGroupDir(addr)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(dir)
+	return err == nil
+}
+
+// frostShareInfo is the public part of a share, kept at <keys dir>/frost/<GROUP>/<ID>.json.
+// The share's secret is a key in the keystore at its own address, the ed25519,ripemd160
+// address of the participant's public share, so it is encrypted, locked and unlocked like any other key
+type frostShareInfo struct {
+	ID        uint16            `json:"id"`
+	Address   string            `json:"address"`
+	Threshold int               `json:"threshold"`
+	GroupPub  []byte            `json:"group_pub"`
+	PubShares map[uint16][]byte `json:"pub_shares"`
+}
+
+func storeFrostShare(addr, auth string, share *ed25519.KeyShare) error {
+	dir, err := frostGroupDir(addr)
+	if err != nil {
+		return err
+	}
+	if err := checkMakeDataDir(dir); err != nil {
+		return err
+	}
+	info := &frostShareInfo{
+		ID:        share.ID,
+		Address:   frostAddress(share.PubShares[share.ID]),
+		Threshold: share.Threshold,
+		GroupPub:  share.GroupPub,
+		PubShares: share.PubShares,
+	}
+	shareAddr, _ := hex.DecodeString(info.Address)
+	id, _ := uuid.NewRandom()
+	key := &crypto.Key{
+		Id:         id,
+		Type:       crypto.KeyType{CurveType: crypto.CurveTypeEd25519, AddrType: crypto.AddrTypeRipemd160},
+		Address:    shareAddr,
+		PrivateKey: share.Secret,
+	}
+
+	var keyStore crypto.KeyStore
+	if auth == "" {
+		if keyStore, err = newKeyStore(); err != nil {
+			return err
+		}
+	} else {
+		keyStore = AccountManager.KeyStore()
+	}
+	if err := keyStore.StoreKey(key, auth); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(dir, fmt.Sprintf("%d.json", share.ID)), b, 0600)
+}
+
+// loadFrostShareInfo loads the public part of our share of the group key at addr.
+// The id may be left out if the daemon holds only one share of the group
+func loadFrostShareInfo(addr, id string) (*frostShareInfo, error) {
+	dir, err := frostGroupDir(addr)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		files, err := filepath.Glob(path.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		switch len(files) {
+		case 0:
+			return nil, fmt.Errorf("Unknown frost group %s", addr)
+		case 1:
+			id = strings.TrimSuffix(path.Base(files[0]), ".json")
+		default:
+			return nil, fmt.Errorf("this daemon holds %d shares of %s. Choose one with the id", len(files), addr)
+		}
+	}
+	idN, err := parseFrostID(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path.Join(dir, fmt.Sprintf("%d.json", idN)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no share %d of frost group %s", idN, addr)
+		}
+		return nil, err
+	}
+	info := new(frostShareInfo)
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("invalid frost share file for %s: %v", addr, err)
+	}
+	return info, nil
+}
+
+// loadFrostShare loads our share of the group key at addr, with its secret.
+// Like any other key, an encrypted share must be unlocked first
+func loadFrostShare(addr, id string) (*ed25519.KeyShare, error) {
+	info, err := loadFrostShareInfo(addr, id)
+	if err != nil {
+		return nil, err
+	}
+	shareAddr, err := hex.DecodeString(info.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frost share file for %s: %v", addr, err)
+	}
+	key, err := getKey(shareAddr)
+	if err != nil {
+		return nil, fmt.Errorf("share %d of frost group %s (%s): %v", info.ID, addr, info.Address, err)
+	}
+	return &ed25519.KeyShare{
+		ID:        info.ID,
+		Threshold: info.Threshold,
+		Secret:    key.PrivateKey,
+		GroupPub:  info.GroupPub,
+		PubShares: info.PubShares,
+	}, nil
+}
+
+// isFrostShare reports whether the key at addr is a share of a frost group key,
+// which may only be used through the frost rounds
+func isFrostShare(addr []byte) bool {
+	files, err := filepath.Glob(path.Join(KeysDir, "frost", "*", "*.json"))
+	if err != nil {
+		return false
+	}
+	addrHex := fmt.Sprintf("%X", addr)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		info := new(frostShareInfo)
+		if json.Unmarshal(b, info) == nil && info.Address == addrHex {
+			return true
+		}
+	}
+	return false
+}
+
+//------------------------------------------------------------------------
+// identities
+
+// loadFrostIdentities returns the pinned identity pubkeys of the participants we'll run a DKG with,
+// including our own, from <keys dir>/frost/identities.json: a json list of hex ed25519 pubkeys.
+// Like policy files, it is only read by the daemon and must be edited on disk
+func loadFrostIdentities() (map[string]bool, error) {
+	dir, err := returnFrostDir(KeysDir)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path.Join(dir, "identities.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no trusted identities. Pin the participants' identity pubkeys in %s", path.Join(dir, "identities.json"))
+		}
+		return nil, err
+	}
+	var pubs []string
+	if err := json.Unmarshal(b, &pubs); err != nil {
+		return nil, fmt.Errorf("invalid frost identities file: %v", err)
+	}
+	trusted := make(map[string]bool)
+	for _, pub := range pubs {
+		trusted[strings.ToUpper(pub)] = true
+	}
+	return trusted, nil
+}
+
+// frostIdentityKey loads the identity key at addr, which must be pinned.
+// Without an addr, it's the one pinned identity whose key this daemon holds
+func frostIdentityKey(addr string, trusted map[string]bool) (*crypto.Key, error) {
+	if addr == "" {
+		dir, err := returnDataDir(KeysDir)
+		if err != nil {
+			return nil, err
+		}
+		var held []string
+		for pub := range trusted {
+			pubB, err := hex.DecodeString(pub)
+			if err != nil {
+				continue
+			}
+			a := frostAddress(pubB)
+			if _, err := os.Stat(path.Join(dir, a, a)); err == nil {
+				held = append(held, a)
+			}
+		}
+		if len(held) != 1 {
+			return nil, fmt.Errorf("this daemon holds %d of the trusted identity keys. Choose one with the identity", len(held))
+		}
+		addr = held[0]
+	}
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("identity is invalid hex: %s", err.Error())
+	}
+	key, err := GetKey(addrB)
+	if err != nil {
+		return nil, fmt.Errorf("identity key %s: %v", addr, err)
+	}
+	if key.Type.CurveType != crypto.CurveTypeEd25519 {
+		return nil, fmt.Errorf("identity key %s is not an ed25519 key", addr)
+	}
+	pub, err := key.Pubkey()
+	if err != nil {
+		return nil, err
+	}
+	if !trusted[fmt.Sprintf("%X", pub)] {
+		return nil, fmt.Errorf("identity key %s is not in the trusted identities", addr)
+	}
+	return key, nil
+}
+
+//------------------------------------------------------------------------
+// distributed key generation
+
+// coreFrostDKGRound1 starts the DKG session as participant id,
+// signing our package with the identity key at identity
+func coreFrostDKGRound1(session, id, identity string, threshold int) (*FrostDKGPackage, error) {
+	idN, err := parseFrostID(id)
+	if err != nil {
+		return nil, err
+	}
+	if session == "" {
+		return nil, fmt.Errorf("missing dkg session id")
+	}
+	trusted, err := loadFrostIdentities()
+	if err != nil {
+		return nil, err
+	}
+	idKey, err := frostIdentityKey(identity, trusted)
+	if err != nil {
+		return nil, err
+	}
+	idPub, err := idKey.Pubkey()
+	if err != nil {
+		return nil, err
+	}
+	secret, commitment, err := ed25519.DKGRound1(idN, threshold, randentropy.Reader)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := crypto.NewKey(crypto.KeyType{CurveType: crypto.CurveTypeEd25519, AddrType: crypto.AddrTypeRipemd160})
+	if err != nil {
+		return nil, err
+	}
+	encPub, err := encKey.Pubkey()
+	if err != nil {
+		return nil, err
+	}
+	pkg := &FrostDKGPackage{Commitment: commitment, EncryptionPub: encPub, IdentityPub: idPub}
+	if pkg.Signature, err = idKey.Sign(pkg.signBytes(session, threshold)); err != nil {
+		return nil, err
+	}
+
+	frostState.Lock()
+	defer frostState.Unlock()
+	now := time.Now()
+	pruneFrostDKGs(now)
+	key := frostDKGKey(session, idN)
+	if _, ok := frostState.dkgs[key]; ok {
+		return nil, fmt.Errorf("participant %d already joined dkg session %s", idN, session)
+	}
+	if len(frostState.dkgs) >= frostMaxDKGs {
+		return nil, fmt.Errorf("already in %d dkg sessions. Finish one or wait for it to expire", len(frostState.dkgs))
+	}
+	frostState.dkgs[key] = &frostDKGSession{secret: secret, encKey: encKey, identity: idKey, pkg: pkg, expires: now.Add(frostDKGTTL)}
+	return pkg, nil
+}
+
+// coreFrostDKGRound2 checks everyone's round 1 packages and returns
+// a secret share for each of the other participants, encrypted to them,
+// with our signature over the packages
+func coreFrostDKGRound2(session, id string, packages []*FrostDKGPackage) (*FrostDKGRound2, error) {
+	idN, err := parseFrostID(id)
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := loadFrostIdentities()
+	if err != nil {
+		return nil, err
+	}
+	frostState.Lock()
+	defer frostState.Unlock()
+	pruneFrostDKGs(time.Now())
+	s, ok := frostState.dkgs[frostDKGKey(session, idN)]
+	if !ok {
+		return nil, fmt.Errorf("participant %d is not in dkg session %s", idN, session)
+	}
+	if s.packages != nil {
+		return nil, fmt.Errorf("participant %d already did round 2 of dkg session %s", idN, session)
+	}
+
+	threshold := s.secret.Threshold
+	ids := make(map[uint16]bool)
+	identities := make(map[string]uint16)
+	for _, p := range packages {
+		if p.Commitment == nil {
+			return nil, fmt.Errorf("missing commitment in round 1 package")
+		}
+		pid := p.Commitment.ID
+		if ids[pid] {
+			return nil, fmt.Errorf("participant %d is given twice", pid)
+		}
+		ids[pid] = true
+		if err := ed25519.VerifyDKGCommitment(p.Commitment, threshold); err != nil {
+			return nil, err
+		}
+		idPub := fmt.Sprintf("%X", p.IdentityPub)
+		if !trusted[idPub] {
+			return nil, fmt.Errorf("participant %d's identity %s is not trusted", pid, idPub)
+		}
+		if other, ok := identities[idPub]; ok {
+			return nil, fmt.Errorf("participants %d and %d have the same identity", other, pid)
+		}
+		identities[idPub] = pid
+		if ok, err := crypto.Verify(crypto.CurveTypeEd25519, p.signBytes(session, threshold), p.Signature, p.IdentityPub); err != nil || !ok {
+			return nil, fmt.Errorf("participant %d's round 1 package is not signed by its identity", pid)
+		}
+		if pid == idN && !bytes.Equal(p.signBytes(session, threshold), s.pkg.signBytes(session, threshold)) {
+			return nil, fmt.Errorf("our round 1 package was altered")
+		}
+	}
+	if !ids[idN] {
+		return nil, fmt.Errorf("our round 1 package is missing")
+	}
+
+	// the hash of every package, in id order, which everyone must agree on
+	sorted := append([]*FrostDKGPackage{}, packages...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Commitment.ID < sorted[j].Commitment.ID })
+	h := sha256.New()
+	for _, p := range sorted {
+		b := p.signBytes(session, threshold)
+		binary.Write(h, binary.BigEndian, uint32(len(b)))
+		h.Write(b)
+	}
+	digest := h.Sum(nil)
+	echo, err := s.identity.Sign(frostEchoBytes(session, digest))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &FrostDKGRound2{Echo: echo}
+	for _, p := range sorted {
+		if p.Commitment.ID == idN {
+			continue
+		}
+		env, err := crypto.Encrypt(crypto.CurveTypeEd25519, p.EncryptionPub, s.secret.Share(p.Commitment.ID))
+		if err != nil {
+			return nil, fmt.Errorf("error encrypting share for participant %d: %v", p.Commitment.ID, err)
+		}
+		r.Shares = append(r.Shares, &FrostDKGShare{From: idN, To: p.Commitment.ID, Envelope: env})
+	}
+	s.packages, s.digest = sorted, digest
+	return r, nil
+}
+
+// coreFrostDKGFinish checks that every participant signed the same packages as us,
+// decrypts and checks the shares sent to us, and stores our share of the group key.
+// echoes are every participant's round 2 echo by id. Shares for other participants are ignored
+func coreFrostDKGFinish(session, id, auth string, shares []*FrostDKGShare, echoes map[uint16][]byte, client string) (addr string, err error) {
+	defer func() {
+		err = auditOp("frost-dkg", addr, nil, client, err)
+	}()
+
+	idN, err := parseFrostID(id)
+	if err != nil {
+		return "", err
+	}
+	frostState.Lock()
+	defer frostState.Unlock()
+	pruneFrostDKGs(time.Now())
+	key := frostDKGKey(session, idN)
+	s, ok := frostState.dkgs[key]
+	if !ok || s.packages == nil {
+		return "", fmt.Errorf("participant %d has not done round 2 of dkg session %s", idN, session)
+	}
+
+	echo := frostEchoBytes(session, s.digest)
+	for _, p := range s.packages {
+		if ok, err := crypto.Verify(crypto.CurveTypeEd25519, echo, echoes[p.Commitment.ID], p.IdentityPub); err != nil || !ok {
+			// the session can't be finished
+			delete(frostState.dkgs, key)
+			return "", fmt.Errorf("participant %d didn't see the same round 1 packages as us. Aborting dkg session %s", p.Commitment.ID, session)
+		}
+	}
+
+	received := make(map[uint16][]byte)
+	for _, sh := range shares {
+		if sh.To != idN {
+			continue
+		}
+		plain, err := s.encKey.Decrypt(sh.Envelope)
+		if err != nil {
+			return "", fmt.Errorf("error decrypting share from participant %d: %v", sh.From, err)
+		}
+		received[sh.From] = plain
+	}
+	var commitments []*ed25519.DKGCommitment
+	for _, p := range s.packages {
+		commitments = append(commitments, p.Commitment)
+	}
+	keyShare, err := ed25519.DKGFinish(s.secret, commitments, received)
+	if err != nil {
+		return "", err
+	}
+	addr = frostAddress(keyShare.GroupPub)
+	if err := storeFrostShare(addr, auth, keyShare); err != nil {
+		return "", err
+	}
+	delete(frostState.dkgs, key)
+	logger.Infof("Stored share %d of frost group %s. Encrypted (%v)\n", idN, addr, auth != "")
+	return addr, nil
+}
+
+//------------------------------------------------------------------------
+// signing
+
+func coreFrostGroup(addr, id string) (*FrostGroup, error) {
+	info, err := loadFrostShareInfo(addr, id)
+	if err != nil {
+		return nil, err
+	}
+	return &FrostGroup{
+		Address:   frostAddress(info.GroupPub),
+		GroupPub:  info.GroupPub,
+		Threshold: info.Threshold,
+		PubShares: info.PubShares,
+
+		ShareAddress: info.Address,
+	}, nil
+}
+
+// coreFrostCommit makes the nonces for one signature and returns their commitment.
+// They must be used within frostNonceTTL
+func coreFrostCommit(addr, id string) (*ed25519.SigningCommitment, error) {
+	share, err := loadFrostShare(addr, id)
+	if err != nil {
+		return nil, err
+	}
+	frostState.Lock()
+	defer frostState.Unlock()
+	now := time.Now()
+	key := frostShareKey(addr, share.ID)
+	pending := frostPendingNonces(key, now)
+	if len(pending) >= frostMaxNonces {
+		return nil, fmt.Errorf("share %d of frost group %s already has %d unused commitments. Sign with them or wait for them to expire", share.ID, addr, len(pending))
+	}
+	nonces, err := share.Commit(randentropy.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		pending = make(map[string]*frostNonces)
+		frostState.nonces[key] = pending
+	}
+	pending[fmt.Sprintf("%X", nonces.Commitment.Hiding)] = &frostNonces{nonces: nonces, expires: now.Add(frostNonceTTL)}
+	return nonces.Commitment, nil
+}
+
+// coreFrostSign makes our signature share of the hash, given every signer's commitment.
+// The group's signing policy, if any, is checked as for any other key.
+// Once the request is allowed, the nonces behind our commitment are forgotten whether or not signing succeeds
+func coreFrostSign(addr, id, hash string, commitments []*ed25519.SigningCommitment, client string) (sigShare *ed25519.SignatureShare, err error) {
+	defer func() {
+		hashB, _ := hex.DecodeString(hash)
+		if err = auditOp("frost-sign", addr, hashB, client, err); err != nil {
+			sigShare = nil
+		}
+	}()
+
+	hashB, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("hash is invalid hex: %s", err.Error())
+	}
+	info, err := loadFrostShareInfo(addr, id)
+	if err != nil {
+		return nil, err
+	}
+	shareAddr, err := hex.DecodeString(info.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frost share file for %s: %v", addr, err)
+	}
+
+	// the group's signing policy, as for any other key
+	policy, err := loadPolicy(addr)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if policy != nil {
+		if err := policy.checkRequest(hashB, client, now); err != nil {
+			return nil, err
+		}
+	}
+	share, err := loadFrostShare(addr, strconv.Itoa(int(info.ID)))
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		if err := policy.checkUnlock(shareAddr, now); err != nil {
+			return nil, err
+		}
+		if policy.MaxSigsPerMinute > 0 {
+			if err := sigRates.allow(info.Address, policy.MaxSigsPerMinute, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var own *ed25519.SigningCommitment
+	for _, c := range commitments {
+		if c.ID == share.ID {
+			own = c
+			break
+		}
+	}
+	if own == nil {
+		return nil, fmt.Errorf("our commitment is missing")
+	}
+
+	frostState.Lock()
+	pending := frostPendingNonces(frostShareKey(addr, share.ID), time.Now())
+	hiding := fmt.Sprintf("%X", own.Hiding)
+	nonces := pending[hiding]
+	delete(pending, hiding)
+	frostState.Unlock()
+	if nonces == nil {
+		return nil, fmt.Errorf("unknown commitment. Nonces can only be used once, expire after %v and are lost if the daemon restarts", frostNonceTTL)
+	}
+	if sigShare, err = share.Sign(nonces.nonces, hashB, commitments); err != nil {
+		return nil, err
+	}
+	if policy != nil && policy.MaxSigsPerMinute > 0 {
+		sigRates.record(info.Address, now)
+	}
+	metricSignatures.inc("frost")
+	return sigShare, nil
+}
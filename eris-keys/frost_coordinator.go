@@ -0,0 +1,279 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/eris-ltd/eris-keys/crypto/ed25519"
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
+)
+
+//------------------------------------------------------------------------
+// frost coordinator
+//
+// The coordinator runs the rounds of the DKG and of signing by relaying
+// the participants' public messages. It is not trusted: the DKG packages are
+// signed by the participants' pinned identity keys, and the participants check
+// they were all given the same ones, so it can't swap or withhold anything undetected.
+// It reaches the participants' daemons over http, or through files:
+// it writes a request file for each participant, who answers it with
+// `eris-keys frost respond <file>` using their own daemon.
+// The coordinator is then run again to pick up the responses
+// and make the requests of the next round
+
+var errFrostPending = errors.New("waiting for responses")
+
+type frostTransport interface {
+	// call returns errFrostPending if participant i hasn't answered yet
+	call(i int, method string, args map[string]string) (string, error)
+}
+
+// frostHTTP reaches each participant's daemon by its address, eg. http://localhost:4767
+type frostHTTP []string
+
+func (t frostHTTP) call(i int, method string, args map[string]string) (string, error) {
+	return callAt(t[i], method, args)
+}
+
+// FrostRequest is a request file for a participant
+type FrostRequest struct {
+	Method string            `json:"method"`
+	Args   map[string]string `json:"args"`
+}
+
+// frostFiles exchanges requests and responses through files in a directory.
+// The response to <round>-<i>.request.json is <round>-<i>.response.json
+type frostFiles struct {
+	dir     string
+	pending []string // requests without a response
+}
+
+func frostResponseFile(requestFile string) string {
+	return strings.TrimSuffix(requestFile, ".request.json") + ".response.json"
+}
+
+func (t *frostFiles) call(i int, method string, args map[string]string) (string, error) {
+	requestFile := path.Join(t.dir, fmt.Sprintf("%s-%d.request.json", strings.Replace(method, "/", "-", -1), i+1))
+	b, err := ioutil.ReadFile(frostResponseFile(requestFile))
+	if err == nil {
+		return string(b), nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	if _, err := os.Stat(requestFile); os.IsNotExist(err) {
+		b, err := json.MarshalIndent(&FrostRequest{method, args}, "", "\t")
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(requestFile, b, 0600); err != nil {
+			return "", err
+		}
+	}
+	t.pending = append(t.pending, requestFile)
+	return "", errFrostPending
+}
+
+// frostSession returns the session id kept in the directory, making one if need be
+func (t *frostFiles) session() (string, error) {
+	file := path.Join(t.dir, "session")
+	if b, err := ioutil.ReadFile(file); err == nil {
+		return strings.TrimSpace(string(b)), nil
+	}
+	session, err := newFrostSession()
+	if err != nil {
+		return "", err
+	}
+	return session, ioutil.WriteFile(file, []byte(session), 0600)
+}
+
+func newFrostSession() (string, error) {
+	b, err := randentropy.GetEntropyCSPRNG(16)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// frostRound calls each of the n participants and returns their responses.
+// If any haven't answered, it returns errFrostPending once all the requests are made
+func frostRound(t frostTransport, n int, method string, args func(i int) map[string]string) ([]string, error) {
+	results := make([]string, n)
+	var pending bool
+	for i := 0; i < n; i++ {
+		r, err := t.call(i, method, args(i))
+		if err == errFrostPending {
+			pending = true
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("participant %d: %v", i+1, err)
+		}
+		results[i] = r
+	}
+	if pending {
+		return nil, errFrostPending
+	}
+	return results, nil
+}
+
+// frostDKG runs the key generation with n participants, who get ids 1 to n,
+// and returns the group key. identities may give the address of each participant's identity key,
+// which their daemon needs if it holds more than one. If auth is given, every daemon encrypts its share with it
+func frostDKG(t frostTransport, n, threshold int, identities []string, session, name, auth string) (*FrostGroup, error) {
+	if threshold < 1 || threshold > n {
+		return nil, fmt.Errorf("threshold must be between 1 and the number of participants (%d)", n)
+	}
+	if len(identities) != 0 && len(identities) != n {
+		return nil, fmt.Errorf("got %d identities for %d participants", len(identities), n)
+	}
+	id := func(i int) string { return strconv.Itoa(i + 1) }
+
+	r1, err := frostRound(t, n, "frost/dkg/round1", func(i int) map[string]string {
+		a := map[string]string{"session": session, "id": id(i), "threshold": strconv.Itoa(threshold)}
+		if len(identities) > 0 {
+			a["identity"] = identities[i]
+		}
+		return a
+	})
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]*FrostDKGPackage, n)
+	for i, r := range r1 {
+		packages[i] = new(FrostDKGPackage)
+		if err := json.Unmarshal([]byte(r), packages[i]); err != nil {
+			return nil, fmt.Errorf("participant %d: invalid round 1 package: %v", i+1, err)
+		}
+		if packages[i].Commitment == nil || packages[i].Commitment.ID != uint16(i+1) {
+			return nil, fmt.Errorf("participant %d: round 1 package has the wrong id", i+1)
+		}
+	}
+	packagesJ, err := json.Marshal(packages)
+	if err != nil {
+		return nil, err
+	}
+
+	r2, err := frostRound(t, n, "frost/dkg/round2", func(i int) map[string]string {
+		return map[string]string{"session": session, "id": id(i), "packages": string(packagesJ)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var shares []*FrostDKGShare
+	echoes := make(map[uint16][]byte)
+	for i, r := range r2 {
+		r2i := new(FrostDKGRound2)
+		if err := json.Unmarshal([]byte(r), r2i); err != nil {
+			return nil, fmt.Errorf("participant %d: invalid round 2 shares: %v", i+1, err)
+		}
+		shares = append(shares, r2i.Shares...)
+		echoes[uint16(i+1)] = r2i.Echo
+	}
+	echoesJ, err := json.Marshal(echoes)
+	if err != nil {
+		return nil, err
+	}
+
+	r3, err := frostRound(t, n, "frost/dkg/finish", func(i int) map[string]string {
+		var theirs []*FrostDKGShare
+		for _, s := range shares {
+			if s.To == uint16(i+1) {
+				theirs = append(theirs, s)
+			}
+		}
+		sharesJ, _ := json.Marshal(theirs)
+		a := map[string]string{"session": session, "id": id(i), "shares": string(sharesJ), "echoes": string(echoesJ), "name": name}
+		if auth != "" {
+			a["auth"] = auth
+		}
+		return a
+	})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*FrostGroup, n)
+	for i, r := range r3 {
+		groups[i] = new(FrostGroup)
+		if err := json.Unmarshal([]byte(r), groups[i]); err != nil {
+			return nil, fmt.Errorf("participant %d: invalid group: %v", i+1, err)
+		}
+		if !bytes.Equal(groups[i].GroupPub, groups[0].GroupPub) {
+			return nil, fmt.Errorf("participant %d got group %s, but participant 1 got %s", i+1, groups[i].Address, groups[0].Address)
+		}
+	}
+	return groups[0], nil
+}
+
+// frostSign has n participants sign the hash with their shares of the group key.
+// ids may give each participant's id, which their daemon needs if it holds more than one share
+func frostSign(t frostTransport, n int, ids []string, addr, name, hash string) ([]byte, error) {
+	if len(ids) != 0 && len(ids) != n {
+		return nil, fmt.Errorf("got %d ids for %d participants", len(ids), n)
+	}
+	hashB, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("hash is invalid hex: %s", err.Error())
+	}
+	args := func(i int) map[string]string {
+		a := map[string]string{"addr": addr, "name": name}
+		if len(ids) > 0 {
+			a["id"] = ids[i]
+		}
+		return a
+	}
+
+	// the group's public shares come from the first participant
+	groupR, groupErr := t.call(0, "frost/group", args(0))
+	if groupErr != nil && groupErr != errFrostPending {
+		return nil, fmt.Errorf("participant 1: %v", groupErr)
+	}
+	r1, err := frostRound(t, n, "frost/commit", args)
+	if err == nil {
+		err = groupErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	group := new(FrostGroup)
+	if err := json.Unmarshal([]byte(groupR), group); err != nil {
+		return nil, fmt.Errorf("participant 1: invalid group: %v", err)
+	}
+	if n < group.Threshold {
+		return nil, fmt.Errorf("%d participants is fewer than the threshold of %d", n, group.Threshold)
+	}
+	commitments := make([]*ed25519.SigningCommitment, n)
+	for i, r := range r1 {
+		commitments[i] = new(ed25519.SigningCommitment)
+		if err := json.Unmarshal([]byte(r), commitments[i]); err != nil {
+			return nil, fmt.Errorf("participant %d: invalid commitment: %v", i+1, err)
+		}
+	}
+	commitmentsJ, err := json.Marshal(commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	r2, err := frostRound(t, n, "frost/sign", func(i int) map[string]string {
+		a := args(i)
+		a["msg"], a["commitments"] = hash, string(commitmentsJ)
+		return a
+	})
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]*ed25519.SignatureShare, n)
+	for i, r := range r2 {
+		shares[i] = new(ed25519.SignatureShare)
+		if err := json.Unmarshal([]byte(r), shares[i]); err != nil {
+			return nil, fmt.Errorf("participant %d: invalid signature share: %v", i+1, err)
+		}
+	}
+	return ed25519.Aggregate(group.GroupPub, hashB, commitments, shares, group.PubShares)
+}
@@ -0,0 +1,353 @@
+package keys
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+	"github.com/eris-ltd/eris-keys/crypto/ed25519"
+)
+
+func verifyFrostSig(t *testing.T, group *FrostGroup, hash string, sig []byte) {
+	var pub [ed25519.PublicKeySize]byte
+	var sigA [ed25519.SignatureSize]byte
+	copy(pub[:], group.GroupPub)
+	copy(sigA[:], sig)
+	hashB, _ := hex.DecodeString(hash)
+	if !ed25519.Verify(&pub, hashB, &sigA) {
+		t.Fatal("FROST signature failed to verify as ed25519")
+	}
+}
+
+// frostIdentities makes n identity keys and pins them as the only trusted ones
+func frostSession(t *testing.T) string {
+	session, err := newFrostSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func frostIdentities(t *testing.T, n int) []string {
+	var addrs, pubs []string
+	for i := 0; i < n; i++ {
+		addr, err := coreKeygen("", "ed25519,ripemd160", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := corePub(toHex(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs, pubs = append(addrs, toHex(addr)), append(pubs, toHex(pub))
+	}
+	writeFrostIdentities(t, pubs)
+	return addrs
+}
+
+func writeFrostIdentities(t *testing.T, pubs []string) {
+	dir, err := returnFrostDir(KeysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(pubs)
+	if err := ioutil.WriteFile(filepath.Join(dir, "identities.json"), b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the test daemon plays all the participants
+func TestFrostHTTP(t *testing.T) {
+	participants := frostHTTP{TestAddr, TestAddr, TestAddr}
+	identities := frostIdentities(t, 3)
+	group, err := frostDKG(participants, 3, 2, identities, frostSession(t), "frostgroup", "frostpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Address == "" || group.Threshold != 2 || len(group.PubShares) != 3 {
+		t.Fatalf("Wrong group: %s with threshold %d and %d pub shares", group.Address, group.Threshold, len(group.PubShares))
+	}
+
+	// the shares are encrypted, and only sign through the frost rounds
+	hash := toHex(crypto.Sha3([]byte(testSigData)))
+	if _, err := frostSign(participants[:2], 2, []string{"1", "3"}, "", "frostgroup", hash); err == nil {
+		t.Fatal("Expected an error signing with locked shares")
+	}
+	for id, pub := range group.PubShares {
+		shareAddr := frostAddress(pub)
+		if _, err := callAt(TestAddr, "sign", map[string]string{"addr": shareAddr, "msg": hash}); err == nil {
+			t.Fatalf("Expected an error signing directly with share %d", id)
+		}
+		if _, err := callAt(TestAddr, "unlock", map[string]string{"addr": shareAddr, "auth": "frostpass"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sig, err := frostSign(participants[:2], 2, []string{"1", "3"}, "", "frostgroup", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyFrostSig(t, group, hash, sig)
+
+	if _, err := frostSign(participants[:1], 1, []string{"2"}, group.Address, "", hash); err == nil {
+		t.Fatal("Expected an error signing with fewer than the threshold")
+	}
+	if _, err := frostSign(participants[:2], 2, nil, group.Address, "", hash); err == nil {
+		t.Fatal("Expected an error signing without an id when the daemon holds several shares")
+	}
+}
+
+// respondAll answers the pending requests as `frost respond` would
+func respondAll(t *testing.T, files *frostFiles) {
+	for _, f := range files.pending {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := new(FrostRequest)
+		if err := json.Unmarshal(b, req); err != nil {
+			t.Fatal(err)
+		}
+		r, err := callAt(TestAddr, req.Method, req.Args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(frostResponseFile(f), []byte(r), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files.pending = nil
+}
+
+func TestFrostFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "frost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	identities := frostIdentities(t, 2)
+	dkgDir := filepath.Join(dir, "dkg")
+	os.Mkdir(dkgDir, 0700)
+	files := &frostFiles{dir: dkgDir}
+	session, err := files.session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var group *FrostGroup
+	for round := 0; ; round++ {
+		if round > 3 {
+			t.Fatal("DKG took too many rounds")
+		}
+		if group, err = frostDKG(files, 2, 2, identities, session, "", ""); err != errFrostPending {
+			break
+		}
+		respondAll(t, files)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signDir := filepath.Join(dir, "sign")
+	os.Mkdir(signDir, 0700)
+	files = &frostFiles{dir: signDir}
+	hash := toHex(crypto.Sha3([]byte(testSigData)))
+	ids := []string{"1", "2"}
+	var sig []byte
+	for round := 0; ; round++ {
+		if round > 2 {
+			t.Fatal("Signing took too many rounds")
+		}
+		if sig, err = frostSign(files, 2, ids, group.Address, "", hash); err != errFrostPending {
+			break
+		}
+		respondAll(t, files)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyFrostSig(t, group, hash, sig)
+
+	// the signing requests can't be answered again with the same nonces
+	b, _ := ioutil.ReadFile(filepath.Join(signDir, "frost-sign-1.request.json"))
+	req := new(FrostRequest)
+	json.Unmarshal(b, req)
+	if _, err := callAt(TestAddr, req.Method, req.Args); err == nil {
+		t.Fatal("Expected an error reusing signing nonces")
+	}
+}
+
+func TestFrostPolicy(t *testing.T) {
+	participants := frostHTTP{TestAddr, TestAddr}
+	group, err := frostDKG(participants, 2, 2, frostIdentities(t, 2), frostSession(t), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := toHex(crypto.Sha3([]byte(testSigData)))
+	ids := []string{"1", "2"}
+
+	writeTestPolicy(t, group.Address, `{"client_addrs": ["10.0.0.1"]}`)
+	if _, err := frostSign(participants, 2, ids, group.Address, "", hash); err == nil || !strings.Contains(err.Error(), "policy violation") {
+		t.Fatalf("Expected a policy violation for a disallowed client, got %v", err)
+	}
+
+	writeTestPolicy(t, group.Address, `{"max_sigs_per_minute": 1}`)
+	sig, err := frostSign(participants, 2, ids, group.Address, "", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyFrostSig(t, group, hash, sig)
+	if _, err := frostSign(participants, 2, ids, group.Address, "", hash); err == nil || !strings.Contains(err.Error(), "policy violation") {
+		t.Fatalf("Expected a policy violation for exceeding the rate limit, got %v", err)
+	}
+}
+
+func TestFrostLimits(t *testing.T) {
+	participants := frostHTTP{TestAddr, TestAddr}
+	identities := frostIdentities(t, 2)
+	group, err := frostDKG(participants, 2, 2, identities, frostSession(t), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := toHex(crypto.Sha3([]byte(testSigData)))
+
+	// commitments that are never used run out
+	for i := 0; i < frostMaxNonces; i++ {
+		if _, err := coreFrostCommit(group.Address, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := coreFrostCommit(group.Address, "1"); err == nil {
+		t.Fatal("Expected an error for too many unused commitments")
+	}
+	c2, err := coreFrostCommit(group.Address, "2")
+	if err != nil {
+		t.Fatalf("Another share's commitments were limited: %v", err)
+	}
+
+	// until they expire
+	expireFrostNonces(frostShareKey(group.Address, 1))
+	c1, err := coreFrostCommit(group.Address, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frostState.Lock()
+	pending := len(frostState.nonces[frostShareKey(group.Address, 1)])
+	frostState.Unlock()
+	if pending != 1 {
+		t.Fatalf("Expected the expired nonces to be forgotten, %d left", pending)
+	}
+	expireFrostNonces(frostShareKey(group.Address, 1))
+	if _, err := coreFrostSign(group.Address, "1", hash, []*ed25519.SigningCommitment{c1, c2}, ""); err == nil || !strings.Contains(err.Error(), "unknown commitment") {
+		t.Fatalf("Expected an expired commitment to be refused, got %v", err)
+	}
+
+	// dkg sessions expire
+	session := frostSession(t)
+	if _, err := coreFrostDKGRound1(session, "1", identities[0], 2); err != nil {
+		t.Fatal(err)
+	}
+	expireFrostDKGs()
+	if _, err := coreFrostDKGRound2(session, "1", nil); err == nil || !strings.Contains(err.Error(), "not in dkg session") {
+		t.Fatalf("Expected an expired dkg session to be refused, got %v", err)
+	}
+
+	// and there can only be so many
+	var err2 error
+	for i := 0; i <= frostMaxDKGs && err2 == nil; i++ {
+		_, err2 = coreFrostDKGRound1(frostSession(t), "1", identities[0], 2)
+	}
+	if err2 == nil || !strings.Contains(err2.Error(), "dkg sessions") {
+		t.Fatalf("Expected an error for too many dkg sessions, got %v", err2)
+	}
+	expireFrostDKGs()
+	if _, err := coreFrostDKGRound1(frostSession(t), "1", identities[0], 2); err != nil {
+		t.Fatal(err)
+	}
+	expireFrostDKGs()
+}
+
+func expireFrostNonces(share string) {
+	frostState.Lock()
+	defer frostState.Unlock()
+	for _, n := range frostState.nonces[share] {
+		n.expires = time.Now().Add(-time.Second)
+	}
+}
+
+func expireFrostDKGs() {
+	frostState.Lock()
+	defer frostState.Unlock()
+	for _, s := range frostState.dkgs {
+		s.expires = time.Now().Add(-time.Second)
+	}
+}
+
+// a coordinator relaying the daemons' calls, which can tamper with them
+type frostTamper struct {
+	frostHTTP
+	tamper func(i int, method string, args map[string]string)
+}
+
+func (t frostTamper) call(i int, method string, args map[string]string) (string, error) {
+	t.tamper(i, method, args)
+	return t.frostHTTP.call(i, method, args)
+}
+
+func TestFrostDKGAuth(t *testing.T) {
+	identities := frostIdentities(t, 3)
+	participants := frostHTTP{TestAddr, TestAddr, TestAddr}
+
+	// swapping a participant's encryption key breaks its signature
+	swapped := frostTamper{participants, func(i int, method string, args map[string]string) {
+		if method != "frost/dkg/round2" {
+			return
+		}
+		var packages []*FrostDKGPackage
+		json.Unmarshal([]byte(args["packages"]), &packages)
+		pub, _ := hex.DecodeString(identities[0])
+		packages[1].EncryptionPub = append(pub, pub[:12]...)
+		b, _ := json.Marshal(packages)
+		args["packages"] = string(b)
+	}}
+	if _, err := frostDKG(swapped, 3, 2, identities, frostSession(t), "", ""); err == nil || !strings.Contains(err.Error(), "not signed by its identity") {
+		t.Fatalf("Expected an error for a swapped encryption key, got %v", err)
+	}
+
+	// a participant colluding with the coordinator signs a different package for participant 1,
+	// which is caught when finishing
+	addr3, _ := hex.DecodeString(identities[2])
+	key3, err := GetKey(addr3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session string
+	equivocated := frostTamper{participants, func(i int, method string, args map[string]string) {
+		if method != "frost/dkg/round2" || i != 0 {
+			return
+		}
+		var packages []*FrostDKGPackage
+		json.Unmarshal([]byte(args["packages"]), &packages)
+		other, _ := crypto.NewKey(crypto.KeyType{CurveType: crypto.CurveTypeEd25519, AddrType: crypto.AddrTypeRipemd160})
+		packages[2].EncryptionPub, _ = other.Pubkey()
+		packages[2].Signature, _ = key3.Sign(packages[2].signBytes(session, 2))
+		b, _ := json.Marshal(packages)
+		args["packages"] = string(b)
+	}}
+	session = frostSession(t)
+	if _, err := frostDKG(equivocated, 3, 2, identities, session, "", ""); err == nil || !strings.Contains(err.Error(), "same round 1 packages") {
+		t.Fatalf("Expected an error for participants seeing different packages, got %v", err)
+	}
+
+	// identities must be pinned
+	writeFrostIdentities(t, nil)
+	if _, err := frostDKG(participants, 3, 2, identities, frostSession(t), "", ""); err == nil || !strings.Contains(err.Error(), "not in the trusted identities") {
+		t.Fatalf("Expected an error for untrusted identities, got %v", err)
+	}
+}
@@ -21,6 +21,7 @@ package keys
 // We really only wanted the TimeoutUnlock feature
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -36,6 +37,7 @@ type Manager struct {
 type unlocked struct {
 	*crypto.Key
 	abort chan struct{}
+	at    time.Time
 }
 
 func NewManager(keyStore crypto.KeyStore) *Manager {
@@ -60,6 +62,43 @@ func (am *Manager) GetKey(addr []byte) *crypto.Key {
 	return u.Key
 }
 
+// UnlockedAt returns the time the key was last unlocked,
+// and false if the key is not unlocked
+func (am *Manager) UnlockedAt(addr []byte) (time.Time, bool) {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	u, ok := am.unlocked[string(addr)]
+	if !ok {
+		return time.Time{}, false
+	}
+	return u.at, true
+}
+
+// NumUnlocked returns the number of currently unlocked keys
+func (am *Manager) NumUnlocked() int {
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	return len(am.unlocked)
+}
+
+// Lock drops the unlocked key from memory
+func (am *Manager) Lock(addr []byte) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	u, ok := am.unlocked[string(addr)]
+	if !ok {
+		return fmt.Errorf("key %X is not unlocked", addr)
+	}
+	if u.abort != nil {
+		close(u.abort)
+	}
+	logger.Infof("Locking %X\n", addr)
+	zeroKey(u.PrivateKey)
+	delete(am.unlocked, string(addr))
+	metricKeyEvents.inc("lock")
+	return nil
+}
+
 // Unlock unlocks the given account indefinitely.
 func (am *Manager) Unlock(addr []byte, keyAuth string) error {
 	return am.TimedUnlock(addr, keyAuth, 0)
@@ -89,12 +128,13 @@ func (am *Manager) TimedUnlock(addr []byte, keyAuth string, timeout time.Duratio
 	}
 	logger.Infof("Unlocking key %X for %v\n", addr, timeout)
 	if timeout > 0 {
-		u = &unlocked{Key: key, abort: make(chan struct{})}
+		u = &unlocked{Key: key, abort: make(chan struct{}), at: time.Now()}
 		go am.expire(addr, u, timeout)
 	} else {
-		u = &unlocked{Key: key}
+		u = &unlocked{Key: key, at: time.Now()}
 	}
 	am.unlocked[string(addr)] = u
+	metricKeyEvents.inc("unlock")
 	return nil
 }
 
@@ -115,6 +155,8 @@ func (am *Manager) expire(addr []byte, u *unlocked, timeout time.Duration) {
 		if am.unlocked[string(addr)] == u {
 			zeroKey(u.PrivateKey)
 			delete(am.unlocked, string(addr))
+			metricKeyEvents.inc("expire")
+			auditOp("expire", fmt.Sprintf("%X", addr), nil, "", nil)
 		}
 		am.mutex.Unlock()
 	}
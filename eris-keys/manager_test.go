@@ -37,14 +37,14 @@ func TestTimedUnlock(t *testing.T) {
 	AccountManager = NewManager(ks)
 	am := AccountManager
 	pass := "foo"
-	addr, err := coreKeygen(pass, keyType)
+	addr, err := coreKeygen(pass, keyType, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	addrHex := hex.EncodeToString(addr)
 
 	// Signing without passphrase fails because account is locked
-	_, err = coreSign(testSigData, addrHex)
+	_, err = coreSign(testSigData, addrHex, "")
 	if err != ErrLocked {
 		t.Fatal("Signing should've failed with ErrLocked before unlocking, got ", err)
 	}
@@ -55,14 +55,14 @@ func TestTimedUnlock(t *testing.T) {
 	}
 
 	// Signing without passphrase works because account is temp unlocked
-	_, err = coreSign(testSigData, addrHex)
+	_, err = coreSign(testSigData, addrHex, "")
 	if err != nil {
 		t.Fatal("Signing shouldn't return an error after unlocking, got ", err)
 	}
 
 	// Signing fails again after automatic locking
 	time.Sleep(150 * time.Millisecond)
-	_, err = coreSign(testSigData, addrHex)
+	_, err = coreSign(testSigData, addrHex, "")
 	if err != ErrLocked {
 		t.Fatal("Signing should've failed with ErrLocked timeout expired, got ", err)
 	}
@@ -75,7 +75,7 @@ func TestOverrideUnlock(t *testing.T) {
 	AccountManager = NewManager(ks)
 	am := AccountManager
 	pass := "foo"
-	addr, err := coreKeygen(pass, keyType)
+	addr, err := coreKeygen(pass, keyType, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -87,7 +87,7 @@ func TestOverrideUnlock(t *testing.T) {
 	}
 
 	// Signing without passphrase works because account is temp unlocked
-	_, err = coreSign(testSigData, addrHex)
+	_, err = coreSign(testSigData, addrHex, "")
 	if err != nil {
 		t.Fatal("Signing shouldn't return an error after unlocking, got ", err)
 	}
@@ -98,19 +98,50 @@ func TestOverrideUnlock(t *testing.T) {
 	}
 
 	// Signing without passphrase still works because account is temp unlocked
-	_, err = coreSign(testSigData, addrHex)
+	_, err = coreSign(testSigData, addrHex, "")
 	if err != nil {
 		t.Fatal("Signing shouldn't return an error after unlocking, got ", err)
 	}
 
 	// Signing fails again after automatic locking
 	time.Sleep(150 * time.Millisecond)
-	_, err = coreSign(testSigData, addrHex)
+	_, err = coreSign(testSigData, addrHex, "")
 	if err != ErrLocked {
 		t.Fatal("Signing should've failed with ErrLocked timeout expired, got ", err)
 	}
 }
 
+func TestLock(t *testing.T) {
+	_, ks := tmpKeyStore(t, crypto.NewKeyStorePassphrase)
+
+	AccountManager = NewManager(ks)
+	am := AccountManager
+	pass := "foo"
+	addr, err := coreKeygen(pass, keyType, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := hex.EncodeToString(addr)
+
+	if err = am.Unlock(addr, pass); err != nil {
+		t.Fatal(err)
+	}
+	if am.NumUnlocked() != 1 {
+		t.Fatalf("Expected 1 unlocked key, got %d", am.NumUnlocked())
+	}
+
+	if err := coreLock(addrHex, ""); err != nil {
+		t.Fatal(err)
+	}
+	_, err = coreSign(testSigData, addrHex, "")
+	if err != ErrLocked {
+		t.Fatal("Signing should've failed with ErrLocked after locking, got ", err)
+	}
+	if err := coreLock(addrHex, ""); err == nil {
+		t.Fatal("Expected locking a locked key to fail")
+	}
+}
+
 // This test should fail under -race if signing races the expiration goroutine.
 func TestSignRace(t *testing.T) {
 	_, ks := tmpKeyStore(t, crypto.NewKeyStorePassphrase)
@@ -119,7 +150,7 @@ func TestSignRace(t *testing.T) {
 	// Create a test account.
 	am := NewManager(ks)
 	pass := "foo"
-	addr, err := coreKeygen(pass, keyType)
+	addr, err := coreKeygen(pass, keyType, "")
 	if err != nil {
 		t.Fatal("could not create the test account", err)
 	}
@@ -129,7 +160,7 @@ func TestSignRace(t *testing.T) {
 	}
 	end := time.Now().Add(500 * time.Millisecond)
 	for time.Now().Before(end) {
-		if _, err := coreSign(testSigData, hex.EncodeToString(addr)); err == ErrLocked {
+		if _, err := coreSign(testSigData, hex.EncodeToString(addr), ""); err == ErrLocked {
 			return
 		} else if err != nil {
 			t.Errorf("Sign error: %v", err)
@@ -0,0 +1,178 @@
+package keys
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+//------------------------------------------------------------------------
+// metrics
+//
+// The daemon serves counters and histograms at /metrics
+// in the prometheus text exposition format (version 0.0.4).
+// We only need a handful of metrics so we don't pull in the client library.
+
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	metricRequests = newCounterVec("eris_keys_requests_total",
+		"Number of http requests by handler.", "handler")
+	metricRequestDuration = newHistogramVec("eris_keys_request_duration_seconds",
+		"Latency of http requests by handler.", "handler")
+	metricSignatures = newCounterVec("eris_keys_signatures_total",
+		"Number of signatures made by key type.", "key_type")
+	metricKeyEvents = newCounterVec("eris_keys_key_events_total",
+		"Number of unlock, lock and expire events.", "event")
+	metricScryptDuration = newHistogramVec("eris_keys_scrypt_duration_seconds",
+		"Time spent deriving encryption keys with scrypt.", "")
+)
+
+func init() {
+	crypto.OnScrypt = func(d time.Duration) {
+		metricScryptDuration.observe("", d.Seconds())
+	}
+}
+
+type counterVec struct {
+	name, help, label string
+
+	mtx  sync.Mutex
+	vals map[string]float64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, vals: make(map[string]float64)}
+}
+
+func (c *counterVec) inc(labelVal string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.vals[labelVal]++
+}
+
+func (c *counterVec) write(buf *bytes.Buffer) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, l := range sortedKeys(c.vals) {
+		fmt.Fprintf(buf, "%s%s %v\n", c.name, labels(c.label, l, ""), c.vals[l])
+	}
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// a histogram with an optional label
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64
+
+	mtx   sync.Mutex
+	hists map[string]*histogram
+}
+
+func newHistogramVec(name, help, label string) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: defaultBuckets, hists: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(labelVal string, v float64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	hist, ok := h.hists[labelVal]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.hists[labelVal] = hist
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.sum += v
+	hist.count++
+}
+
+func (h *histogramVec) write(buf *bytes.Buffer) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.hists))
+	for k := range h.hists {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, l := range keys {
+		hist := h.hists[l]
+		var cumulative uint64
+		for i, b := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", h.name, labels(h.label, l, fmt.Sprintf("%v", b)), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", h.name, labels(h.label, l, "+Inf"), hist.count)
+		fmt.Fprintf(buf, "%s_sum%s %v\n", h.name, labels(h.label, l, ""), hist.sum)
+		fmt.Fprintf(buf, "%s_count%s %d\n", h.name, labels(h.label, l, ""), hist.count)
+	}
+}
+
+// labels formats the label set, including the bucket bound le if given
+func labels(label, val, le string) string {
+	var ls []string
+	if label != "" {
+		ls = append(ls, fmt.Sprintf("%s=%q", label, val))
+	}
+	if le != "" {
+		ls = append(ls, fmt.Sprintf("le=%q", le))
+	}
+	if len(ls) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(ls, ",") + "}"
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// instrument counts and times every request to the handler
+func instrument(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h(w, r)
+		metricRequests.inc(name)
+		metricRequestDuration.observe(name, time.Since(start).Seconds())
+	}
+}
+
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	buf := new(bytes.Buffer)
+	metricRequests.write(buf)
+	metricRequestDuration.write(buf)
+	metricSignatures.write(buf)
+	metricKeyEvents.write(buf)
+	metricScryptDuration.write(buf)
+
+	var unlocked int
+	if AccountManager != nil {
+		unlocked = AccountManager.NumUnlocked()
+	}
+	fmt.Fprintf(buf, "# HELP eris_keys_unlocked_keys Number of currently unlocked keys.\n# TYPE eris_keys_unlocked_keys gauge\neris_keys_unlocked_keys %d\n", unlocked)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
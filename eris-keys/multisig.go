@@ -0,0 +1,307 @@
+package keys
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+//------------------------------------------------------------------------
+// multisig accounts
+//
+// A multisig account is a threshold of member pubkeys, stored at
+// <keys dir>/multisig/<ADDR>.json. It has no private key of its own.
+// Pubkeys are kept in their canonical encoding (see crypto.CanonicalPubkey),
+// so a member given once compressed and once uncompressed is still one member.
+// Its address commits to the key type, the threshold and the sorted pubkeys,
+// so every member's daemon derives the same address from the same set.
+//
+// Members sign with `sign --partial`, which collects their signatures
+// in a file along with the account and the message, eg.
+//
+//	{
+//		"multisig": {"address": "...", "type": "ed25519,ripemd160", "threshold": 2, "pubs": ["...", "...", "..."]},
+//		"msg": "...",
+//		"sigs": [{"pub": "...", "sig": "..."}]
+//	}
+//
+// The set is valid once threshold distinct members have signed the message
+
+type Multisig struct {
+	Address   string   `json:"address"`
+	Type      string   `json:"type"`
+	Threshold int      `json:"threshold"`
+	Pubs      []string `json:"pubs"`
+}
+
+type PartialSig struct {
+	Pub string `json:"pub"`
+	Sig string `json:"sig"`
+}
+
+// MultisigSigs are the signatures of a message collected for a multisig account
+type MultisigSigs struct {
+	Multisig *Multisig    `json:"multisig"`
+	Msg      string       `json:"msg"`
+	Sigs     []PartialSig `json:"sigs"`
+}
+
+// newMultisig returns the threshold of pubs account, with the pubs canonical and sorted
+func newMultisig(typ string, threshold int, pubs []string) (*Multisig, error) {
+	keyT, err := crypto.KeyTypeFromString(typ)
+	if err != nil {
+		return nil, err
+	}
+	if threshold < 1 || threshold > len(pubs) {
+		return nil, fmt.Errorf("threshold must be between 1 and the number of pubkeys (%d)", len(pubs))
+	}
+	m := &Multisig{Type: typ, Threshold: threshold}
+	for i, pub := range pubs {
+		pubB, err := hex.DecodeString(pub)
+		if err != nil {
+			return nil, fmt.Errorf("pub %d is invalid hex: %s", i, err.Error())
+		}
+		pubB, err = crypto.CanonicalPubkey(keyT.CurveType, pubB)
+		if err != nil {
+			return nil, fmt.Errorf("pub %d: %v", i, err)
+		}
+		m.Pubs = append(m.Pubs, fmt.Sprintf("%X", pubB))
+	}
+	sort.Strings(m.Pubs)
+	for i := 1; i < len(m.Pubs); i++ {
+		if m.Pubs[i] == m.Pubs[i-1] {
+			return nil, fmt.Errorf("pubkey %s is given twice", m.Pubs[i])
+		}
+	}
+	m.Address = fmt.Sprintf("%X", m.address())
+	return m, nil
+}
+
+// address is the ripemd160 of the sha256 of the type, threshold and sorted pubs
+func (m *Multisig) address() []byte {
+	var data []byte
+	data = append(data, []byte(m.Type)...)
+	data = append(data, 0)
+	var n [binary.MaxVarintLen64]byte
+	data = append(data, n[:binary.PutUvarint(n[:], uint64(m.Threshold))]...)
+	for _, pub := range m.Pubs {
+		pubB, _ := hex.DecodeString(pub)
+		data = append(data, n[:binary.PutUvarint(n[:], uint64(len(pubB)))]...)
+		data = append(data, pubB...)
+	}
+	return crypto.Ripemd160(crypto.Sha256(data))
+}
+
+// check that the account is well formed and its address matches its members
+func (m *Multisig) check() error {
+	if m == nil {
+		return fmt.Errorf("missing multisig account")
+	}
+	n, err := newMultisig(m.Type, m.Threshold, m.Pubs)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(n.Address, m.Address) {
+		return fmt.Errorf("multisig address %s does not match its members (expected %s)", m.Address, n.Address)
+	}
+	for i := range m.Pubs {
+		if m.Pubs[i] != n.Pubs[i] {
+			return fmt.Errorf("multisig %s pubkeys are not canonical and sorted", m.Address)
+		}
+	}
+	return nil
+}
+
+// member returns the canonical hex encoding of pub if it's a member
+func (m *Multisig) member(pub []byte) (string, bool) {
+	keyT, err := crypto.KeyTypeFromString(m.Type)
+	if err != nil {
+		return "", false
+	}
+	pub, err = crypto.CanonicalPubkey(keyT.CurveType, pub)
+	if err != nil {
+		return "", false
+	}
+	pubS := fmt.Sprintf("%X", pub)
+	for _, p := range m.Pubs {
+		if p == pubS {
+			return pubS, true
+		}
+	}
+	return "", false
+}
+
+// add merges the signatures of other into s. They must be for the same account and message.
+// A member's new signature replaces its old one
+func (s *MultisigSigs) add(other *MultisigSigs) error {
+	if !strings.EqualFold(s.Multisig.Address, other.Multisig.Address) {
+		return fmt.Errorf("signatures are for multisig %s, not %s", other.Multisig.Address, s.Multisig.Address)
+	}
+	if !strings.EqualFold(s.Msg, other.Msg) {
+		return fmt.Errorf("signatures are for a different message")
+	}
+	for _, o := range other.Sigs {
+		var replaced bool
+		for i, sig := range s.Sigs {
+			if strings.EqualFold(sig.Pub, o.Pub) {
+				s.Sigs[i], replaced = o, true
+				break
+			}
+		}
+		if !replaced {
+			s.Sigs = append(s.Sigs, o)
+		}
+	}
+	return nil
+}
+
+func returnMultisigDir(dir string) (string, error) {
+	dir = path.Join(dir, "multisig")
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return dir, checkMakeDataDir(dir)
+}
+
+func multisigFile(addr string) (string, error) {
+	dir, err := returnMultisigDir(KeysDir)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, strings.ToUpper(addr)+".json"), nil
+}
+
+func loadMultisig(addr string) (*Multisig, error) {
+	file, err := multisigFile(addr)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Unknown multisig %s", addr)
+		}
+		return nil, err
+	}
+	m := new(Multisig)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("invalid multisig file for %s: %v", addr, err)
+	}
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func isMultisig(addr string) bool {
+	file, err := multisigFile(addr)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(file)
+	return err == nil
+}
+
+// coreMultisigNew stores the threshold of pubs account and returns its address
+func coreMultisigNew(typ string, threshold int, pubs []string, client string) (addr string, err error) {
+	defer func() {
+		err = auditOp("multisig", addr, nil, client, err)
+	}()
+
+	m, err := newMultisig(typ, threshold, pubs)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	file, err := multisigFile(m.Address)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		return "", err
+	}
+	return m.Address, nil
+}
+
+// coreMultisigSign signs the hash with the key at addr, which must be a member of the multisig
+func coreMultisigSign(hash, addr, multisigAddr, client string) (*MultisigSigs, error) {
+	m, err := loadMultisig(multisigAddr)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := corePub(addr)
+	if err != nil {
+		return nil, err
+	}
+	pubS, ok := m.member(pub)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not a member of multisig %s", addr, m.Address)
+	}
+	sig, err := coreSign(hash, addr, client)
+	if err != nil {
+		return nil, err
+	}
+	return &MultisigSigs{
+		Multisig: m,
+		Msg:      strings.ToUpper(hash),
+		Sigs:     []PartialSig{{Pub: pubS, Sig: fmt.Sprintf("%X", sig)}},
+	}, nil
+}
+
+// coreMultisigVerify returns true if at least threshold distinct members of the stored
+// multisig account at multisigAddr validly signed the message.
+// The account in the signature set is only checked to be the same one, never trusted
+func coreMultisigVerify(sigs *MultisigSigs, multisigAddr string) (bool, error) {
+	if multisigAddr == "" {
+		return false, fmt.Errorf("must provide the multisig's address or name")
+	}
+	m, err := loadMultisig(multisigAddr)
+	if err != nil {
+		return false, err
+	}
+	if sigs.Multisig != nil && !strings.EqualFold(sigs.Multisig.Address, m.Address) {
+		return false, fmt.Errorf("signatures are for multisig %s, not %s", sigs.Multisig.Address, m.Address)
+	}
+	keyT, _ := crypto.KeyTypeFromString(m.Type)
+	hashB, err := hex.DecodeString(sigs.Msg)
+	if err != nil {
+		return false, fmt.Errorf("msg is invalid hex: %s", err.Error())
+	}
+
+	signed := make(map[string]bool)
+	for i, s := range sigs.Sigs {
+		pubB, err := hex.DecodeString(s.Pub)
+		if err != nil {
+			return false, fmt.Errorf("pub %d is invalid hex: %s", i, err.Error())
+		}
+		pub, ok := m.member(pubB)
+		if !ok {
+			return false, fmt.Errorf("signature %d is by %s, who is not a member", i, s.Pub)
+		}
+		if signed[pub] {
+			return false, fmt.Errorf("signature %d is by %s, who already signed", i, s.Pub)
+		}
+		pubB, _ = hex.DecodeString(pub)
+		sigB, err := hex.DecodeString(s.Sig)
+		if err != nil {
+			return false, fmt.Errorf("sig %d is invalid hex: %s", i, err.Error())
+		}
+		if ok, _ := crypto.Verify(keyT.CurveType, hashB, sigB, pubB); ok {
+			signed[pub] = true
+		}
+	}
+	return len(signed) >= m.Threshold, nil
+}
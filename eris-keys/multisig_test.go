@@ -0,0 +1,160 @@
+package keys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+func TestMultisig(t *testing.T) {
+	var addrs, pubs []string
+	for i := 0; i < 4; i++ {
+		addr, err := coreKeygen(AUTH, keyType, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := corePub(toHex(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs, pubs = append(addrs, toHex(addr)), append(pubs, toHex(pub))
+	}
+	// the last key is not a member
+	members := pubs[:3]
+
+	multisig, err := coreMultisigNew(keyType, 2, members, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMultisig(keyType, 2, []string{members[2], members[0], strings.ToLower(members[1])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Address != multisig {
+		t.Fatalf("Address depends on the order of the pubkeys: %s != %s", m.Address, multisig)
+	}
+	if other, _ := newMultisig(keyType, 3, members); other.Address == multisig {
+		t.Fatal("Address doesn't depend on the threshold")
+	}
+	if _, err := newMultisig(keyType, 4, members); err == nil {
+		t.Fatal("Expected an error for a threshold above the number of members")
+	}
+	if _, err := newMultisig(keyType, 1, []string{members[0], members[0]}); err == nil {
+		t.Fatal("Expected an error for a repeated member")
+	}
+	if err := coreNameAdd("officers", multisig); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := toHex(crypto.Sha3([]byte("pay the bills")))
+	if _, err := coreMultisigSign(hash, addrs[3], multisig, ""); err == nil {
+		t.Fatal("Expected an error signing with a key that's not a member")
+	}
+
+	sigs, err := coreMultisigSign(hash, addrs[0], multisig, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := coreMultisigVerify(sigs, multisig); err != nil || res {
+		t.Fatalf("Expected one of two signatures to fail, got %v %v", res, err)
+	}
+	sig2, err := coreMultisigSign(hash, addrs[2], multisig, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sigs.add(sig2); err != nil {
+		t.Fatal(err)
+	}
+	// signing again replaces the old signature
+	if err := sigs.add(sig2); err != nil || len(sigs.Sigs) != 2 {
+		t.Fatalf("Expected two signatures, got %d (%v)", len(sigs.Sigs), err)
+	}
+	if res, err := coreMultisigVerify(sigs, multisig); err != nil || !res {
+		t.Fatalf("Expected two of two signatures to verify, got %v %v", res, err)
+	}
+	if _, err := coreMultisigVerify(sigs, addrs[0]); err == nil {
+		t.Fatal("Expected an error verifying against the wrong multisig")
+	}
+
+	other, err := coreMultisigSign(toHex(crypto.Sha3([]byte("pay me"))), addrs[1], multisig, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sigs.add(other); err == nil {
+		t.Fatal("Expected an error adding a signature of another message")
+	}
+
+	// a signature of another message doesn't count
+	forged := *sigs
+	forged.Sigs = []PartialSig{sigs.Sigs[0], {Pub: other.Sigs[0].Pub, Sig: other.Sigs[0].Sig}}
+	if res, err := coreMultisigVerify(&forged, multisig); err != nil || res {
+		t.Fatalf("Expected a signature of another message not to count, got %v %v", res, err)
+	}
+
+	// nor does a multisig with a lower threshold but the same address
+	lowered := *sigs.Multisig
+	lowered.Threshold = 1
+	forged = MultisigSigs{Multisig: &lowered, Msg: sigs.Msg, Sigs: sigs.Sigs[:1]}
+	if res, err := coreMultisigVerify(&forged, multisig); err != nil || res {
+		t.Fatalf("Expected the stored multisig's threshold to be used, got %v %v", res, err)
+	}
+
+	// the multisig is always the stored one
+	if _, err := coreMultisigVerify(sigs, ""); err == nil {
+		t.Fatal("Expected an error verifying without the multisig's address")
+	}
+	unknown, _ := newMultisig(keyType, 1, members[:1])
+	forged = MultisigSigs{Multisig: unknown, Msg: sigs.Msg, Sigs: sigs.Sigs[:1]}
+	if _, err := coreMultisigVerify(&forged, unknown.Address); err == nil {
+		t.Fatal("Expected an error verifying against a multisig that isn't stored")
+	}
+}
+
+func TestMultisigCanonical(t *testing.T) {
+	typ := "secp256k1,sha3"
+	var addrs, pubs, compressed []string
+	for i := 0; i < 2; i++ {
+		addr, err := coreKeygen(AUTH, typ, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := corePub(toHex(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := crypto.CanonicalPubkey(crypto.CurveTypeSecp256k1, pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pub) != 65 || len(c) != 33 {
+			t.Fatalf("Expected a 65 byte pubkey to be compressed, got %d and %d bytes", len(pub), len(c))
+		}
+		addrs, pubs, compressed = append(addrs, toHex(addr)), append(pubs, toHex(pub)), append(compressed, toHex(c))
+	}
+
+	multisig, err := coreMultisigNew(typ, 2, pubs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := newMultisig(typ, 2, []string{compressed[1], pubs[0]}); err != nil || m.Address != multisig {
+		t.Fatalf("Address depends on the encoding of the pubkeys: %v", err)
+	}
+	if _, err := newMultisig(typ, 1, []string{pubs[0], compressed[0]}); err == nil {
+		t.Fatal("Expected an error for a member given compressed and uncompressed")
+	}
+	if _, err := newMultisig(typ, 1, []string{pubs[0][:len(pubs[0])-2] + "00"}); err == nil {
+		t.Fatal("Expected an error for a pubkey that's not on the curve")
+	}
+
+	hash := toHex(crypto.Sha3([]byte("pay the bills")))
+	sigs, err := coreMultisigSign(hash, addrs[0], multisig, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same member's signature in another encoding doesn't count twice
+	sigs.Sigs = append(sigs.Sigs, PartialSig{Pub: pubs[0], Sig: sigs.Sigs[0].Sig})
+	if _, err := coreMultisigVerify(sigs, multisig); err == nil || !strings.Contains(err.Error(), "already signed") {
+		t.Fatalf("Expected an error for a member signing twice, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package keys
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------
+// signing policies
+//
+// A key may have a policy file at <keys dir>/policies/<ADDR>.json.
+// If it exists, coreSign checks every request against it before signing.
+// Policy files are only read from disk and are never written by the daemon,
+// so a client allowed to sign cannot loosen its own policy.
+//
+// Example:
+//
+//	{
+//		"max_sigs_per_minute": 10,
+//		"hash_lengths": [32],
+//		"client_addrs": ["127.0.0.1", "10.0.0.0/8"],
+//		"time_windows": ["09:00-17:00"],
+//		"fresh_unlock": 5
+//	}
+
+type ErrPolicyViolation string
+
+func (e ErrPolicyViolation) Error() string {
+	return "policy violation: " + string(e)
+}
+
+type SigningPolicy struct {
+	// maximum number of signatures in any sliding minute. 0 for no limit
+	MaxSigsPerMinute int `json:"max_sigs_per_minute"`
+
+	// allowed lengths in bytes of the message to sign
+	HashLengths []int `json:"hash_lengths"`
+
+	// IPs or CIDR ranges allowed to request signatures
+	ClientAddrs []string `json:"client_addrs"`
+
+	// time-of-day windows (daemon local time) in which signing is allowed,
+	// formatted as "HH:MM-HH:MM". A window may wrap around midnight
+	TimeWindows []string `json:"time_windows"`
+
+	// if non zero, the key must be encrypted and have been
+	// unlocked within this many minutes
+	FreshUnlock int `json:"fresh_unlock"`
+}
+
+func returnPoliciesDir(dir string) (string, error) {
+	dir = path.Join(dir, "policies")
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return dir, checkMakeDataDir(dir)
+}
+
+// loadPolicy returns the policy for the address, or nil if it has none.
+// It's called for every signature, so it doesn't make the policies dir
+func loadPolicy(addr string) (*SigningPolicy, error) {
+	file, err := filepath.Abs(path.Join(KeysDir, "policies", strings.ToUpper(addr)+".json"))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	p := new(SigningPolicy)
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("invalid policy file for %s: %v", addr, err)
+	}
+	return p, nil
+}
+
+// checkRequest enforces everything that can be checked before the key is loaded
+func (p *SigningPolicy) checkRequest(hash []byte, client string, now time.Time) error {
+	if len(p.HashLengths) > 0 {
+		var ok bool
+		for _, l := range p.HashLengths {
+			if l == len(hash) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return ErrPolicyViolation(fmt.Sprintf("message length %d not allowed", len(hash)))
+		}
+	}
+
+	if len(p.ClientAddrs) > 0 {
+		ok, err := clientAllowed(p.ClientAddrs, client)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrPolicyViolation(fmt.Sprintf("client %q not allowed", client))
+		}
+	}
+
+	if len(p.TimeWindows) > 0 {
+		ok, err := inTimeWindows(p.TimeWindows, now)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrPolicyViolation(fmt.Sprintf("signing not allowed at %s", now.Format("15:04")))
+		}
+	}
+	return nil
+}
+
+// checkUnlock enforces the fresh unlock requirement
+func (p *SigningPolicy) checkUnlock(addr []byte, now time.Time) error {
+	if p.FreshUnlock == 0 {
+		return nil
+	}
+	at, ok := AccountManager.UnlockedAt(addr)
+	if !ok {
+		return ErrPolicyViolation("key must be unlocked to sign")
+	}
+	if now.Sub(at) > time.Duration(p.FreshUnlock)*time.Minute {
+		return ErrPolicyViolation(fmt.Sprintf("key was unlocked more than %d minutes ago", p.FreshUnlock))
+	}
+	return nil
+}
+
+func clientAllowed(allowed []string, client string) (bool, error) {
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	ip := net.ParseIP(client)
+	if ip == nil {
+		return false, nil
+	}
+	for _, a := range allowed {
+		if strings.Contains(a, "/") {
+			_, ipNet, err := net.ParseCIDR(a)
+			if err != nil {
+				return false, fmt.Errorf("invalid client range in policy %s: %v", a, err)
+			}
+			if ipNet.Contains(ip) {
+				return true, nil
+			}
+		} else if allowedIP := net.ParseIP(a); allowedIP != nil && allowedIP.Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func inTimeWindows(windows []string, now time.Time) (bool, error) {
+	minute := now.Hour()*60 + now.Minute()
+	for _, w := range windows {
+		spl := strings.Split(w, "-")
+		if len(spl) != 2 {
+			return false, fmt.Errorf("invalid time window in policy %s", w)
+		}
+		start, err := minuteOfDay(spl[0])
+		if err != nil {
+			return false, err
+		}
+		end, err := minuteOfDay(spl[1])
+		if err != nil {
+			return false, err
+		}
+		if start <= end {
+			if minute >= start && minute < end {
+				return true, nil
+			}
+		} else if minute >= start || minute < end {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func minuteOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time in policy %s: %v", s, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+//------------------------------------------------------------------------
+// rate limiting
+
+var sigRates = &sigRateLimiter{sigs: make(map[string][]time.Time)}
+
+// sigRateLimiter remembers when each key signed over the last minute
+type sigRateLimiter struct {
+	mtx  sync.Mutex
+	sigs map[string][]time.Time
+}
+
+// allow returns an error if the address has reached the limit. It doesn't count
+// a signature, which is only done with record once one has been made
+func (l *sigRateLimiter) allow(addr string, limit int, now time.Time) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	recent := l.recent(addr, now)
+	if len(recent) >= limit {
+		return ErrPolicyViolation(fmt.Sprintf("more than %d signatures per minute", limit))
+	}
+	return nil
+}
+
+// record counts a signature made by the address
+func (l *sigRateLimiter) record(addr string, now time.Time) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.sigs[addr] = append(l.recent(addr, now), now)
+}
+
+// recent drops the address's signatures older than a minute and returns the rest
+func (l *sigRateLimiter) recent(addr string, now time.Time) []time.Time {
+	recent := l.sigs[addr][:0]
+	for _, t := range l.sigs[addr] {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	l.sigs[addr] = recent
+	return recent
+}
@@ -0,0 +1,139 @@
+package keys
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+func writeTestPolicy(t *testing.T, addr, policy string) {
+	dir, err := returnPoliciesDir(KeysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, addr+".json"), []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignPolicy(t *testing.T) {
+	addr, err := coreKeygen(AUTH, keyType, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := toHex(addr)
+	hash := toHex(crypto.Sha3([]byte("the hash of something!")))
+
+	writeTestPolicy(t, addrHex, `{"max_sigs_per_minute": 2, "hash_lengths": [32], "client_addrs": ["127.0.0.1", "10.0.0.0/8"]}`)
+
+	if _, err := coreSign(testSigData, addrHex, "127.0.0.1:5555"); err == nil {
+		t.Fatal("Expected policy violation for wrong hash length")
+	} else if _, ok := err.(ErrPolicyViolation); !ok {
+		t.Fatalf("Expected ErrPolicyViolation, got %v", err)
+	}
+
+	if _, err := coreSign(hash, addrHex, "192.168.1.1:5555"); err == nil {
+		t.Fatal("Expected policy violation for disallowed client")
+	}
+
+	if _, err := coreSign(hash, addrHex, "127.0.0.1:5555"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSign(hash, addrHex, "10.1.2.3:5555"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSign(hash, addrHex, "127.0.0.1:5555"); err == nil {
+		t.Fatal("Expected policy violation for exceeding rate limit")
+	}
+}
+
+func TestSignPolicyRateFailedSigs(t *testing.T) {
+	addr, err := coreKeygen(AUTH, keyType, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := toHex(addr)
+	hash := toHex(crypto.Sha3([]byte("the hash of something!")))
+	writeTestPolicy(t, addrHex, `{"max_sigs_per_minute": 2}`)
+
+	// signatures that fail don't count
+	for i := 0; i < 3; i++ {
+		if _, err := coreSignScheme(hash, addrHex, "nonsense", ""); err == nil {
+			t.Fatal("Expected an error for an unknown signature scheme")
+		} else if _, ok := err.(ErrPolicyViolation); ok {
+			t.Fatalf("Failed signature counted against the rate limit: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := coreSign(hash, addrHex, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := coreSign(hash, addrHex, ""); err == nil {
+		t.Fatal("Expected policy violation for exceeding rate limit")
+	}
+}
+
+func TestLoadPolicyNoDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eris-keys-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keysDir := KeysDir
+	KeysDir = dir
+	defer func() { KeysDir = keysDir }()
+
+	if p, err := loadPolicy("AB"); err != nil || p != nil {
+		t.Fatalf("Expected no policy, got %v %v", p, err)
+	}
+	if _, err := os.Stat(path.Join(dir, "policies")); !os.IsNotExist(err) {
+		t.Fatalf("Expected loading a policy not to make the policies dir, got %v", err)
+	}
+}
+
+func TestSignPolicyFreshUnlock(t *testing.T) {
+	addr, err := coreKeygen(AUTH, keyType, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := toHex(addr)
+
+	// the key is not encrypted so can never be freshly unlocked
+	writeTestPolicy(t, addrHex, `{"fresh_unlock": 5}`)
+	if _, err := coreSign(testSigData, addrHex, ""); err == nil {
+		t.Fatal("Expected policy violation for key that was never unlocked")
+	}
+}
+
+func TestPolicyTimeWindows(t *testing.T) {
+	at := func(h, m int) time.Time {
+		return time.Date(2015, 1, 1, h, m, 0, 0, time.Local)
+	}
+	cases := []struct {
+		windows []string
+		now     time.Time
+		ok      bool
+	}{
+		{[]string{"09:00-17:00"}, at(12, 0), true},
+		{[]string{"09:00-17:00"}, at(17, 0), false},
+		{[]string{"09:00-17:00"}, at(8, 59), false},
+		{[]string{"22:00-06:00"}, at(23, 30), true},
+		{[]string{"22:00-06:00"}, at(5, 0), true},
+		{[]string{"22:00-06:00"}, at(12, 0), false},
+		{[]string{"01:00-02:00", "09:00-10:00"}, at(9, 15), true},
+	}
+	for _, c := range cases {
+		ok, err := inTimeWindows(c.windows, c.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.ok {
+			t.Fatalf("Time window %v at %s: got %v, expected %v", c.windows, c.now.Format("15:04"), ok, c.ok)
+		}
+	}
+}
@@ -6,8 +6,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"runtime"
+	"strconv"
 	"strings"
 
+	"github.com/eris-ltd/eris-keys/crypto"
+	"github.com/eris-ltd/eris-keys/crypto/ed25519"
+
 	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/rs/cors"
 )
 
@@ -24,24 +29,92 @@ func StartServer(host, port string) error {
 	AccountManager = NewManager(ks)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/gen", genHandler)
-	mux.HandleFunc("/pub", pubHandler)
-	mux.HandleFunc("/sign", signHandler)
-	mux.HandleFunc("/verify", verifyHandler)
-	mux.HandleFunc("/hash", hashHandler)
-	mux.HandleFunc("/import", importHandler)
-	mux.HandleFunc("/name", nameHandler)
-	mux.HandleFunc("/name/ls", nameLsHandler)
-	mux.HandleFunc("/name/rm", nameRmHandler)
-	mux.HandleFunc("/unlock", unlockHandler)
-	mux.HandleFunc("/lock", lockHandler)
-	mux.HandleFunc("/mint", convertMintHandler)
+	mux.HandleFunc("/gen", instrument("gen", genHandler))
+	mux.HandleFunc("/gen/progress", instrument("gen/progress", genProgressHandler))
+	mux.HandleFunc("/pub", instrument("pub", pubHandler))
+	mux.HandleFunc("/sign", instrument("sign", signHandler))
+	mux.HandleFunc("/sign/validator", instrument("sign/validator", signValidatorHandler))
+	mux.HandleFunc("/verify", instrument("verify", verifyHandler))
+	mux.HandleFunc("/sign/eth", instrument("sign/eth", signEthHandler))
+	mux.HandleFunc("/sign/tx", instrument("sign/tx", signTxHandler))
+	mux.HandleFunc("/sign/batch", instrument("sign/batch", signBatchHandler))
+	mux.HandleFunc("/verify/eth", instrument("verify/eth", verifyEthHandler))
+	mux.HandleFunc("/verify/batch", instrument("verify/batch", verifyBatchHandler))
+	mux.HandleFunc("/recover", instrument("recover", recoverHandler))
+	mux.HandleFunc("/pop", instrument("pop", popHandler))
+	mux.HandleFunc("/verify/pop", instrument("verify/pop", verifyPopHandler))
+	mux.HandleFunc("/aggregate", instrument("aggregate", aggregateHandler))
+	mux.HandleFunc("/verify/aggregate", instrument("verify/aggregate", verifyAggregateHandler))
+	mux.HandleFunc("/multisig/new", instrument("multisig/new", multisigNewHandler))
+	mux.HandleFunc("/multisig/sign", instrument("multisig/sign", multisigSignHandler))
+	mux.HandleFunc("/multisig/verify", instrument("multisig/verify", multisigVerifyHandler))
+	mux.HandleFunc("/frost/dkg/round1", instrument("frost/dkg/round1", frostDKGRound1Handler))
+	mux.HandleFunc("/frost/dkg/round2", instrument("frost/dkg/round2", frostDKGRound2Handler))
+	mux.HandleFunc("/frost/dkg/finish", instrument("frost/dkg/finish", frostDKGFinishHandler))
+	mux.HandleFunc("/frost/group", instrument("frost/group", frostGroupHandler))
+	mux.HandleFunc("/frost/commit", instrument("frost/commit", frostCommitHandler))
+	mux.HandleFunc("/frost/sign", instrument("frost/sign", frostSignHandler))
+	mux.HandleFunc("/hash", instrument("hash", hashHandler))
+	mux.HandleFunc("/ecdh", instrument("ecdh", ecdhHandler))
+	mux.HandleFunc("/encrypt", instrument("encrypt", encryptHandler))
+	mux.HandleFunc("/decrypt", instrument("decrypt", decryptHandler))
+	mux.HandleFunc("/import", instrument("import", importHandler))
+	mux.HandleFunc("/name", instrument("name", nameHandler))
+	mux.HandleFunc("/name/ls", instrument("name/ls", nameLsHandler))
+	mux.HandleFunc("/name/rm", instrument("name/rm", nameRmHandler))
+	mux.HandleFunc("/unlock", instrument("unlock", unlockHandler))
+	mux.HandleFunc("/lock", instrument("lock", lockHandler))
+	mux.HandleFunc("/mint", instrument("mint", convertMintHandler))
+	mux.HandleFunc("/audit/verify", instrument("audit/verify", auditVerifyHandler))
+	mux.HandleFunc("/audit/query", instrument("audit/query", auditQueryHandler))
+
+	mux.HandleFunc("/metrics", metricsHandler)
 
 	logger.Infof("Starting eris-keys server on %s:%s\n", host, port)
-	c := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"}, // TODO: dev
+	return http.ListenAndServe(host+":"+port, corsHandler(mux))
+}
+
+//------------------------------------------------------------------------
+// cross-origin policy
+
+// CORS options for the daemon. They are empty by default,
+// meaning no cross-origin access is allowed
+var (
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+)
+
+// corsHandler wraps the mux with the cors policy.
+// Browsers send an Origin header with every cross-origin request,
+// and will happily send simple POSTs without a preflight,
+// so we refuse any request from an origin not in the allow-list
+// before it reaches the handlers
+func corsHandler(h http.Handler) http.Handler {
+	if len(CORSAllowedOrigins) > 0 {
+		h = cors.New(cors.Options{
+			AllowedOrigins: CORSAllowedOrigins,
+			AllowedMethods: CORSAllowedMethods,
+			AllowedHeaders: CORSAllowedHeaders,
+		}).Handler(h)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !isAllowedOrigin(origin) {
+			logger.Infof("Refusing request to %s from origin %s\n", r.URL.Path, origin)
+			http.Error(w, fmt.Sprintf("origin %s not allowed", origin), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
 	})
-	return http.ListenAndServe(host+":"+port, c.Handler(mux))
+}
+
+func isAllowedOrigin(origin string) bool {
+	for _, o := range CORSAllowedOrigins {
+		if o == "*" || strings.ToLower(o) == strings.ToLower(origin) {
+			return true
+		}
+	}
+	return false
 }
 
 // A request is just a map of args to be json marshalled
@@ -75,12 +148,47 @@ func genHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := args["name"]
-	addr, err := coreKeygen(auth, typ)
+	name, validator := args["name"], args["validator"] == "true"
+	if validator {
+		if err := checkValidatorType(typ); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	var addr []byte
+	if source := args["entropy"]; source != "" {
+		if args["vanity"] != "" {
+			WriteError(w, fmt.Errorf("an entropy source can't be used with a vanity prefix"))
+			return
+		}
+		addr, err = coreKeygenEntropy(auth, typ, source, r.RemoteAddr)
+	} else if prefix := args["vanity"]; prefix != "" {
+		// the search can keep every cpu busy for hours, so it never gets more
+		// than the cpus, and stops if the client goes away
+		workers := runtime.NumCPU()
+		if n := args["workers"]; n != "" {
+			if workers, err = strconv.Atoi(n); err != nil || workers < 1 {
+				WriteError(w, fmt.Errorf("must provide a positive number of workers with the `workers` key"))
+				return
+			}
+			if workers > runtime.NumCPU() {
+				workers = runtime.NumCPU()
+			}
+		}
+		addr, err = coreKeygenVanity(r.Context(), auth, typ, prefix, args["search"], workers, r.RemoteAddr)
+	} else {
+		addr, err = coreKeygen(auth, typ, r.RemoteAddr)
+	}
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
+	if validator {
+		if err := coreMarkValidator(fmt.Sprintf("%X", addr), r.RemoteAddr); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
 	if name != "" {
 		err := coreNameAdd(name, strings.ToUpper(hex.EncodeToString(addr)))
 		if err != nil {
@@ -91,6 +199,25 @@ func genHandler(w http.ResponseWriter, r *http.Request) {
 	WriteResult(w, fmt.Sprintf("%X", addr))
 }
 
+func genProgressHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	search := args["search"]
+	if search == "" {
+		WriteError(w, fmt.Errorf("must provide the vanity search id with the `search` key"))
+		return
+	}
+	progress, err := coreVanityProgress(search)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSONResult(w, progress)
+}
+
 func unlockHandler(w http.ResponseWriter, r *http.Request) {
 	_, auth, args, err := typeAuthArgs(r)
 	if err != nil {
@@ -103,7 +230,7 @@ func unlockHandler(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, err)
 		return
 	}
-	if err := coreUnlock(auth, addr, timeout); err != nil {
+	if err := coreUnlock(auth, addr, timeout, r.RemoteAddr); err != nil {
 		WriteError(w, err)
 		return
 	}
@@ -122,7 +249,7 @@ func convertMintHandler(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, err)
 		return
 	}
-	key, err := coreConvert(addr)
+	key, err := coreConvert(addr, r.RemoteAddr)
 	if err != nil {
 		WriteError(w, err)
 		return
@@ -131,10 +258,6 @@ func convertMintHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func lockHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO
-}
-
-func pubHandler(w http.ResponseWriter, r *http.Request) {
 	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
@@ -146,15 +269,14 @@ func pubHandler(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, err)
 		return
 	}
-	pub, err := corePub(addr)
-	if err != nil {
+	if err := coreLock(addr, r.RemoteAddr); err != nil {
 		WriteError(w, err)
 		return
 	}
-	WriteResult(w, fmt.Sprintf("%X", pub))
+	WriteResult(w, fmt.Sprintf("%s locked", addr))
 }
 
-func signHandler(w http.ResponseWriter, r *http.Request) {
+func ecdhHandler(w http.ResponseWriter, r *http.Request) {
 	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
@@ -166,164 +288,914 @@ func signHandler(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, err)
 		return
 	}
-	msg := args["msg"]
-	if msg == "" {
-		WriteError(w, fmt.Errorf("must provide a message to sign with the `msg` key"))
+	peer := args["pub"]
+	if peer == "" {
+		WriteError(w, fmt.Errorf("must provide the peer's pubkey with the `pub` key"))
 		return
 	}
-	sig, err := coreSign(msg, addr)
+	secret, err := coreECDH(addr, peer, args["salt"], args["info"], r.RemoteAddr)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	WriteResult(w, fmt.Sprintf("%X", sig))
+	WriteResult(w, fmt.Sprintf("%X", secret))
 }
 
-func verifyHandler(w http.ResponseWriter, r *http.Request) {
-	typ, _, args, err := typeAuthArgs(r)
+func encryptHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	pub, msg, sig := args["pub"], args["msg"], args["sig"]
+	pub, msg := args["pub"], args["msg"]
 	if pub == "" {
-		WriteError(w, fmt.Errorf("must provide a pubkey with the `pub` key"))
+		WriteError(w, fmt.Errorf("must provide the recipient's pubkey with the `pub` key"))
 		return
 	}
-	if msg == "" {
-		WriteError(w, fmt.Errorf("must provide a message msg with the `msg` key"))
+	// not the default key type: a pubkey's length doesn't say which curve it's on
+	curve := args["type"]
+	if curve == "" {
+		WriteError(w, fmt.Errorf("must provide the recipient's curve with the `type` key"))
 		return
 	}
-	if sig == "" {
-		WriteError(w, fmt.Errorf("must provide a signature with the `sig` key"))
+	envelope, err := coreEncrypt(curve, pub, msg)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
+	WriteResult(w, string(envelope))
+}
 
-	res, err := coreVerify(typ, pub, msg, sig)
+func decryptHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	WriteResult(w, fmt.Sprintf("%v", res))
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	envelope := args["msg"]
+	if envelope == "" {
+		WriteError(w, fmt.Errorf("must provide the envelope to decrypt with the `msg` key"))
+		return
+	}
+	plainText, err := coreDecrypt(addr, envelope, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%X", plainText))
 }
 
-func hashHandler(w http.ResponseWriter, r *http.Request) {
-	typ, _, args, err := typeAuthArgs(r)
+func pubHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	msg := args["msg"]
-	hexD := args["hex"]
-
-	hash, err := coreHash(typ, msg, hexD == "true")
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	WriteResult(w, fmt.Sprintf("%X", hash))
+	pub, err := corePub(addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if args["scheme"] == crypto.SigSchemeSchnorr {
+		if pub, err = crypto.XOnlyPubkey(pub); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	WriteResult(w, fmt.Sprintf("%X", pub))
 }
 
-func importHandler(w http.ResponseWriter, r *http.Request) {
-	typ, auth, args, err := typeAuthArgs(r)
+func signHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	name, key := args["data"], args["key"]
-
-	addr, err := coreImport(auth, typ, key)
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-
-	if name != "" {
-		if err := coreNameAdd(name, strings.ToUpper(hex.EncodeToString(addr))); err != nil {
+	msg := args["msg"]
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide a message to sign with the `msg` key"))
+		return
+	}
+	// optionally hash the (hex encoded) message first
+	if h := args["hash"]; h != "" && h != "none" {
+		digest, err := coreHash(h, msg, true)
+		if err != nil {
 			WriteError(w, err)
 			return
 		}
+		msg = hex.EncodeToString(digest)
 	}
-	WriteResult(w, fmt.Sprintf("%X", addr))
+	scheme, format := args["scheme"], args["format"]
+	if scheme == crypto.SigSchemeSchnorr && format != "" {
+		WriteError(w, fmt.Errorf("signature formats are only for ecdsa signatures"))
+		return
+	}
+	if err := checkFormatKey(addr, format); err != nil {
+		WriteError(w, err)
+		return
+	}
+	sig, err := coreSignScheme(msg, addr, scheme, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	sig, err = formatSig(sig, format)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%X", sig))
 }
 
-func nameHandler(w http.ResponseWriter, r *http.Request) {
+func signBatchHandler(w http.ResponseWriter, r *http.Request) {
 	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	name, addr := args["name"], args["addr"]
-
-	logger.Debugf("name handler. name (%s). addr (%s)\n", name, addr)
-
-	if name == "" {
-		WriteError(w, fmt.Errorf("please specify a name"))
+	msgs := splitList(args["msgs"])
+	if len(msgs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated messages to sign with the `msgs` key"))
 		return
 	}
-
-	if addr == "" {
-		addr, err := coreNameGet(name)
+	// one key for every message, or a key per message
+	addrs := splitList(args["addrs"])
+	if len(addrs) == 0 {
+		addr, err := getNameAddr(args["name"], args["addr"])
 		if err != nil {
 			WriteError(w, err)
 			return
 		}
-		WriteResult(w, addr)
-	} else {
-		if err := coreNameAdd(name, strings.ToUpper(addr)); err != nil {
+		addrs = []string{addr}
+	}
+	for i, addr := range addrs {
+		addrs[i] = strings.ToUpper(addr)
+		if err := checkFormatKey(addrs[i], args["format"]); err != nil {
 			WriteError(w, err)
 			return
 		}
-		WriteResult(w, fmt.Sprintf("Added name (%s) to address (%s)", name, addr))
 	}
+	if h := args["hash"]; h != "" && h != "none" {
+		for i, msg := range msgs {
+			digest, err := coreHash(h, msg, true)
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+			msgs[i] = hex.EncodeToString(digest)
+		}
+	}
+
+	sigs, err := coreSignBatch(msgs, addrs, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	sigsHex := make([]string, len(sigs))
+	for i, sig := range sigs {
+		if sig, err = formatSig(sig, args["format"]); err != nil {
+			WriteError(w, err)
+			return
+		}
+		sigsHex[i] = fmt.Sprintf("%X", sig)
+	}
+	b, err := json.Marshal(sigsHex)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
 }
 
-func nameLsHandler(w http.ResponseWriter, r *http.Request) {
+func signValidatorHandler(w http.ResponseWriter, r *http.Request) {
 	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	name, addr := args["name"], args["addr"]
-
-	logger.Debugf("name ls handler. name (%s). addr (%s)\n", name, addr)
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	msg := args["msg"]
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide the hex encoded sign bytes with the `msg` key"))
+		return
+	}
+	sig, err := coreSignValidator(msg, addr, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%X", sig))
+}
 
-	names, err := coreNameList()
+func signEthHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	mode, msg := args["mode"], args["msg"]
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide a message to sign with the `msg` key"))
+		return
+	}
+	sig, err := coreSignEth(mode, msg, addr, r.RemoteAddr)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
+	WriteResult(w, fmt.Sprintf("%X", sig))
+}
 
-	b, err := json.Marshal(names)
+func signTxHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	tx := args["tx"]
+	if tx == "" {
+		WriteError(w, fmt.Errorf("must provide a json transaction with the `tx` key"))
+		return
+	}
+	// a chain id means a tendermint transaction
+	if chainID := args["chain_id"]; chainID != "" {
+		signed, err := coreSignTendermintTx(chainID, tx, addr, r.RemoteAddr)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		WriteResult(w, string(signed))
+		return
+	}
+	signed, err := coreSignEthTx(tx, addr, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	b, err := json.Marshal(signed)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
 	WriteResult(w, string(b))
-	return
 }
 
-func nameRmHandler(w http.ResponseWriter, r *http.Request) {
+func verifyEthHandler(w http.ResponseWriter, r *http.Request) {
 	_, _, args, err := typeAuthArgs(r)
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
-	name, addr := args["name"], args["addr"]
-
-	logger.Debugf("name rm handler. name (%s). addr (%s)\n", name, addr)
-
-	if name == "" {
-		WriteError(w, fmt.Errorf("please specify a name"))
+	mode, pub, msg, sig := args["mode"], args["pub"], args["msg"], args["sig"]
+	if pub == "" {
+		WriteError(w, fmt.Errorf("must provide a pubkey with the `pub` key"))
 		return
 	}
-
-	if err := coreNameRm(name); err != nil {
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide a message msg with the `msg` key"))
+		return
+	}
+	if sig == "" {
+		WriteError(w, fmt.Errorf("must provide a signature with the `sig` key"))
+		return
+	}
+	res, err := coreVerifyEth(mode, pub, msg, sig)
+	if err != nil {
 		WriteError(w, err)
 		return
 	}
+	WriteResult(w, fmt.Sprintf("%v", res))
+}
 
-	WriteResult(w, fmt.Sprintf("Removed name (%s)", name))
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	typ, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	pub, msg, sig := args["pub"], args["msg"], args["sig"]
+	if pub == "" {
+		WriteError(w, fmt.Errorf("must provide a pubkey with the `pub` key"))
+		return
+	}
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide a message msg with the `msg` key"))
+		return
+	}
+	if sig == "" {
+		WriteError(w, fmt.Errorf("must provide a signature with the `sig` key"))
+		return
+	}
+
+	if err := checkSigFormat(sig, args["format"]); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	res, err := coreVerifyScheme(typ, args["scheme"], pub, msg, sig)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%v", res))
+}
+
+func recoverHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	hash, sig := args["hash"], args["sig"]
+	if hash == "" {
+		WriteError(w, fmt.Errorf("must provide a hash with the `hash` key"))
+		return
+	}
+	if sig == "" {
+		WriteError(w, fmt.Errorf("must provide a signature with the `sig` key"))
+		return
+	}
+
+	rec, err := coreRecover(hash, sig)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
+}
+
+func verifyBatchHandler(w http.ResponseWriter, r *http.Request) {
+	typ, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	pubs, msgs, sigs := splitList(args["pubs"]), splitList(args["msgs"]), splitList(args["sigs"])
+	if len(pubs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated pubkeys with the `pubs` key"))
+		return
+	}
+	if len(msgs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated messages with the `msgs` key"))
+		return
+	}
+	if len(sigs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated signatures with the `sigs` key"))
+		return
+	}
+
+	results, err := coreVerifyBatch(typ, pubs, msgs, sigs)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	b, err := json.Marshal(results)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
+}
+
+func popHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	proof, err := coreProofOfPossession(addr, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%X", proof))
+}
+
+func verifyPopHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	pub, proof := args["pub"], args["pop"]
+	if pub == "" {
+		WriteError(w, fmt.Errorf("must provide a pubkey with the `pub` key"))
+		return
+	}
+	if proof == "" {
+		WriteError(w, fmt.Errorf("must provide a proof of possession with the `pop` key"))
+		return
+	}
+	res, err := coreVerifyProofOfPossession(pub, proof)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%v", res))
+}
+
+func aggregateHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	sigs := splitList(args["sigs"])
+	if len(sigs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated signatures with the `sigs` key"))
+		return
+	}
+	agg, err := coreAggregate(sigs)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%X", agg))
+}
+
+func verifyAggregateHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	sig, msgs, pubs := args["sig"], splitList(args["msgs"]), splitList(args["pubs"])
+	if sig == "" {
+		WriteError(w, fmt.Errorf("must provide the aggregate signature with the `sig` key"))
+		return
+	}
+	if len(msgs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated messages with the `msgs` key"))
+		return
+	}
+	if len(pubs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated pubkeys with the `pubs` key"))
+		return
+	}
+	res, err := coreVerifyAggregate(sig, msgs, pubs, splitList(args["pops"]))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%v", res))
+}
+
+func multisigNewHandler(w http.ResponseWriter, r *http.Request) {
+	typ, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	pubs := splitList(args["pubs"])
+	if len(pubs) == 0 {
+		WriteError(w, fmt.Errorf("must provide comma separated pubkeys with the `pubs` key"))
+		return
+	}
+	threshold, err := strconv.Atoi(args["threshold"])
+	if err != nil {
+		WriteError(w, fmt.Errorf("must provide a numeric threshold with the `threshold` key"))
+		return
+	}
+	addr, err := coreMultisigNew(typ, threshold, pubs, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if name := args["name"]; name != "" {
+		if err := coreNameAdd(name, addr); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	WriteResult(w, addr)
+}
+
+func multisigSignHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	multisig, msg := args["multisig"], args["msg"]
+	if multisig == "" {
+		WriteError(w, fmt.Errorf("must provide the multisig address with the `multisig` key"))
+		return
+	}
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide a message to sign with the `msg` key"))
+		return
+	}
+	sigs, err := coreMultisigSign(msg, addr, multisig, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	b, err := json.Marshal(sigs)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
+}
+
+func multisigVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if args["sigs"] == "" {
+		WriteError(w, fmt.Errorf("must provide the json signature set with the `sigs` key"))
+		return
+	}
+	sigs := new(MultisigSigs)
+	if err := json.Unmarshal([]byte(args["sigs"]), sigs); err != nil {
+		WriteError(w, fmt.Errorf("invalid signature set: %v", err))
+		return
+	}
+	multisig := args["multisig"]
+	if name := args["name"]; name != "" {
+		if multisig, err = getNameAddr(name, multisig); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	res, err := coreMultisigVerify(sigs, multisig)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%v", res))
+}
+
+func frostDKGRound1Handler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	threshold, err := strconv.Atoi(args["threshold"])
+	if err != nil {
+		WriteError(w, fmt.Errorf("must provide a numeric threshold with the `threshold` key"))
+		return
+	}
+	pkg, err := coreFrostDKGRound1(args["session"], args["id"], args["identity"], threshold)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSONResult(w, pkg)
+}
+
+func frostDKGRound2Handler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	var packages []*FrostDKGPackage
+	if err := json.Unmarshal([]byte(args["packages"]), &packages); err != nil {
+		WriteError(w, fmt.Errorf("must provide the json round 1 packages with the `packages` key: %v", err))
+		return
+	}
+	round2, err := coreFrostDKGRound2(args["session"], args["id"], packages)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSONResult(w, round2)
+}
+
+func frostDKGFinishHandler(w http.ResponseWriter, r *http.Request) {
+	_, auth, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	var shares []*FrostDKGShare
+	if err := json.Unmarshal([]byte(args["shares"]), &shares); err != nil {
+		WriteError(w, fmt.Errorf("must provide the json round 2 shares with the `shares` key: %v", err))
+		return
+	}
+	var echoes map[uint16][]byte
+	if err := json.Unmarshal([]byte(args["echoes"]), &echoes); err != nil {
+		WriteError(w, fmt.Errorf("must provide the json round 2 echoes with the `echoes` key: %v", err))
+		return
+	}
+	addr, err := coreFrostDKGFinish(args["session"], args["id"], auth, shares, echoes, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if name := args["name"]; name != "" {
+		if err := coreNameAdd(name, addr); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	group, err := coreFrostGroup(addr, args["id"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSONResult(w, group)
+}
+
+func frostGroupHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, err := getNameAddr(args["name"], args["addr"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	group, err := coreFrostGroup(addr, args["id"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSONResult(w, group)
+}
+
+func frostCommitHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, err := getNameAddr(args["name"], args["addr"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	commitment, err := coreFrostCommit(addr, args["id"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSONResult(w, commitment)
+}
+
+func frostSignHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, err := getNameAddr(args["name"], args["addr"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	msg := args["msg"]
+	if msg == "" {
+		WriteError(w, fmt.Errorf("must provide a message to sign with the `msg` key"))
+		return
+	}
+	var commitments []*ed25519.SigningCommitment
+	if err := json.Unmarshal([]byte(args["commitments"]), &commitments); err != nil {
+		WriteError(w, fmt.Errorf("must provide the json signing commitments with the `commitments` key: %v", err))
+		return
+	}
+	share, err := coreFrostSign(addr, args["id"], msg, commitments, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSONResult(w, share)
+}
+
+func writeJSONResult(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
+}
+
+func hashHandler(w http.ResponseWriter, r *http.Request) {
+	typ, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	msg := args["msg"]
+	hexD := args["hex"]
+
+	hash, err := coreHash(typ, msg, hexD == "true")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("%X", hash))
+}
+
+func importHandler(w http.ResponseWriter, r *http.Request) {
+	typ, auth, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	name, key, validator := args["data"], args["key"], args["validator"] == "true"
+	if validator {
+		if err := checkValidatorType(typ); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+
+	addr, err := coreImport(auth, typ, key, r.RemoteAddr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if validator {
+		if err := coreMarkValidator(fmt.Sprintf("%X", addr), r.RemoteAddr); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+
+	if name != "" {
+		if err := coreNameAdd(name, strings.ToUpper(hex.EncodeToString(addr))); err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	WriteResult(w, fmt.Sprintf("%X", addr))
+}
+
+func nameHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	name, addr := args["name"], args["addr"]
+
+	logger.Debugf("name handler. name (%s). addr (%s)\n", name, addr)
+
+	if name == "" {
+		WriteError(w, fmt.Errorf("please specify a name"))
+		return
+	}
+
+	if addr == "" {
+		addr, err := coreNameGet(name)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		WriteResult(w, addr)
+	} else {
+		if err := coreNameAdd(name, strings.ToUpper(addr)); err != nil {
+			WriteError(w, err)
+			return
+		}
+		WriteResult(w, fmt.Sprintf("Added name (%s) to address (%s)", name, addr))
+	}
+}
+
+func nameLsHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	name, addr := args["name"], args["addr"]
+
+	logger.Debugf("name ls handler. name (%s). addr (%s)\n", name, addr)
+
+	names, err := coreNameList()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	b, err := json.Marshal(names)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
+	return
+}
+
+func nameRmHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	name, addr := args["name"], args["addr"]
+
+	logger.Debugf("name rm handler. name (%s). addr (%s)\n", name, addr)
+
+	if name == "" {
+		WriteError(w, fmt.Errorf("please specify a name"))
+		return
+	}
+
+	if err := coreNameRm(name); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	WriteResult(w, fmt.Sprintf("Removed name (%s)", name))
+}
+
+func auditVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if _, _, _, err := typeAuthArgs(r); err != nil {
+		WriteError(w, err)
+		return
+	}
+	n, head, err := coreAuditVerify()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, fmt.Sprintf("audit log ok (%d entries, head %s)", n, head))
+}
+
+func auditQueryHandler(w http.ResponseWriter, r *http.Request) {
+	_, _, args, err := typeAuthArgs(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	addr, name := args["addr"], args["name"]
+	addr, err = getNameAddr(name, addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	entries, err := coreAuditQuery(addr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResult(w, string(b))
 }
 
 // convenience function
@@ -2,15 +2,19 @@ package keys
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/eris-ltd/eris-keys/crypto"
+	"github.com/eris-ltd/eris-keys/crypto/randentropy"
 )
 
 // start the server
@@ -90,12 +94,348 @@ func testServerSignAndVerify(t *testing.T, typ string) {
 	}
 }
 
+func TestServerKeygenVanity(t *testing.T) {
+	req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": "secp256k1,sha3", "vanity": "e7", "workers": "2"}))
+	addr, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	if !strings.HasPrefix(addr, "E7") {
+		t.Fatalf("Address %s doesn't start with the vanity prefix", addr)
+	}
+	req, _ = http.NewRequest("POST", TestAddr+"/pub", formatForBody(map[string]string{"addr": addr}))
+	_, errS, err = requestResponse(req)
+	checkErrs(t, errS, err)
+}
+
+func TestServerKeygenVanityProgress(t *testing.T) {
+	crypto.VanityProgressInterval = time.Millisecond
+	defer func() { crypto.VanityProgressInterval = 5 * time.Second }()
+
+	// the client goes away when ctx is cancelled, which should stop the search
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": "secp256k1,sha3", "vanity": "ffffffff", "search": "progress-test"}))
+	gen := make(chan error)
+	go func() {
+		_, _, err := requestResponse(req.WithContext(ctx))
+		gen <- err
+	}()
+
+	progress := func() (*VanityProgress, string) {
+		req, _ := http.NewRequest("POST", TestAddr+"/gen/progress", formatForBody(map[string]string{"search": "progress-test"}))
+		r, errS, err := requestResponse(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errS != "" {
+			return nil, errS
+		}
+		p := new(VanityProgress)
+		if err := json.Unmarshal([]byte(r), p); err != nil {
+			t.Fatal(err)
+		}
+		return p, ""
+	}
+	var p *VanityProgress
+	for deadline := time.Now().Add(10 * time.Second); p == nil || p.Tried == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the vanity search's progress")
+		}
+		p, _ = progress()
+	}
+	if p.ExpectedTries != crypto.VanityDifficulty("ffffffff") || p.Rate <= 0 || p.RemainingSeconds <= 0 {
+		t.Fatalf("Bad progress %+v", p)
+	}
+
+	cancel()
+	if err := <-gen; err == nil {
+		t.Fatal("Expected the cancelled request to fail")
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, errS := progress(); errS != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The vanity search didn't stop when its client went away")
+		}
+	}
+
+	for _, workers := range []string{"0", "-1", "x"} {
+		req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": "secp256k1,sha3", "vanity": "e7", "workers": workers}))
+		if _, errS, _ := requestResponse(req); errS == "" {
+			t.Fatalf("Expected an error for %s workers", workers)
+		}
+	}
+}
+
+func TestServerKeygenEntropy(t *testing.T) {
+	rolls := strings.Repeat("316524", 20)
+	file, err := randentropy.GetEntropyCSPRNG(randentropy.FileSourceBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range KEY_TYPES {
+		for _, source := range []string{"os", "dice:" + rolls, "bytes:" + hex.EncodeToString(file)} {
+			req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": typ, "entropy": source}))
+			addr, errS, err := requestResponse(req)
+			checkErrs(t, errS, err)
+			req, _ = http.NewRequest("POST", TestAddr+"/pub", formatForBody(map[string]string{"addr": addr}))
+			_, errS, err = requestResponse(req)
+			checkErrs(t, errS, err)
+		}
+	}
+
+	for _, args := range []map[string]string{
+		{"entropy": "dice:1111"},
+		{"entropy": "bogus"},
+		{"entropy": "file:/dev/urandom"},
+		{"entropy": "bytes:" + strings.Repeat("00", 2048)},
+		{"entropy": "os", "vanity": "e7"},
+	} {
+		args["type"] = "secp256k1,sha3"
+		req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(args))
+		if _, errS, _ := requestResponse(req); errS == "" {
+			t.Fatalf("Expected an error for %v", args)
+		}
+	}
+}
+
 func TestServerSignAndVerify(t *testing.T) {
 	for _, typ := range KEY_TYPES {
 		testServerSignAndVerify(t, typ)
 	}
 }
 
+func TestServerSchnorr(t *testing.T) {
+	typ := "secp256k1,sha3"
+	req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": typ}))
+	addr, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+
+	req, _ = http.NewRequest("POST", TestAddr+"/pub", formatForBody(map[string]string{"addr": addr, "scheme": "schnorr"}))
+	pub, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	if len(pub) != 64 {
+		t.Fatalf("Expected a 32 byte x-only pubkey, got %s", pub)
+	}
+
+	msg := toHex([]byte("schnorr signs messages of any length"))
+	req, _ = http.NewRequest("POST", TestAddr+"/sign", formatForBody(map[string]string{"msg": msg, "addr": addr, "scheme": "schnorr"}))
+	sig, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	if len(sig) != 128 {
+		t.Fatalf("Expected a 64 byte signature, got %s", sig)
+	}
+
+	req, _ = http.NewRequest("POST", TestAddr+"/verify", formatForBody(map[string]string{"type": typ, "msg": msg, "pub": pub, "sig": sig, "scheme": "schnorr"}))
+	res, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	if res != "true" {
+		t.Fatalf("Schnorr signature failed to verify")
+	}
+
+	req, _ = http.NewRequest("POST", TestAddr+"/sign", formatForBody(map[string]string{"msg": msg, "addr": addr, "scheme": "schnorr", "format": "der"}))
+	if _, errS, err = requestResponse(req); err == nil && errS == "" {
+		t.Fatal("Expected an error for a schnorr signature format")
+	}
+}
+
+func TestServerSignWithHash(t *testing.T) {
+	typ := "ed25519,ripemd160"
+	req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": typ}))
+	addr, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+
+	req, _ = http.NewRequest("POST", TestAddr+"/pub", formatForBody(map[string]string{"addr": addr}))
+	pub, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+
+	data := []byte("something that is not a hash")
+	req, _ = http.NewRequest("POST", TestAddr+"/sign", formatForBody(map[string]string{"msg": toHex(data), "hash": "sha256", "addr": addr}))
+	sig, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+
+	hash := crypto.Sha256(data)
+	req, _ = http.NewRequest("POST", TestAddr+"/verify", formatForBody(map[string]string{"type": typ, "msg": toHex(hash), "pub": pub, "sig": sig}))
+	res, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	if res != "true" {
+		t.Fatalf("Signature of sha256 hash failed to verify")
+	}
+}
+
+func testServerBatch(t *testing.T, typ string) {
+	var addrs, pubs, hashes []string
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": typ}))
+		addr, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		req, _ = http.NewRequest("POST", TestAddr+"/pub", formatForBody(map[string]string{"addr": addr}))
+		pub, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		addrs, pubs = append(addrs, addr), append(pubs, pub)
+		hashes = append(hashes, toHex(crypto.Sha3([]byte(fmt.Sprintf("hash %d", i)))))
+	}
+
+	req, _ := http.NewRequest("POST", TestAddr+"/sign/batch", formatForBody(map[string]string{"msgs": strings.Join(hashes, ","), "addrs": strings.Join(addrs, ",")}))
+	r, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	var sigs []string
+	if err := json.Unmarshal([]byte(r), &sigs); err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != len(hashes) {
+		t.Fatalf("Expected %d signatures, got %d", len(hashes), len(sigs))
+	}
+
+	// break the last signature
+	sigs[2] = sigs[1]
+	req, _ = http.NewRequest("POST", TestAddr+"/verify/batch", formatForBody(map[string]string{"type": typ, "msgs": strings.Join(hashes, ","), "sigs": strings.Join(sigs, ","), "pubs": strings.Join(pubs, ",")}))
+	r, errS, err = requestResponse(req)
+	checkErrs(t, errS, err)
+	var results []bool
+	if err := json.Unmarshal([]byte(r), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || !results[0] || !results[1] || results[2] {
+		t.Fatalf("Wrong batch verification results (type %s): %v", typ, results)
+	}
+
+	// a signature of the wrong length is just invalid
+	sigs[1] = sigs[1][:16]
+	req, _ = http.NewRequest("POST", TestAddr+"/verify/batch", formatForBody(map[string]string{"type": typ, "msgs": strings.Join(hashes, ","), "sigs": strings.Join(sigs, ","), "pubs": strings.Join(pubs, ",")}))
+	r, errS, err = requestResponse(req)
+	checkErrs(t, errS, err)
+	results = nil
+	if err := json.Unmarshal([]byte(r), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || !results[0] || results[1] || results[2] {
+		t.Fatalf("Wrong batch verification results with a short signature (type %s): %v", typ, results)
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	for _, typ := range KEY_TYPES {
+		testServerBatch(t, typ)
+	}
+}
+
+func TestServerValidatorKey(t *testing.T) {
+	req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": "secp256k1,sha3", "validator": "true"}))
+	_, errS, err := requestResponse(req)
+	if err == nil && errS == "" {
+		t.Fatal("Expected an error for a secp256k1 validator key")
+	}
+
+	req, _ = http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": "ed25519,ripemd160", "validator": "true"}))
+	addr, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	req, _ = http.NewRequest("POST", TestAddr+"/sign", formatForBody(map[string]string{"msg": voteSignBytes(1, 0, voteTypePrevote, "AA"), "addr": addr}))
+	_, errS, err = requestResponse(req)
+	if err == nil && errS == "" {
+		t.Fatal("Expected raw signing with a new validator key to be refused")
+	}
+	req, _ = http.NewRequest("POST", TestAddr+"/sign/validator", formatForBody(map[string]string{"msg": voteSignBytes(1, 0, voteTypePrevote, "AA"), "addr": addr}))
+	_, errS, err = requestResponse(req)
+	checkErrs(t, errS, err)
+}
+
+func TestServerAggregate(t *testing.T) {
+	hash := toHex(crypto.Sha256([]byte(testSigData)))
+	var pubs, pops, sigs []string
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": "bls12381,sha256"}))
+		addr, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		req, _ = http.NewRequest("POST", TestAddr+"/pub", formatForBody(map[string]string{"addr": addr}))
+		pub, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		req, _ = http.NewRequest("POST", TestAddr+"/pop", formatForBody(map[string]string{"addr": addr}))
+		pop, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		req, _ = http.NewRequest("POST", TestAddr+"/sign", formatForBody(map[string]string{"msg": hash, "addr": addr}))
+		sig, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		pubs, pops, sigs = append(pubs, pub), append(pops, pop), append(sigs, sig)
+	}
+
+	req, _ := http.NewRequest("POST", TestAddr+"/verify/pop", formatForBody(map[string]string{"pub": pubs[0], "pop": pops[0]}))
+	r, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	if r != "true" {
+		t.Fatalf("Proof of possession failed to verify")
+	}
+
+	req, _ = http.NewRequest("POST", TestAddr+"/aggregate", formatForBody(map[string]string{"sigs": strings.Join(sigs, ",")}))
+	agg, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+
+	req, _ = http.NewRequest("POST", TestAddr+"/verify/aggregate", formatForBody(map[string]string{"sig": agg, "msgs": hash, "pubs": strings.Join(pubs, ","), "pops": strings.Join(pops, ",")}))
+	r, errS, err = requestResponse(req)
+	checkErrs(t, errS, err)
+	if r != "true" {
+		t.Fatalf("Aggregate signature failed to verify")
+	}
+
+	// a proof of possession for the wrong key fails the request
+	pops[0], pops[1] = pops[1], pops[0]
+	req, _ = http.NewRequest("POST", TestAddr+"/verify/aggregate", formatForBody(map[string]string{"sig": agg, "msgs": hash, "pubs": strings.Join(pubs, ","), "pops": strings.Join(pops, ",")}))
+	_, errS, err = requestResponse(req)
+	if err == nil && errS == "" {
+		t.Fatal("Expected an error for swapped proofs of possession")
+	}
+
+	// and so does a shared hash without them
+	req, _ = http.NewRequest("POST", TestAddr+"/verify/aggregate", formatForBody(map[string]string{"sig": agg, "msgs": hash, "pubs": strings.Join(pubs, ",")}))
+	_, errS, err = requestResponse(req)
+	if err == nil && errS == "" {
+		t.Fatal("Expected an error for a shared hash without proofs of possession")
+	}
+}
+
+func TestServerMultisig(t *testing.T) {
+	typ := "secp256k1,sha3"
+	hash := toHex(crypto.Sha3([]byte(testSigData)))
+	var addrs, pubs []string
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("POST", TestAddr+"/gen", formatForBody(map[string]string{"type": typ}))
+		addr, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		req, _ = http.NewRequest("POST", TestAddr+"/pub", formatForBody(map[string]string{"addr": addr}))
+		pub, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		addrs, pubs = append(addrs, addr), append(pubs, pub)
+	}
+
+	req, _ := http.NewRequest("POST", TestAddr+"/multisig/new", formatForBody(map[string]string{"type": typ, "threshold": "2", "pubs": strings.Join(pubs, ","), "name": "treasury"}))
+	multisig, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+
+	var sigs *MultisigSigs
+	for _, addr := range addrs[1:] {
+		req, _ = http.NewRequest("POST", TestAddr+"/multisig/sign", formatForBody(map[string]string{"addr": addr, "multisig": multisig, "msg": hash}))
+		r, errS, err := requestResponse(req)
+		checkErrs(t, errS, err)
+		partial := new(MultisigSigs)
+		if err := json.Unmarshal([]byte(r), partial); err != nil {
+			t.Fatal(err)
+		}
+		if sigs == nil {
+			sigs = partial
+		} else if err := sigs.add(partial); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, _ := json.Marshal(sigs)
+	req, _ = http.NewRequest("POST", TestAddr+"/multisig/verify", formatForBody(map[string]string{"sigs": string(b), "name": "treasury"}))
+	r, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+	if r != "true" {
+		t.Fatalf("Multisig signatures failed to verify")
+	}
+}
+
 func testServerHash(t *testing.T, typ string) {
 	hData := hashData[typ]
 	data, expected := hData.data, hData.expected
@@ -116,6 +456,48 @@ func TestServerHash(t *testing.T) {
 	}
 }
 
+func TestServerMetrics(t *testing.T) {
+	testServerSignAndVerify(t, "ed25519,ripemd160")
+
+	resp, err := http.Get(TestAddr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{
+		`eris_keys_requests_total{handler="sign"}`,
+		`eris_keys_request_duration_seconds_bucket{handler="sign",le="+Inf"}`,
+		`eris_keys_signatures_total{key_type="ed25519,ripemd160"}`,
+		`eris_keys_unlocked_keys`,
+	} {
+		if !strings.Contains(string(b), m) {
+			t.Fatalf("Metrics missing %s. Got:\n%s", m, b)
+		}
+	}
+}
+
+func TestServerRefusesCrossOrigin(t *testing.T) {
+	body := formatForBody(map[string]string{"type": "sha256", "msg": "hi"})
+	req, _ := http.NewRequest("POST", TestAddr+"/hash", body)
+	req.Header.Set("Origin", "http://evil.example.com")
+	if _, _, err := requestResponse(req); err == nil {
+		t.Fatal("Expected request from unknown origin to be refused")
+	}
+
+	CORSAllowedOrigins = []string{"http://good.example.com"}
+	defer func() { CORSAllowedOrigins = nil }()
+
+	body = formatForBody(map[string]string{"type": "sha256", "msg": "hi"})
+	req, _ = http.NewRequest("POST", TestAddr+"/hash", body)
+	req.Header.Set("Origin", "http://good.example.com")
+	_, errS, err := requestResponse(req)
+	checkErrs(t, errS, err)
+}
+
 //---------------------------------------------------------------------------------
 
 func checkErrs(t *testing.T, errS string, err error) {
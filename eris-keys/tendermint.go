@@ -0,0 +1,194 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/tendermint/account"
+	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/tendermint/wire"
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+//------------------------------------------------------------------------
+// tendermint transactions
+//
+// The tendermint types package isn't vendored, so we mirror the
+// SendTx and CallTx types and their sign bytes here.
+// Transactions are read and written in the wire json format,
+// eg. [1,{"inputs":[...],"outputs":[...]}] for a SendTx
+
+type TendermintTx interface {
+	WriteSignBytes(chainID string, w io.Writer, n *int64, err *error)
+}
+
+// Types of TendermintTx implementations
+const (
+	TxTypeSend = byte(0x01)
+	TxTypeCall = byte(0x02)
+)
+
+var _ = wire.RegisterInterface(
+	struct{ TendermintTx }{},
+	wire.ConcreteType{O: &SendTx{}, Byte: TxTypeSend},
+	wire.ConcreteType{O: &CallTx{}, Byte: TxTypeCall},
+)
+
+type TxInput struct {
+	Address   []byte            `json:"address"`
+	Amount    int64             `json:"amount"`
+	Sequence  int               `json:"sequence"`
+	Signature account.Signature `json:"signature"`
+	PubKey    account.PubKey    `json:"pub_key"`
+}
+
+func (txIn *TxInput) WriteSignBytes(w io.Writer, n *int64, err *error) {
+	wire.WriteTo([]byte(fmt.Sprintf(`{"address":"%X","amount":%v,"sequence":%v}`, txIn.Address, txIn.Amount, txIn.Sequence)), w, n, err)
+}
+
+type TxOutput struct {
+	Address []byte `json:"address"`
+	Amount  int64  `json:"amount"`
+}
+
+func (txOut *TxOutput) WriteSignBytes(w io.Writer, n *int64, err *error) {
+	wire.WriteTo([]byte(fmt.Sprintf(`{"address":"%X","amount":%v}`, txOut.Address, txOut.Amount)), w, n, err)
+}
+
+type SendTx struct {
+	Inputs  []*TxInput  `json:"inputs"`
+	Outputs []*TxOutput `json:"outputs"`
+}
+
+func (tx *SendTx) WriteSignBytes(chainID string, w io.Writer, n *int64, err *error) {
+	wire.WriteTo([]byte(fmt.Sprintf(`{"chain_id":%s`, jsonEscape(chainID))), w, n, err)
+	wire.WriteTo([]byte(fmt.Sprintf(`,"tx":[%v,{"inputs":[`, TxTypeSend)), w, n, err)
+	for i, in := range tx.Inputs {
+		in.WriteSignBytes(w, n, err)
+		if i != len(tx.Inputs)-1 {
+			wire.WriteTo([]byte(","), w, n, err)
+		}
+	}
+	wire.WriteTo([]byte(`],"outputs":[`), w, n, err)
+	for i, out := range tx.Outputs {
+		out.WriteSignBytes(w, n, err)
+		if i != len(tx.Outputs)-1 {
+			wire.WriteTo([]byte(","), w, n, err)
+		}
+	}
+	wire.WriteTo([]byte(`]}]}`), w, n, err)
+}
+
+type CallTx struct {
+	Input    *TxInput `json:"input"`
+	Address  []byte   `json:"address"`
+	GasLimit int64    `json:"gas_limit"`
+	Fee      int64    `json:"fee"`
+	Data     []byte   `json:"data"`
+}
+
+func (tx *CallTx) WriteSignBytes(chainID string, w io.Writer, n *int64, err *error) {
+	wire.WriteTo([]byte(fmt.Sprintf(`{"chain_id":%s`, jsonEscape(chainID))), w, n, err)
+	wire.WriteTo([]byte(fmt.Sprintf(`,"tx":[%v,{"address":"%X","data":"%X"`, TxTypeCall, tx.Address, tx.Data)), w, n, err)
+	wire.WriteTo([]byte(fmt.Sprintf(`,"fee":%v,"gas_limit":%v,"input":`, tx.Fee, tx.GasLimit)), w, n, err)
+	tx.Input.WriteSignBytes(w, n, err)
+	wire.WriteTo([]byte(`}]}`), w, n, err)
+}
+
+func jsonEscape(str string) string {
+	escaped, err := json.Marshal(str)
+	if err != nil {
+		panic(fmt.Sprintf("error json escaping %s: %v", str, err))
+	}
+	return string(escaped)
+}
+
+// tendermintSignBytes returns the canonical bytes to sign for the tx
+func tendermintSignBytes(chainID string, tx TendermintTx) ([]byte, error) {
+	buf, n, err := new(bytes.Buffer), new(int64), new(error)
+	tx.WriteSignBytes(chainID, buf, n, err)
+	return buf.Bytes(), *err
+}
+
+// coreSignTendermintTx signs every input of the wire json encoded
+// SendTx or CallTx that belongs to the key, and returns the signed tx
+func coreSignTendermintTx(chainID, txJSON, addr, client string) ([]byte, error) {
+	if chainID == "" {
+		return nil, fmt.Errorf("a chain id is required to sign tendermint transactions")
+	}
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+
+	var tx TendermintTx
+	var wireErr error
+	wire.ReadJSONPtr(&tx, []byte(txJSON), &wireErr)
+	if wireErr != nil {
+		return nil, fmt.Errorf("invalid tendermint transaction json: %v", wireErr)
+	}
+
+	// the sign bytes can't be written with missing inputs or outputs
+	var inputs []*TxInput
+	switch tx := tx.(type) {
+	case *SendTx:
+		for i, in := range tx.Inputs {
+			if in == nil {
+				return nil, fmt.Errorf("input %d of the transaction is missing", i)
+			}
+		}
+		for i, out := range tx.Outputs {
+			if out == nil {
+				return nil, fmt.Errorf("output %d of the transaction is missing", i)
+			}
+		}
+		inputs = tx.Inputs
+	case *CallTx:
+		if tx.Input == nil {
+			return nil, fmt.Errorf("the call transaction has no input")
+		}
+		inputs = []*TxInput{tx.Input}
+	default:
+		return nil, fmt.Errorf("unsupported tendermint transaction")
+	}
+
+	signBytes, err := tendermintSignBytes(chainID, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if curve, err := keyCurveType(addr); err != nil {
+		return nil, err
+	} else if curve != crypto.CurveTypeEd25519 {
+		return nil, fmt.Errorf("tendermint transactions require an ed25519 key")
+	}
+	pub, err := corePub(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var signed bool
+	for _, in := range inputs {
+		if !bytes.Equal(in.Address, addrB) {
+			continue
+		}
+		sig, err := coreSignTx(hex.EncodeToString(signBytes), addr, client)
+		if err != nil {
+			return nil, err
+		}
+		var sigEd account.SignatureEd25519
+		copy(sigEd[:], sig)
+		var pubEd account.PubKeyEd25519
+		copy(pubEd[:], pub)
+		in.Signature = sigEd
+		in.PubKey = pubEd
+		signed = true
+	}
+	if !signed {
+		return nil, fmt.Errorf("no input of the transaction belongs to %s", addr)
+	}
+
+	return wire.JSONBytes(&tx), nil
+}
@@ -0,0 +1,116 @@
+package keys
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+
+	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/tendermint/account"
+	"github.com/eris-ltd/eris-keys/Godeps/_workspace/src/github.com/eris-ltd/tendermint/wire"
+)
+
+func readTendermintTx(t *testing.T, txJSON []byte) TendermintTx {
+	var tx TendermintTx
+	var err error
+	wire.ReadJSONPtr(&tx, txJSON, &err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func checkTendermintSig(t *testing.T, chainID string, tx TendermintTx, in *TxInput) {
+	signBytes, err := tendermintSignBytes(chainID, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, ok := in.Signature.(account.SignatureEd25519)
+	if !ok {
+		t.Fatalf("Input is not signed: %v", in.Signature)
+	}
+	pub, ok := in.PubKey.(account.PubKeyEd25519)
+	if !ok {
+		t.Fatalf("Input is missing its pubkey: %v", in.PubKey)
+	}
+	res, err := crypto.Verify(crypto.CurveTypeEd25519, signBytes, sig[:], pub[:])
+	if err != nil || !res {
+		t.Fatalf("Input signature failed to verify: %v", err)
+	}
+}
+
+func TestTendermintSignBytes(t *testing.T) {
+	tx := readTendermintTx(t, []byte(`[1,{"inputs":[{"address":"0102","amount":12345,"sequence":67890}],"outputs":[{"address":"0304","amount":333}]}]`))
+	sb, err := tendermintSignBytes("test_chain", tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"chain_id":"test_chain","tx":[1,{"inputs":[{"address":"0102","amount":12345,"sequence":67890}],"outputs":[{"address":"0304","amount":333}]}]}`
+	if string(sb) != expected {
+		t.Fatalf("Wrong sign bytes. Got %s, expected %s", sb, expected)
+	}
+
+	tx = readTendermintTx(t, []byte(`[2,{"input":{"address":"0102","amount":12345,"sequence":67890},"address":"0506","gas_limit":111,"fee":222,"data":"ABCD"}]`))
+	sb, err = tendermintSignBytes("test_chain", tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"chain_id":"test_chain","tx":[2,{"address":"0506","data":"ABCD","fee":222,"gas_limit":111,"input":{"address":"0102","amount":12345,"sequence":67890}}]}`
+	if string(sb) != expected {
+		t.Fatalf("Wrong sign bytes. Got %s, expected %s", sb, expected)
+	}
+}
+
+func TestSignTendermintTx(t *testing.T) {
+	addr, err := coreKeygen(AUTH, "ed25519,ripemd160", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := toHex(addr)
+
+	sendTx := fmt.Sprintf(`[1,{"inputs":[{"address":"%s","amount":10,"sequence":1},{"address":"0102","amount":5,"sequence":3}],"outputs":[{"address":"0304","amount":15}]}]`, addrHex)
+	signed, err := coreSignTendermintTx("test_chain", sendTx, addrHex, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := readTendermintTx(t, signed).(*SendTx)
+	checkTendermintSig(t, "test_chain", tx, tx.Inputs[0])
+	if tx.Inputs[1].Signature != nil {
+		t.Fatalf("Expected input of another account to be left unsigned")
+	}
+
+	callTx := fmt.Sprintf(`[2,{"input":{"address":"%s","amount":10,"sequence":2},"address":"0506","gas_limit":1000,"fee":1,"data":"ABCD"}]`, addrHex)
+	signed, err = coreSignTendermintTx("test_chain", callTx, addrHex, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := readTendermintTx(t, signed).(*CallTx)
+	checkTendermintSig(t, "test_chain", ctx, ctx.Input)
+
+	if _, err := coreSignTendermintTx("test_chain", `[1,{"inputs":[{"address":"0102","amount":5,"sequence":3}],"outputs":[]}]`, addrHex, ""); err == nil {
+		t.Fatal("Expected tx with no inputs for the key to fail")
+	}
+	if _, err := coreSignTendermintTx("", sendTx, addrHex, ""); err == nil {
+		t.Fatal("Expected signing without a chain id to fail")
+	}
+
+	// missing inputs and outputs are errors, not panics
+	for _, txJSON := range []string{
+		`[2,{"address":"0506","gas_limit":1,"fee":1,"data":"AB"}]`,
+		fmt.Sprintf(`[1,{"inputs":[{"address":"%s","amount":10,"sequence":1},null],"outputs":[]}]`, addrHex),
+		fmt.Sprintf(`[1,{"inputs":[{"address":"%s","amount":10,"sequence":1}],"outputs":[null]}]`, addrHex),
+	} {
+		if _, err := coreSignTendermintTx("test_chain", txJSON, addrHex, ""); err == nil {
+			t.Fatalf("Expected an error for %s", txJSON)
+		}
+	}
+
+	secpAddr, err := coreKeygen(AUTH, "secp256k1,sha3", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secpTx := fmt.Sprintf(`[1,{"inputs":[{"address":"%s","amount":10,"sequence":1}],"outputs":[]}]`, toHex(secpAddr))
+	if _, err := coreSignTendermintTx("test_chain", secpTx, toHex(secpAddr), ""); err == nil {
+		t.Fatal("Expected signing with a secp256k1 key to fail")
+	}
+}
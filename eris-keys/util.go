@@ -85,7 +85,12 @@ func requestResponse(req *http.Request) (string, string, error) {
 
 // Call the http server
 func Call(method string, args map[string]string) (string, error) {
-	url := fmt.Sprintf("%s/%s", DaemonAddr, method)
+	return callAt(DaemonAddr, method, args)
+}
+
+// callAt calls the http server at daemonAddr, eg. http://localhost:4767
+func callAt(daemonAddr, method string, args map[string]string) (string, error) {
+	url := fmt.Sprintf("%s/%s", daemonAddr, method)
 	b, err := json.Marshal(args)
 	if err != nil {
 		return "", fmt.Errorf("Error marshaling args map: %v", err)
@@ -0,0 +1,300 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/eris-ltd/eris-keys/crypto"
+)
+
+//------------------------------------------------------------------------
+// validator signing
+//
+// In validator mode the message is not a hash but the sign bytes of a
+// tendermint vote or proposal. We decode the height, round and step,
+// and refuse to sign anything that would let the validator equivocate.
+// The last signed height/round/step is persisted per key in
+// <keys dir>/validators/<ADDR>.json, like the last_* fields
+// of a tendermint priv_validator.json. The file is made when the key
+// is marked as a validator key, and from then on raw signing is refused
+
+const (
+	stepNone      = 0
+	stepPropose   = 1
+	stepPrevote   = 2
+	stepPrecommit = 3
+)
+
+// vote types as in tendermint/types
+const (
+	voteTypePrevote   = 0x01
+	voteTypePrecommit = 0x02
+)
+
+type ErrDoubleSign string
+
+func (e ErrDoubleSign) Error() string {
+	return "refusing to double sign: " + string(e)
+}
+
+// the last signature made by a validator key
+type validatorState struct {
+	LastHeight    int    `json:"last_height"`
+	LastRound     int    `json:"last_round"`
+	LastStep      int    `json:"last_step"`
+	LastSignBytes []byte `json:"last_sign_bytes"`
+	LastSignature []byte `json:"last_signature"`
+}
+
+// the parts of tendermint's vote and proposal sign bytes we care about, ie.
+// {"chain_id":"...","vote":{...,"height":1,"round":0,"type":1}}
+// {"chain_id":"...","proposal":{"height":1,"round":0,...}}
+type validatorSignBytes struct {
+	ChainID string `json:"chain_id"`
+	Vote    *struct {
+		Height int  `json:"height"`
+		Round  int  `json:"round"`
+		Type   byte `json:"type"`
+	} `json:"vote"`
+	Proposal *struct {
+		Height int `json:"height"`
+		Round  int `json:"round"`
+	} `json:"proposal"`
+}
+
+// decodeSignBytes returns the height, round and step of vote or proposal sign bytes
+func decodeSignBytes(signBytes []byte) (height, round, step int, err error) {
+	sb := new(validatorSignBytes)
+	if err = json.Unmarshal(signBytes, sb); err != nil {
+		return 0, 0, 0, fmt.Errorf("sign bytes are not a tendermint vote or proposal: %v", err)
+	}
+	if sb.ChainID == "" {
+		return 0, 0, 0, fmt.Errorf("sign bytes are missing a chain_id")
+	}
+	switch {
+	case sb.Vote != nil && sb.Proposal != nil:
+		return 0, 0, 0, fmt.Errorf("sign bytes contain both a vote and a proposal")
+	case sb.Vote != nil:
+		switch sb.Vote.Type {
+		case voteTypePrevote:
+			step = stepPrevote
+		case voteTypePrecommit:
+			step = stepPrecommit
+		default:
+			return 0, 0, 0, fmt.Errorf("unknown vote type %d", sb.Vote.Type)
+		}
+		return sb.Vote.Height, sb.Vote.Round, step, nil
+	case sb.Proposal != nil:
+		return sb.Proposal.Height, sb.Proposal.Round, stepPropose, nil
+	}
+	return 0, 0, 0, fmt.Errorf("sign bytes contain neither a vote nor a proposal")
+}
+
+// guards the validator state files
+var validatorMtx sync.Mutex
+
+func returnValidatorsDir(dir string) (string, error) {
+	dir = path.Join(dir, "validators")
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return dir, checkMakeDataDir(dir)
+}
+
+func validatorStateFile(addr string) (string, error) {
+	dir, err := returnValidatorsDir(KeysDir)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, strings.ToUpper(addr)+".json"), nil
+}
+
+func loadValidatorState(addr string) (*validatorState, error) {
+	file, err := validatorStateFile(addr)
+	if err != nil {
+		return nil, err
+	}
+	state := new(validatorState)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("invalid validator state for %s: %v", addr, err)
+	}
+	return state, nil
+}
+
+// isValidator reports whether addr was marked as a validator key,
+// which is when its state file is made
+func isValidator(addr string) (bool, error) {
+	file, err := validatorStateFile(addr)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(file); err == nil {
+		return true, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// markValidator makes addr a validator key before it signs anything as one.
+// From then on only coreSignValidator signs with it
+func markValidator(addr string) error {
+	validatorMtx.Lock()
+	defer validatorMtx.Unlock()
+	if ok, err := isValidator(addr); err != nil || ok {
+		return err
+	}
+	return saveValidatorState(addr, new(validatorState))
+}
+
+// coreMarkValidator marks a key that was just made or imported as a validator key
+func coreMarkValidator(addr, client string) (err error) {
+	defer func() {
+		err = auditOp("validator", addr, nil, client, err)
+	}()
+	return markValidator(addr)
+}
+
+// checkValidatorType refuses to mark a key of the given type as a validator key
+func checkValidatorType(keyType string) error {
+	keyT, err := crypto.KeyTypeFromString(keyType)
+	if err != nil {
+		return err
+	}
+	if keyT.CurveType != crypto.CurveTypeEd25519 {
+		return fmt.Errorf("validator keys must be ed25519 keys, got %s", keyType)
+	}
+	return nil
+}
+
+// checkNotValidator refuses raw signing with a validator key.
+// Its signature over any bytes could be a vote or proposal that skips the double sign checks
+func checkNotValidator(addr string) error {
+	ok, err := isValidator(addr)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("%s is a validator key. Sign its votes and proposals with /sign/validator", strings.ToUpper(addr))
+	}
+	return nil
+}
+
+// saveValidatorState writes to a temp file and renames it so
+// a crash can never leave a partially written state behind
+func saveValidatorState(addr string, state *validatorState) error {
+	file, err := validatorStateFile(addr)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file+".tmp", b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// coreSignValidator signs tendermint vote or proposal sign bytes (hex encoded)
+// with an ed25519 key, refusing any height/round/step regression.
+// Signing the exact same bytes again returns the previous signature
+func coreSignValidator(msg, addr, client string) (sig []byte, err error) {
+	defer func() {
+		signBytes, _ := hex.DecodeString(msg)
+		if err = auditOp("sign/validator", addr, signBytes, client, err); err != nil {
+			sig = nil
+		}
+	}()
+
+	signBytes, err := hex.DecodeString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("sign bytes are invalid hex: %s", err.Error())
+	}
+	addrB, err := hex.DecodeString(addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr is invalid hex: %s", err.Error())
+	}
+	height, round, step, err := decodeSignBytes(signBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := GetKey(addrB)
+	if err != nil {
+		return nil, err
+	}
+	if key.Type.CurveType != crypto.CurveTypeEd25519 {
+		return nil, fmt.Errorf("validator signing requires an ed25519 key, got %s", key.Type)
+	}
+
+	validatorMtx.Lock()
+	defer validatorMtx.Unlock()
+
+	// a key that could have signed raw bytes before can't be trusted not to have double signed
+	if ok, err := isValidator(addr); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%s is not a validator key. Make or import it with `validator`, or convert it, first", strings.ToUpper(addr))
+	}
+	state, err := loadValidatorState(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.LastHeight > height {
+		return nil, ErrDoubleSign(fmt.Sprintf("height regression. Got %d, last height %d", height, state.LastHeight))
+	}
+	if state.LastHeight == height {
+		if state.LastRound > round {
+			return nil, ErrDoubleSign(fmt.Sprintf("round regression at height %d. Got %d, last round %d", height, round, state.LastRound))
+		}
+		if state.LastRound == round {
+			if state.LastStep > step {
+				return nil, ErrDoubleSign(fmt.Sprintf("step regression at height %d round %d. Got %d, last step %d", height, round, step, state.LastStep))
+			}
+			if state.LastStep == step {
+				if bytes.Equal(state.LastSignBytes, signBytes) {
+					return state.LastSignature, nil
+				}
+				return nil, ErrDoubleSign(fmt.Sprintf("conflicting data at height %d round %d step %d", height, round, step))
+			}
+		}
+	}
+
+	// ed25519 signs the full sign bytes, not a hash
+	sig, err = policySign(msg, addr, "", client)
+	if err != nil {
+		return nil, err
+	}
+
+	state = &validatorState{
+		LastHeight:    height,
+		LastRound:     round,
+		LastStep:      step,
+		LastSignBytes: signBytes,
+		LastSignature: sig,
+	}
+	// never hand out a signature we failed to record
+	if err := saveValidatorState(addr, state); err != nil {
+		return nil, fmt.Errorf("failed to save validator state: %v", err)
+	}
+	return sig, nil
+}
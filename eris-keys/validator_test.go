@@ -0,0 +1,167 @@
+package keys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func voteSignBytes(height, round int, typ byte, blockHash string) string {
+	sb := fmt.Sprintf(`{"chain_id":"test_chain","vote":{"block_hash":"%s","block_parts_header":{"total":1,"hash":"AB"},"height":%d,"round":%d,"type":%d}}`, blockHash, height, round, typ)
+	return hex.EncodeToString([]byte(sb))
+}
+
+func proposalSignBytes(height, round int) string {
+	sb := fmt.Sprintf(`{"chain_id":"test_chain","proposal":{"height":%d,"round":%d,"block_parts_header":{"total":1,"hash":"AB"},"pol_round":-1}}`, height, round)
+	return hex.EncodeToString([]byte(sb))
+}
+
+func TestSignValidator(t *testing.T) {
+	addr, err := coreKeygen(AUTH, "ed25519,ripemd160", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := toHex(addr)
+	if err := coreMarkValidator(addrHex, ""); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := corePub(addrHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevote := voteSignBytes(1, 0, voteTypePrevote, "AA")
+	sig1, err := coreSignValidator(prevote, addrHex, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := coreVerify("ed25519,ripemd160", toHex(pub), prevote, toHex(sig1))
+	if err != nil || !res {
+		t.Fatalf("Validator signature failed to verify: %v", err)
+	}
+
+	// signing the same bytes again is fine
+	sig2, err := coreSignValidator(prevote, addrHex, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig1, sig2) {
+		t.Fatalf("Expected the same signature for the same sign bytes")
+	}
+
+	// a different block at the same height/round/step is not
+	if _, err := coreSignValidator(voteSignBytes(1, 0, voteTypePrevote, "BB"), addrHex, ""); err == nil {
+		t.Fatal("Expected conflicting prevote to be refused")
+	} else if _, ok := err.(ErrDoubleSign); !ok {
+		t.Fatalf("Expected ErrDoubleSign, got %v", err)
+	}
+
+	if _, err := coreSignValidator(voteSignBytes(1, 0, voteTypePrecommit, "AA"), addrHex, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// step, round and height regressions
+	for _, sb := range []string{
+		voteSignBytes(1, 0, voteTypePrevote, "AA"),
+		proposalSignBytes(1, 0),
+	} {
+		if _, err := coreSignValidator(sb, addrHex, ""); err == nil {
+			t.Fatal("Expected regression to be refused")
+		}
+	}
+
+	if _, err := coreSignValidator(proposalSignBytes(2, 1), addrHex, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSignValidator(voteSignBytes(2, 0, voteTypePrevote, "AA"), addrHex, ""); err == nil {
+		t.Fatal("Expected round regression to be refused")
+	}
+	if _, err := coreSignValidator(voteSignBytes(1, 5, voteTypePrevote, "AA"), addrHex, ""); err == nil {
+		t.Fatal("Expected height regression to be refused")
+	}
+
+	state, err := loadValidatorState(addrHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LastHeight != 2 || state.LastRound != 1 || state.LastStep != stepPropose {
+		t.Fatalf("Wrong validator state. Got %d/%d/%d", state.LastHeight, state.LastRound, state.LastStep)
+	}
+
+	// raw signing would get around the checks
+	conflicting := voteSignBytes(2, 1, voteTypePrevote, "BB")
+	if _, err := coreSign(conflicting, addrHex, ""); err == nil || !strings.Contains(err.Error(), "validator key") {
+		t.Fatalf("Expected raw signing with a validator key to be refused, got %v", err)
+	}
+	if _, err := coreSignBatch([]string{testSigData, conflicting}, []string{addrHex}, ""); err == nil {
+		t.Fatal("Expected batch signing with a validator key to be refused")
+	}
+}
+
+func TestSignValidatorBadBytes(t *testing.T) {
+	addr, err := coreKeygen(AUTH, "ed25519,ripemd160", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := coreMarkValidator(toHex(addr), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSignValidator(testSigData, toHex(addr), ""); err == nil {
+		t.Fatal("Expected arbitrary bytes to be refused in validator mode")
+	}
+}
+
+func TestSignValidatorMarked(t *testing.T) {
+	prevote := voteSignBytes(1, 0, voteTypePrevote, "AA")
+
+	// a key that wasn't made a validator key could already have signed any vote
+	addr, err := coreKeygen(AUTH, "ed25519,ripemd160", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSign(prevote, toHex(addr), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSignValidator(prevote, toHex(addr), ""); err == nil || !strings.Contains(err.Error(), "not a validator key") {
+		t.Fatalf("Expected validator signing with an unmarked key to be refused, got %v", err)
+	}
+
+	// raw signing is refused from the moment a key is marked,
+	// before it's signed anything as a validator
+	addr, err = coreKeygen(AUTH, "ed25519,ripemd160", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrHex := toHex(addr)
+	if err := coreMarkValidator(addrHex, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSign(prevote, addrHex, ""); err == nil || !strings.Contains(err.Error(), "validator key") {
+		t.Fatalf("Expected raw signing before the first validator signature to be refused, got %v", err)
+	}
+	if _, err := coreSignValidator(prevote, addrHex, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// marking again keeps the state
+	if err := coreMarkValidator(addrHex, ""); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := loadValidatorState(addrHex); err != nil || state.LastHeight != 1 || state.LastStep != stepPrevote {
+		t.Fatalf("Marking a validator key again lost its state: %v %v", state, err)
+	}
+
+	// converting to a priv_validator marks it too
+	addr, err = coreKeygen(AUTH, "ed25519,ripemd160", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreConvert(toHex(addr), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coreSign(prevote, toHex(addr), ""); err == nil {
+		t.Fatal("Expected raw signing with a converted key to be refused")
+	}
+}
@@ -0,0 +1,76 @@
+package keys
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------
+// vanity search progress
+//
+// A vanity search can take hours, during which the client's gen request
+// is waiting. The client gives the search an id, and asks for its progress
+// with /gen/progress while it waits
+
+// VanityProgress is how far a vanity search has got
+type VanityProgress struct {
+	Tried            uint64  `json:"tried"`
+	ExpectedTries    float64 `json:"expected_tries"`
+	Rate             float64 `json:"rate"`              // keys per second
+	ElapsedSeconds   float64 `json:"elapsed_seconds"`   // since the search started
+	ExpectedSeconds  float64 `json:"expected_seconds"`  // for the whole search, at the current rate
+	RemainingSeconds float64 `json:"remaining_seconds"` // expected, though the search may take any time
+}
+
+func newVanityProgress(tried uint64, expected float64, elapsed time.Duration) *VanityProgress {
+	p := &VanityProgress{Tried: tried, ExpectedTries: expected, ElapsedSeconds: elapsed.Seconds()}
+	if tried > 0 && elapsed > 0 {
+		p.Rate = float64(tried) / elapsed.Seconds()
+		p.ExpectedSeconds = expected / p.Rate
+		if p.RemainingSeconds = p.ExpectedSeconds - p.ElapsedSeconds; p.RemainingSeconds < 0 {
+			p.RemainingSeconds = 0
+		}
+	}
+	return p
+}
+
+type vanitySearchMap struct {
+	sync.Mutex
+	m map[string]*VanityProgress
+}
+
+var vanitySearches = &vanitySearchMap{m: make(map[string]*VanityProgress)}
+
+func (v *vanitySearchMap) start(search string, expected float64) error {
+	v.Lock()
+	defer v.Unlock()
+	if _, ok := v.m[search]; ok {
+		return fmt.Errorf("vanity search %s is already running", search)
+	}
+	v.m[search] = &VanityProgress{ExpectedTries: expected}
+	return nil
+}
+
+func (v *vanitySearchMap) update(search string, p *VanityProgress) {
+	v.Lock()
+	defer v.Unlock()
+	v.m[search] = p
+}
+
+func (v *vanitySearchMap) stop(search string) {
+	v.Lock()
+	defer v.Unlock()
+	delete(v.m, search)
+}
+
+// coreVanityProgress returns the progress of the running vanity search
+func coreVanityProgress(search string) (*VanityProgress, error) {
+	vanitySearches.Lock()
+	defer vanitySearches.Unlock()
+	p, ok := vanitySearches.m[search]
+	if !ok {
+		return nil, fmt.Errorf("no vanity search %s is running", search)
+	}
+	return p, nil
+}